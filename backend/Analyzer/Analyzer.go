@@ -1,662 +1,1915 @@
 package Analyzer
 
 import (
+	"MIA_P1/Cache"
+	"MIA_P1/Commands"
+	"MIA_P1/Diff"
 	"MIA_P1/DiskManagement"
+	"MIA_P1/Export"
+	"MIA_P1/Jobs"
+	"MIA_P1/LineEditor"
+	"MIA_P1/Locks"
 	"MIA_P1/OutPut"
+	"MIA_P1/Overlay"
+	"MIA_P1/Parser"
+	"MIA_P1/Preprocessor"
+	"MIA_P1/Render"
+	"MIA_P1/Reports"
+	"MIA_P1/Results"
 	"MIA_P1/Structs"
 	"MIA_P1/Tree"
+	"MIA_P1/Usage"
 	"MIA_P1/UserManager"
 	"MIA_P1/Utilities"
 	"MIA_P1/stores"
-	"bufio"
-	"flag"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 )
 
-var re = regexp.MustCompile(`-(\w+)=("[^"]+"|\S+)`)
-
-func fn_execute(params string) {
-	fs := flag.NewFlagSet("execute", flag.ContinueOnError)
-	path := fs.String("path", "", "Ruta del archivo script")
+// parseCommand busca el comando en el registro y valida sus parámetros con su
+// esquema. Si el comando existe se retorna aunque sus parámetros sean inválidos.
+func parseCommand(command string, params string) (*Commands.Command, *Parser.Args, error) {
+	cmd, ok := Commands.Lookup(command)
+	if !ok {
+		return nil, nil, Results.Errorf(Results.UnknownCommand, "comando no reconocido: %s", command)
+	}
+	args, err := Parser.Parse(cmd.Schema(), params)
+	return cmd, args, err
+}
 
-	if err := fs.Parse(strings.Fields(params)); err != nil {
-		OutPut.Println("Error al parsear parámetros:", err)
-		return
+// checkAccess verifica que la sesión actual permita ejecutar el comando
+func checkAccess(cmd *Commands.Command) error {
+	if cmd.Access == Commands.Anyone {
+		return nil
 	}
-	if *path == "" {
-		OutPut.Println("Error: el parámetro -path es obligatorio")
-		return
+	user, loggedIn := UserManager.CurrentUser()
+	if !loggedIn {
+		return Results.Errorf(Results.NotLoggedIn, "%s necesita iniciar sesión", cmd.Name)
+	}
+	if cmd.Access == Commands.Root && user != "root" {
+		return Results.Errorf(Results.PermissionDenied, "solo el usuario root puede ejecutar %s", cmd.Name)
 	}
+	return nil
+}
 
-	normalizedPath := strings.ReplaceAll(*path, "\\", "/")
-	normalizedPath = strings.Trim(normalizedPath, "\"") // Elimina comillas
+// ScriptResult es el resultado de ejecutar un script completo o hasta una pausa
+type ScriptResult struct {
+	Results        []string         // eco de cada línea ejecutada
+	Commands       []Results.Result // resultado de cada comando ejecutado
+	Paused         bool
+	Confirm        bool
+	ConfirmMessage string
+	Cancelled      bool
+	Stopped        bool             // un comando falló bajo on-error -action=stop
+	File           string           // archivo incluido donde se detuvo; vacío para el script principal
+	Line           int              // número de línea (desde 1) donde se detuvo el script
+	Next           int              // índice del paso con el que continúa el script
+	DryRun         bool             // el script se simuló sobre copias de los discos
+	Changes        []Overlay.Change // cambios que haría el script, solo en dry-run
+	Transactional  bool             // el script se ejecutó como una transacción
+	RolledBack     bool             // la transacción falló y se deshicieron sus cambios
+}
+
+// LineResult es el resultado de una línea de un script, para seguir su avance
+type LineResult struct {
+	File   string         `json:"file,omitempty"`
+	Line   int            `json:"line"`
+	Text   string         `json:"text"`
+	Result Results.Result `json:"result"`
+	Output []OutPut.Line  `json:"output"`
+}
+
+// fn_execute ejecuta un script .sdaa. Con -dryrun el script se simula y los cambios
+// que haría van en los datos del resultado; dentro de otro dry-run se ejecuta
+// normalmente porque ya trabaja sobre las copias.
+func fn_execute(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	path := args.String("path")
+	normalizedPath := strings.ReplaceAll(path, "\\", "/")
 
 	if !strings.HasSuffix(strings.ToLower(normalizedPath), ".sdaa") {
-		OutPut.Println("Error: el archivo debe tener la extensión .sdaa")
-		return
+		return "", nil, Results.Errorf(Results.InvalidParams, "el archivo debe tener la extensión .sdaa")
 	}
 
-	// Lee el contenido del archivo
-	content, err := os.ReadFile(normalizedPath)
+	steps, err := Preprocessor.ExpandFile(normalizedPath)
 	if err != nil {
-		fmt.Printf("Error al leer el archivo %s: %v\n", *path, err)
-		return
+		var problems Preprocessor.Errors
+		if errors.As(err, &problems) {
+			return "", nil, Results.Errorf(Results.InvalidParams, "el script %s tiene errores: %v", path, err)
+		}
+		return "", nil, Results.Errorf(Results.NotFound, "Error al leer el archivo %s: %v", path, err)
 	}
 
-	lines := strings.Split(string(content), "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-
-		if line == "" {
-			continue // línea vacía
+	if args.Bool("dryrun") && !Overlay.Active() {
+		if Overlay.InProgress() {
+			return "", nil, Results.Errorf(Results.InvalidParams, "no se puede usar -dryrun dentro de un script transaccional")
 		}
-
-		if strings.HasPrefix(line, "#") {
-			OutPut.Println(line) // muestra comentarios
-			continue
+		var scriptErr error
+		changes, err := DryRun(func() {
+			scriptErr = executeFile(out, steps)
+		})
+		if err != nil {
+			return "", nil, err
 		}
+		printChanges(out, changes)
+		return "Script simulado, no se modificó ningún disco", map[string]interface{}{"changes": changes}, scriptErr
+	}
+	return "Script ejecutado", nil, executeFile(out, steps)
+}
 
-		// Ejecuta comando
-		fmt.Printf(">> %s\n", line)
-		command, params := GetCommandAndParams(line)
-		AnalyzeCommand(command, params)
+// executeFile ejecuta los pasos de un script llamado con execute. Un script anidado
+// no puede detenerse a preguntar, así que los pause y los comandos que piden
+// confirmación se omiten.
+func executeFile(out *OutPut.Output, steps []Preprocessor.Step) error {
+	var res ScriptResult
+	runAll(context.Background(), out, steps, &res, runOptions{echo: true})
+	failed := 0
+	for _, result := range res.Commands {
+		if result.Failed() {
+			failed++
+		}
+	}
+	if res.Stopped {
+		return Results.Errorf(Results.Failed, "el script se detuvo en la %s", location(res.File, res.Line))
 	}
+	if failed > 0 {
+		return Results.Errorf(Results.Failed, "%d comandos del script fallaron", failed)
+	}
+	return nil
 }
 
-func Analyze() {
-	scanner := bufio.NewScanner(os.Stdin)
-	OutPut.Println("Ingrese un comando (o 'exit' para salir):")
-	for scanner.Scan() {
-		input := strings.TrimSpace(scanner.Text())
+// Analyze ejecuta comandos de forma interactiva con la salida en la terminal hasta
+// que se cierra la entrada. Los comandos que piden confirmación se preguntan al usuario.
+func Analyze(editor *LineEditor.Editor) {
+	out := OutPut.NewConsole(os.Stdout)
+	for {
+		input, err := editor.ReadLine("mia> ")
+		if err != nil {
+			if err != io.EOF {
+				out.Error("Error reading input:", err)
+			}
+			break
+		}
+		input = strings.TrimSpace(input)
 		if input == "" || strings.HasPrefix(input, "#") {
 			continue
 		}
+		editor.AddHistory(input)
 		command, params := GetCommandAndParams(input)
-		OutPut.Println("Command:", command, "Params:", params)
-		AnalyzeCommand(command, params)
-		OutPut.Println("Ingrese un comando (o 'exit' para salir):")
+		result := AnalyzeCommand(out, command, params)
+		if result.Status == Results.StatusConfirm {
+			// En la terminal la confirmación se pregunta directamente al usuario
+			answer, err := editor.ReadLine(result.Message + " (s/n): ")
+			if err == nil && IsYes(answer) {
+				AnalyzeConfirmed(out, command, params)
+			} else {
+				out.Println("Operación cancelada")
+			}
+		}
 	}
-	if err := scanner.Err(); err != nil {
-		OutPut.Println("Error reading input:", err)
+	if err := Cache.FlushAll(); err != nil {
+		out.Error("Error al guardar las particiones:", err)
 	}
 }
 
+// IsYes indica si la respuesta del usuario es afirmativa
+func IsYes(answer string) bool {
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "s", "si", "sí", "y", "yes":
+		return true
+	}
+	return false
+}
+
+// GetCommandAndParams separa el nombre del comando de sus parámetros.
+// Los parámetros se retornan sin modificar para conservar los espacios entre comillas.
 func GetCommandAndParams(input string) (string, string) {
-	parts := strings.Fields(input)
-	if len(parts) > 0 {
-		command := strings.ToLower(parts[0])
-		params := strings.Join(parts[1:], " ")
-		return command, params
-	}
-	return "", input
-}
-
-func fn_pause() string {
-	return "[PAUSE]"
-}
-
-func fn_mount(params string) {
-	fs := flag.NewFlagSet("mount", flag.ExitOnError)
-	name := fs.String("driveletter", "", "Letter of the drive")
-	path := fs.String("name", "", "name of the partition")
-	managementFlags(fs, params)
-	DiskManagement.Mount(*name, *path)
-}
-
-func fn_fdisk(params string) {
-	fs := flag.NewFlagSet("fdisk", flag.ExitOnError)
-	size := fs.Int("size", 0, "Size")
-	driveLetter := fs.String("driveletter", "", "Letra de unidad")
-	name := fs.String("name", "", "Nombre de la partición")
-	type_ := fs.String("type", "P", "Tipo de partición (p/e)")
-	fit := fs.String("fit", "F", "Fit")
-	delete := fs.String("delete", "", "Delete")
-	unit := fs.String("unit", "M", "Unit")
-	add := fs.Int("add", 0, "Add")
-	managementFlags(fs, params)
-	DiskManagement.Fdisk(*size, strings.ToUpper(*driveLetter), *name,
-		strings.ToUpper(*type_), strings.ToUpper(*fit), strings.ToUpper(*delete), strings.ToUpper(*unit), *add)
-}
-
-func fn_mkdisk(params string) {
-	fs := flag.NewFlagSet("mkdisk", flag.ExitOnError)
-	size := fs.Int("size", 0, "Size")
-	fit := fs.String("fit", "FF", "Fit")
-	unit := fs.String("unit", "M", "Unit")
-	managementFlags(fs, params)
-	DiskManagement.Mkdisk(*size, strings.ToUpper(*fit), strings.ToUpper(*unit))
-}
-
-func fn_rmdisk(params string) string {
-	fs := flag.NewFlagSet("rmdisk", flag.ExitOnError)
-	driveLetter := fs.String("driveletter", "", "Drive letter")
-	confirm := fs.Bool("confirm", false, "Confirm deletion")
-	managementFlags(fs, params)
-	return DiskManagement.Rmdisk(strings.ToUpper(*driveLetter), *confirm)
-}
-
-func managementFlags(fs *flag.FlagSet, params string) {
-	fs.Parse(os.Args[1:])
-	matches := re.FindAllStringSubmatch(params, -1)
-	var flagNames []string
-	fs.VisitAll(func(f *flag.Flag) {
-		flagNames = append(flagNames, f.Name)
-	})
-	for _, match := range matches {
-		flagName := match[1]
-		flagValue := match[2]
-		flagValue = strings.Trim(flagValue, "\"")
-		if contains(flagNames, flagName) {
-			fs.Set(flagName, flagValue)
-		} else {
-			OutPut.Println("Error: Flag not found:", flagName)
-		}
+	return Parser.SplitCommand(input)
+}
+
+// init registra los comandos de la consola. El orden es el que muestra la ayuda.
+func init() {
+	Commands.Register(Commands.Command{
+		Name:    "mkdisk",
+		Summary: "Crea un disco virtual en ./tets con la siguiente letra libre",
+		Flags: []Parser.Flag{
+			{Name: "size", Kind: Parser.Int, Required: true, Help: "Tamaño del disco"},
+			{Name: "fit", Default: "FF", Values: []string{"BF", "FF", "WF"}, Help: "Ajuste del disco"},
+			{Name: "unit", Default: "M", Values: []string{"K", "M"}, Help: "Unidad del tamaño"},
+		},
+		Examples: []string{
+			"mkdisk -size=10 -unit=M",
+			"mkdisk -size=3000 -unit=K -fit=BF",
+		},
+		Run: fn_mkdisk,
+	})
+	Commands.Register(Commands.Command{
+		Name:        "rmdisk",
+		Summary:     "Elimina un disco virtual",
+		Confirmable: true,
+		Flags: []Parser.Flag{
+			{Name: "driveletter", Required: true, Help: "Letra del disco"},
+		},
+		Examples: []string{
+			"rmdisk -driveletter=A",
+			"rmdisk -driveletter=A -force",
+		},
+		Run: fn_rmdisk,
+	})
+	Commands.Register(Commands.Command{
+		Name:        "fdisk",
+		Summary:     "Crea, elimina o redimensiona una partición",
+		Confirmable: true,
+		Flags: []Parser.Flag{
+			{Name: "size", Kind: Parser.Int, Help: "Tamaño de la partición"},
+			{Name: "driveletter", Required: true, Help: "Letra del disco"},
+			{Name: "name", Required: true, Help: "Nombre de la partición"},
+			{Name: "type", Default: "P", Values: []string{"P", "E"}, Help: "Tipo de partición"},
+			{Name: "fit", Default: "F", Values: []string{"B", "F", "W"}, Help: "Ajuste de la partición"},
+			{Name: "delete", Values: []string{"FULL"}, Help: "Elimina la partición"},
+			{Name: "unit", Default: "M", Values: []string{"B", "K", "M"}, Help: "Unidad del tamaño"},
+			{Name: "add", Kind: Parser.Int, Help: "Espacio a agregar o quitar"},
+		},
+		Examples: []string{
+			"fdisk -size=300 -driveletter=A -name=Particion1",
+			"fdisk -size=1 -unit=M -type=E -fit=B -driveletter=A -name=Extendida",
+			"fdisk -add=-500 -unit=K -driveletter=A -name=Particion1",
+			"fdisk -delete=full -driveletter=A -name=Particion1 -confirm",
+		},
+		Run: fn_fdisk,
+	})
+	Commands.Register(Commands.Command{
+		Name:    "mount",
+		Summary: "Monta una partición y le asigna un ID",
+		Flags: []Parser.Flag{
+			{Name: "driveletter", Required: true, Help: "Letra del disco"},
+			{Name: "name", Required: true, Help: "Nombre de la partición"},
+		},
+		Examples: []string{
+			"mount -driveletter=A -name=Particion1",
+		},
+		Run: fn_mount,
+	})
+	Commands.Register(Commands.Command{
+		Name:    "unmount",
+		Aliases: []string{"umount"},
+		Summary: "Desmonta una partición",
+		Flags: []Parser.Flag{
+			{Name: "id", Required: true, Help: "ID de la partición montada"},
+		},
+		Examples: []string{
+			"unmount -id=A100",
+		},
+		Run: fn_unmount,
+	})
+	Commands.Register(Commands.Command{
+		Name:    "listmount",
+		Summary: "Lista las particiones montadas",
+		Examples: []string{
+			"listmount",
+		},
+		Run: fn_listmount,
+	})
+	Commands.Register(Commands.Command{
+		Name:        "mkfs",
+		Summary:     "Formatea una partición montada",
+		Confirmable: true,
+		Flags: []Parser.Flag{
+			{Name: "id", Required: true, Help: "ID de la partición montada"},
+			{Name: "type", Default: "FULL", Values: []string{"FULL"}, Help: "Tipo de formateo"},
+			{Name: "fs", Default: "2FS", Values: []string{"2FS", "3FS"}, Help: "Sistema de archivos"},
+		},
+		Examples: []string{
+			"mkfs -id=A100",
+			"mkfs -id=A100 -fs=3fs -force",
+		},
+		Run: fn_mkfs,
+	})
+	Commands.Register(Commands.Command{
+		Name:    "login",
+		Summary: "Inicia sesión en una partición montada",
+		Flags: []Parser.Flag{
+			{Name: "user", Required: true, Help: "Usuario"},
+			{Name: "pass", Required: true, Help: "Contraseña"},
+			{Name: "id", Required: true, Help: "ID de la partición montada"},
+		},
+		Examples: []string{
+			"login -user=root -pass=123 -id=A100",
+		},
+		Run: fn_login,
+	})
+	Commands.Register(Commands.Command{
+		Name:    "logout",
+		Summary: "Cierra la sesión actual",
+		Access:  Commands.LoggedIn,
+		Examples: []string{
+			"logout",
+		},
+		Run: fn_logout,
+	})
+	Commands.Register(Commands.Command{
+		Name:    "mkgrp",
+		Summary: "Crea un grupo de usuarios",
+		Access:  Commands.Root,
+		Flags: []Parser.Flag{
+			{Name: "name", Required: true, Help: "Nombre del grupo"},
+		},
+		Examples: []string{
+			"mkgrp -name=usuarios",
+		},
+		Run: fn_mkgrp,
+	})
+	Commands.Register(Commands.Command{
+		Name:    "rmgrp",
+		Summary: "Elimina un grupo de usuarios",
+		Access:  Commands.Root,
+		Flags: []Parser.Flag{
+			{Name: "name", Required: true, Help: "Nombre del grupo a eliminar"},
+		},
+		Examples: []string{
+			"rmgrp -name=usuarios",
+		},
+		Run: fn_rmgrp,
+	})
+	Commands.Register(Commands.Command{
+		Name:    "mkusr",
+		Summary: "Crea un usuario dentro de un grupo",
+		Access:  Commands.Root,
+		Flags: []Parser.Flag{
+			{Name: "user", Required: true, Help: "Nombre del usuario"},
+			{Name: "pass", Required: true, Help: "Contraseña"},
+			{Name: "grp", Required: true, Help: "Grupo"},
+		},
+		Examples: []string{
+			"mkusr -user=user1 -pass=usuario -grp=usuarios",
+		},
+		Run: fn_mkusr,
+	})
+	Commands.Register(Commands.Command{
+		Name:    "rmusr",
+		Summary: "Elimina un usuario",
+		Access:  Commands.Root,
+		Flags: []Parser.Flag{
+			{Name: "user", Required: true, Help: "Nombre del usuario a eliminar"},
+		},
+		Examples: []string{
+			"rmusr -user=user1",
+		},
+		Run: fn_rmusr,
+	})
+	Commands.Register(Commands.Command{
+		Name:        "mkfile",
+		Summary:     "Crea un archivo en la partición de la sesión",
+		Confirmable: true,
+		Access:      Commands.LoggedIn,
+		Flags: []Parser.Flag{
+			{Name: "path", Required: true, Help: "Ruta del archivo a crear"},
+			{Name: "size", Kind: Parser.Int, Help: "Tamaño del archivo en bytes"},
+			{Name: "cont", Help: "Ruta a un archivo externo con contenido"},
+			{Name: "r", Kind: Parser.Bool, Help: "Crear carpetas padre si no existen"},
+		},
+		Examples: []string{
+			"mkfile -path=/home/user/docs/a.txt -size=15 -r",
+			`mkfile -path="/home/mis documentos/b.txt" -cont=/home/real/b.txt`,
+		},
+		Run: fn_mkfile,
+	})
+	Commands.Register(Commands.Command{
+		Name:    "mkdir",
+		Summary: "Crea una carpeta en la partición de la sesión",
+		Access:  Commands.LoggedIn,
+		Flags: []Parser.Flag{
+			{Name: "path", Required: true, Help: "Ruta de la carpeta a crear"},
+			{Name: "r", Kind: Parser.Bool, Help: "Crear carpetas padre si no existen"},
+		},
+		Examples: []string{
+			"mkdir -path=/home/user/docs -r",
+		},
+		Run: fn_mkdir,
+	})
+	Commands.Register(Commands.Command{
+		Name:    "rename",
+		Summary: "Cambia el nombre de un archivo o carpeta",
+		Access:  Commands.LoggedIn,
+		Flags: []Parser.Flag{
+			{Name: "path", Required: true, Help: "Ruta del archivo o carpeta"},
+			{Name: "name", Required: true, Help: "Nuevo nombre, de hasta 12 caracteres"},
+		},
+		Examples: []string{
+			"rename -path=/home/a.txt -name=b.txt",
+		},
+		Run: fn_rename,
+	})
+	Commands.Register(Commands.Command{
+		Name:    "chmod",
+		Summary: "Cambia los permisos de un archivo o carpeta",
+		Access:  Commands.LoggedIn,
+		Flags: []Parser.Flag{
+			{Name: "path", Required: true, Help: "Ruta del archivo o carpeta"},
+			{Name: "ugo", Required: true, Help: "Permisos del propietario, el grupo y otros, por ejemplo 764"},
+		},
+		Examples: []string{
+			"chmod -path=/home/a.txt -ugo=764",
+		},
+		Run: fn_chmod,
+	})
+	Commands.Register(Commands.Command{
+		Name:    "chown",
+		Summary: "Cambia el propietario de un archivo o carpeta",
+		Access:  Commands.LoggedIn,
+		Flags: []Parser.Flag{
+			{Name: "path", Required: true, Help: "Ruta del archivo o carpeta"},
+			{Name: "usuario", Required: true, Help: "Nuevo propietario; el grupo pasa a ser el del usuario"},
+		},
+		Examples: []string{
+			"chown -path=/home/a.txt -usuario=user1",
+		},
+		Run: fn_chown,
+	})
+	Commands.Register(Commands.Command{
+		Name:    "remove",
+		Summary: "Elimina un archivo o una carpeta con todo su contenido",
+		Access:  Commands.LoggedIn,
+		Flags: []Parser.Flag{
+			{Name: "path", Required: true, Help: "Ruta del archivo o carpeta a eliminar"},
+		},
+		Examples: []string{
+			"remove -path=/home/a.txt",
+		},
+		Run: fn_remove,
+	})
+	Commands.Register(Commands.Command{
+		Name:    "cat",
+		Summary: "Muestra el contenido de uno o más archivos",
+		Access:  Commands.LoggedIn,
+		Flags: []Parser.Flag{
+			{Name: "file", Prefix: true, Required: true, Help: "Archivos a mostrar: -file1, -file2, ..."},
+		},
+		Examples: []string{
+			"cat -file1=/users.txt",
+			"cat -file1=/home/a.txt -file2=/home/b.txt",
+		},
+		Run: fn_cat,
+	})
+	Commands.Register(Commands.Command{
+		Name:    "rep",
+		Summary: "Genera un reporte de una partición montada",
+		Flags: []Parser.Flag{
+			{Name: "name", Required: true, Values: reportNames, Help: "Tipo de reporte"},
+			{Name: "path", Required: true, Help: "Ruta del reporte dentro de la carpeta de reportes; la extensión elige el formato (.svg, .dot, o .png/.jpg/.pdf con Graphviz; .csv o .md para inode, block, bm_inode, bm_block y ls)"},
+			{Name: "id", Required: true, Help: "ID de la partición montada"},
+			{Name: "path_file_ls", Help: "Ruta del archivo o carpeta para los reportes file y ls"},
+			{Name: "root", Help: "Carpeta desde donde se dibuja el reporte tree"},
+			{Name: "depth", Kind: Parser.Int, Help: "Niveles debajo de -root que incluye el reporte tree; 0 no tiene límite"},
+			{Name: "noblocks", Kind: Parser.Bool, Help: "Oculta los bloques en el reporte tree y deja solo la estructura de inodos y carpetas"},
+			{Name: "top", Kind: Parser.Int, Default: strconv.Itoa(Usage.DefaultTop), Help: "Cantidad de archivos y carpetas más grandes que lista el reporte usage"},
+			{Name: "async", Kind: Parser.Bool, Help: "Genera el reporte en segundo plano y retorna el trabajo"},
+		},
+		Examples: []string{
+			"rep -id=A100 -path=mbr.svg -name=mbr",
+			"rep -id=A100 -path=tree.png -name=tree",
+			"rep -id=A100 -path=ls.svg -name=ls -path_file_ls=/home",
+			"rep -id=A100 -path=inode.svg -name=inode -async",
+			"rep -id=A100 -path=home.svg -name=tree -root=/home -depth=2 -noblocks",
+			"rep -id=A100 -path=uso.svg -name=usage -top=5",
+			"rep -id=A100 -path=inodos.csv -name=inode",
+		},
+		Run: generarReportes,
+	})
+	Commands.Register(Commands.Command{
+		Name:    "diff",
+		Summary: "Compara los archivos de dos particiones o de una partición y una instantánea",
+		Flags: []Parser.Flag{
+			{Name: "id1", Required: true, Help: "ID de la partición montada de referencia (antes)"},
+			{Name: "id2", Help: "ID de la partición montada que se compara (después)"},
+			{Name: "snapshot", Help: "Instantánea guardada con snapshot que se usa como referencia en lugar de -id1"},
+			{Name: "path", Default: "/", Help: "Carpeta que se compara en los dos lados"},
+		},
+		Examples: []string{
+			"diff -id1=A100 -id2=B100",
+			"diff -id1=A100 -id2=B100 -path=/home",
+			"diff -snapshot=antes -id1=A100",
+		},
+		Run: fn_diff,
+	})
+	Commands.Register(Commands.Command{
+		Name:    "snapshot",
+		Summary: "Guarda los archivos de una partición para compararlos después con diff",
+		Flags: []Parser.Flag{
+			{Name: "id", Required: true, Help: "ID de la partición montada"},
+			{Name: "name", Required: true, Help: "Nombre de la instantánea; uno existente se reemplaza"},
+			{Name: "path", Default: "/", Help: "Carpeta desde donde se guardan los archivos"},
+		},
+		Examples: []string{
+			"snapshot -id=A100 -name=antes",
+			"snapshot -id=A100 -name=home -path=/home",
+		},
+		Run: fn_snapshot,
+	})
+	Commands.Register(Commands.Command{
+		Name:    "execute",
+		Summary: "Ejecuta un script .sdaa",
+		Flags: []Parser.Flag{
+			{Name: "path", Required: true, Help: "Ruta del archivo script .sdaa"},
+			{Name: "dryrun", Kind: Parser.Bool, Help: "Simula el script sin modificar los discos"},
+		},
+		Examples: []string{
+			"execute -path=scripts/calificacion.sdaa",
+			"execute -path=scripts/calificacion.sdaa -dryrun",
+		},
+		Run: fn_execute,
+	})
+	Commands.Register(Commands.Command{
+		Name:    "help",
+		Summary: "Lista los comandos o muestra la ayuda de uno",
+		Flags: []Parser.Flag{
+			{Name: "command", Positional: true, Help: "Comando del que se muestra la ayuda, igual que man"},
+		},
+		Examples: []string{
+			"help",
+			"help fdisk",
+		},
+		Run: fn_help,
+	})
+	Commands.Register(Commands.Command{
+		Name:    "man",
+		Summary: "Muestra los parámetros, valores por defecto y ejemplos de un comando",
+		Flags: []Parser.Flag{
+			{Name: "command", Positional: true, Required: true, Help: "Nombre o alias del comando"},
+		},
+		Examples: []string{
+			"man mkdisk",
+			"man -command=fdisk",
+		},
+		Run: fn_man,
+	})
+	Commands.Register(Commands.Command{
+		Name:    "pause",
+		Summary: "Detiene un script hasta que el usuario lo continúe",
+		Examples: []string{
+			"pause",
+		},
+		Run: fn_pause,
+	})
+	Commands.Register(Commands.Command{
+		Name:    "exit",
+		Summary: "Guarda las particiones y termina el programa",
+		Examples: []string{
+			"exit",
+		},
+		Run: fn_exit,
+	})
+}
+
+// fn_help lista los comandos registrados con su descripción
+func fn_help(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	if args.Has("command") {
+		return fn_man(out, args)
+	}
+	commands := Commands.All()
+	width := 0
+	for _, cmd := range commands {
+		width = max(width, len(cmd.Name))
 	}
+	docs := make([]Commands.Doc, 0, len(commands))
+	out.Println("Comandos disponibles:")
+	for _, cmd := range commands {
+		out.Printf("  %-*s  %s\n", width, cmd.Name, cmd.Summary)
+		docs = append(docs, cmd.Doc())
+	}
+	out.Println("Use man <comando> para ver sus parámetros y ejemplos.")
+	out.Println("En los scripts también se pueden usar set, include, on-error e if-exists/else/end.")
+	return "Ayuda mostrada", map[string]interface{}{"commands": docs}, nil
 }
 
-func contains(slice []string, item string) bool {
-	for _, s := range slice {
-		if s == item {
-			return true
-		}
+// fn_man muestra la ayuda completa de un comando
+func fn_man(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	cmd, ok := Commands.Lookup(args.String("command"))
+	if !ok {
+		return "", nil, Results.Errorf(Results.NotFound, "no existe el comando %s; use help para ver la lista", args.String("command"))
 	}
-	return false
+	out.Println(cmd.Manual())
+	return "Ayuda de " + cmd.Name, map[string]interface{}{"command": cmd.Doc()}, nil
+}
+
+func fn_pause(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	return "Pausa completada", nil, nil
 }
 
-func fn_mkfs(params string) {
-	fs := flag.NewFlagSet("mkfs", flag.ExitOnError)
-	id := fs.String("id", "", "Partition ID")
-	type_ := fs.String("type", "FULL", "Format type")
-	fsType := fs.String("fs", "2FS", "Filesystem (2FS/3FS)")
-	managementFlags(fs, params)
-	if err := UserManager.Mkfs(strings.ToUpper(*id), strings.ToUpper(*type_), strings.ToUpper(*fsType)); err != nil {
-		OutPut.Println("Error:", err)
+func fn_exit(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	if Overlay.Active() {
+		out.Println("Dry-run: se omite exit")
+		return "exit omitido en dry-run", nil, nil
+	}
+	if Overlay.InProgress() {
+		return "", nil, Results.Errorf(Results.Failed, "exit no se puede usar en un script transaccional")
 	}
+	out.Println("Exiting the program.")
+	if err := Cache.FlushAll(); err != nil {
+		out.Error("Error al guardar las particiones:", err)
+	}
+	os.Exit(0)
+	return "", nil, nil
 }
 
-func fn_unmount(params string) {
-	fs := flag.NewFlagSet("unmount", flag.ExitOnError)
-	id := fs.String("id", "", "Partition ID")
-	managementFlags(fs, params)
-	DiskManagement.Unmount(strings.ToUpper(*id))
+func fn_mount(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	id, err := DiskManagement.Mount(out, args.String("driveletter"), args.String("name"))
+	return "Partición montada correctamente", map[string]string{"id": id}, err
 }
 
-func fn_login(params string) {
-	fs := flag.NewFlagSet("login", flag.ContinueOnError)
-	user := fs.String("user", "", "Usuario")
-	pass := fs.String("pass", "", "Contraseña")
-	id := fs.String("id", "", "ID de partición")
+func fn_listmount(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	stores.ListMountedPartitions(out)
+	return "Particiones montadas listadas", nil, nil
+}
 
-	if err := fs.Parse(strings.Fields(params)); err != nil {
-		OutPut.Println("Error al parsear parámetros:", err)
-		return
+func fn_fdisk(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	err := DiskManagement.Fdisk(out, args.Int("size"), strings.ToUpper(args.String("driveletter")), args.String("name"),
+		strings.ToUpper(args.String("type")), strings.ToUpper(args.String("fit")), strings.ToUpper(args.String("delete")),
+		strings.ToUpper(args.String("unit")), args.Int("add"), args.Confirmed())
+	return "Partición gestionada correctamente", nil, err
+}
+
+func fn_mkdisk(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	disk, err := DiskManagement.Mkdisk(out, args.Int("size"), strings.ToUpper(args.String("fit")), strings.ToUpper(args.String("unit")))
+	return "Disco creado correctamente", map[string]string{"disk": disk}, err
+}
+
+func fn_rmdisk(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	err := DiskManagement.Rmdisk(out, strings.ToUpper(args.String("driveletter")), args.Confirmed())
+	return "Disco eliminado correctamente", nil, err
+}
+
+func fn_mkfs(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	err := UserManager.Mkfs(out, strings.ToUpper(args.String("id")), strings.ToUpper(args.String("type")), strings.ToUpper(args.String("fs")), args.Confirmed())
+	return "Sistema de archivos creado correctamente", nil, err
+}
+
+func fn_unmount(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	err := DiskManagement.Unmount(out, strings.ToUpper(args.String("id")))
+	return "Partición desmontada correctamente", nil, err
+}
+
+func fn_login(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	err := UserManager.Login(out, args.String("user"), args.String("pass"), args.String("id"))
+	return "Sesión iniciada", nil, err
+}
+
+func fn_logout(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	return "Sesión cerrada", nil, UserManager.Logout(out)
+}
+
+func fn_mkgrp(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	err := UserManager.Mkgrp(out, args.String("name"))
+	return "Grupo creado correctamente", nil, err
+}
+
+func fn_rmgrp(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	err := UserManager.Rmgrp(out, args.String("name"))
+	return "Grupo eliminado correctamente", nil, err
+}
+
+func fn_mkusr(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	err := UserManager.Mkusr(out, args.String("user"), args.String("pass"), args.String("grp"))
+	return "Usuario creado correctamente", nil, err
+}
+
+func fn_rmusr(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	err := UserManager.Rmusr(out, args.String("user"))
+	return "Usuario eliminado correctamente", nil, err
+}
+
+func fn_mkfile(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	size := args.Int("size")
+	cont := args.String("cont")
+
+	// Validaciones
+	if size < 0 {
+		return "", nil, Results.Errorf(Results.InvalidParams, "El tamaño no puede ser negativo")
 	}
 
-	if *user == "" || *pass == "" || *id == "" {
-		OutPut.Println("Error: todos los parámetros son obligatorios")
-		return
+	if cont != "" && size > 0 {
+		out.Warning("Advertencia: Se usará el archivo de contenido. El parámetro -size será ignorado.")
 	}
 
-	if err := UserManager.Login(*user, *pass, *id); err != nil {
-		OutPut.Println("ADVERTENCIA:", err.Error())
+	err := UserManager.Mkfile(out, args.String("path"), args.Bool("r"), size, cont, args.Confirmed())
+	return "Archivo creado correctamente", nil, err
+}
+
+func fn_cat(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	// Call the Cat function from UserManager
+	content, err := UserManager.Cat(args.Prefixed("file"))
+	if err != nil {
+		return "", nil, err
 	}
+
+	// Print the result
+	out.Println(content)
+	return "Comando cat ejecutado", map[string]string{"content": content}, nil
 }
 
-func fn_logout(params string) {
-	if params != "" {
-		OutPut.Println("Error: logout no acepta parámetros")
-		return
+func fn_mkdir(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	// Call Mkdir from UserManager
+	err := UserManager.Mkdir(out, args.String("path"), args.Bool("r"))
+	return "Directorio creado correctamente", nil, err
+}
+
+func fn_rename(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	err := UserManager.Rename(out, args.String("path"), args.String("name"))
+	return "Nombre cambiado correctamente", nil, err
+}
+
+func fn_chmod(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	err := UserManager.Chmod(out, args.String("path"), args.String("ugo"))
+	return "Permisos cambiados correctamente", nil, err
+}
+
+func fn_chown(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	err := UserManager.Chown(out, args.String("path"), args.String("usuario"))
+	return "Propietario cambiado correctamente", nil, err
+}
+
+func fn_remove(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	err := UserManager.Remove(out, args.String("path"))
+	return "Eliminado correctamente", nil, err
+}
+
+// printError escribe en la salida el error de un comando fallido
+func printError(out *OutPut.Output, err error) {
+	msg := err.Error()
+	if !strings.HasPrefix(strings.ToLower(msg), "error") {
+		msg = "Error: " + msg
 	}
-	if err := UserManager.Logout(); err != nil {
-		OutPut.Println("Error:", err)
+	out.Error(msg)
+}
+
+func fn_reportMBR(out *OutPut.Output, id string, path string) error {
+	// Leer el MBR del disco que contiene la partición montada
+	mbr, _, err := stores.GetMountedMBR(id)
+	if err != nil {
+		return Results.Errorf(Results.NotFound, "%v", err)
+	}
+
+	// Llamar a la función que genera el reporte
+	if err := DiskManagement.ReportMBR(out, mbr, path); err != nil {
+		return Results.Errorf(Results.IOError, "Error al generar el reporte MBR: %v", err)
 	}
+	return nil
 }
 
-func fn_mkgrp(params string) string {
-	fs := flag.NewFlagSet("mkgrp", flag.ContinueOnError)
-	name := fs.String("name", "", "Name")
-	params = strings.ToLower(params)
-	if err := fs.Parse(strings.Fields(params)); err != nil {
-		return "Error al parsear los paràmetros: " + err.Error()
+// reportNames son los reportes que genera rep y que la API sirve como JSON
+var reportNames = []string{"mbr", "disk", "inode", "block", "bm_inode", "bm_block", "tree", "sb", "file", "ls", "usage"}
+
+// exportNames son los reportes tabulares que se pueden escribir como .csv o .md
+var exportNames = []string{"inode", "block", "bm_inode", "bm_block", "ls"}
+
+// ReportParams son los parámetros de un reporte, los mismos de rep
+type ReportParams struct {
+	Name       string
+	ID         string       // partición montada
+	PathFileLs string       // solo para los reportes file y ls
+	Tree       Tree.Options // solo para el reporte tree
+	Top        int          // solo para el reporte usage; 0 usa Usage.DefaultTop
+}
+
+// checkReport normaliza el nombre del reporte y valida que sea conocido, que file
+// y ls tengan la ruta a reportar y que la partición esté montada
+func checkReport(p *ReportParams) error {
+	p.Name = strings.ToLower(p.Name)
+	name, id, path_file_ls := p.Name, p.ID, p.PathFileLs
+
+	// Validar que el nombre del reporte sea uno de los valores permitidos
+	if !slices.Contains(reportNames, name) {
+		return Results.Errorf(Results.InvalidParams, "El valor de -name debe ser uno de los siguientes: %s", strings.Join(reportNames, ", "))
 	}
 
-	if *name == "" {
-		return "Error: el nombre esta vacio"
+	// Para reportes file y ls, validar que el parámetro path_file_ls esté presente
+	if (name == "file" || name == "ls") && path_file_ls == "" {
+		return Results.Errorf(Results.InvalidParams, "Para reportes file y ls, el parámetro -path_file_ls es obligatorio")
+	}
+	if p.Tree.Depth < 0 {
+		return Results.Errorf(Results.InvalidParams, "-depth debe ser mayor o igual a 0")
+	}
+	if p.Top == 0 {
+		p.Top = Usage.DefaultTop
+	}
+	if p.Top < 0 {
+		return Results.Errorf(Results.InvalidParams, "-top debe ser mayor que 0")
 	}
+	return checkMounted(id)
+}
 
-	err := UserManager.Mkgrp(*name)
+// checkMounted valida que la partición con el ID especificado esté montada
+func checkMounted(id string) error {
+	for _, partitions := range DiskManagement.GetMountedPartitions() {
+		for _, partition := range partitions {
+			if partition.ID == id {
+				return nil
+			}
+		}
+	}
+	return Results.Errorf(Results.NotFound, "No se encontró ninguna partición montada con el ID %s", id)
+}
+
+// ReportData retorna los datos de un reporte sin dibujarlo, los mismos que usa rep
+func ReportData(ctx context.Context, out *OutPut.Output, p ReportParams) (interface{}, error) {
+	if err := checkReport(&p); err != nil {
+		return nil, err
+	}
+	id := p.ID
+
+	var (
+		data interface{}
+		err  error
+	)
+	switch p.Name {
+	case "tree":
+		data, err = Tree.TreeReportData(ctx, id, p.Tree)
+	case "mbr":
+		mbr, _, mbrErr := stores.GetMountedMBR(id)
+		if mbrErr != nil {
+			return nil, Results.Errorf(Results.NotFound, "%v", mbrErr)
+		}
+		data = DiskManagement.MBRReportData(mbr)
+	case "disk":
+		data, err = DiskManagement.DiskReportData(id)
+	case "inode":
+		data, err = DiskManagement.InodeReportData(ctx, out, id)
+	case "block":
+		data, err = DiskManagement.BlockReportData(ctx, out, id)
+	case "bm_inode":
+		data, err = DiskManagement.BmInodeReportData(id)
+	case "bm_block":
+		data, err = DiskManagement.BmBlockReportData(id)
+	case "file":
+		data, err = UserManager.FileReportData(id, p.PathFileLs)
+	case "ls":
+		data, err = UserManager.LsReportData(id, p.PathFileLs)
+	case "usage":
+		data, err = Usage.UsageReportData(ctx, id, p.Top)
+	case "sb":
+		data, err = DiskManagement.SuperBlockReportData(id)
+	}
 	if err != nil {
-		OutPut.Println("Error al crear el grupo:", err)
-		return "Error al crear el grupo: " + err.Error()
+		return nil, Results.Errorf(Results.CodeOf(err), "Error al generar el reporte: %v", err)
 	}
-	return "Grupo creado correctamente"
+	return data, nil
 }
 
-func fn_rmgrp(params string) {
-	fs := flag.NewFlagSet("rmgrp", flag.ContinueOnError)
-	name := fs.String("name", "", "Nombre del grupo a eliminar")
-	params = strings.ToLower(params)
+func generarReportes(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	p := ReportParams{
+		Name:       args.String("name"),
+		ID:         args.String("id"),
+		PathFileLs: args.String("path_file_ls"),
+		Tree: Tree.Options{
+			Root:     args.String("root"),
+			Depth:    args.Int("depth"),
+			NoBlocks: args.Bool("noblocks"),
+		},
+		Top: args.Int("top"),
+	}
+	if err := checkReport(&p); err != nil {
+		return "", nil, err
+	}
+	path, err := reportPath(p.Name, args.String("path"))
+	if err != nil {
+		return "", nil, err
+	}
 
-	if err := fs.Parse(strings.Fields(params)); err != nil {
-		OutPut.Println("Error al parsear los parámetros:", err)
-		return
+	// Un dry-run no escribe archivos fuera de los discos
+	if Overlay.Active() {
+		out.Printf("Dry-run: se generaría el reporte %s en %s\n", p.Name, path)
+		return "Reporte generado correctamente: " + path, map[string]string{"path": path}, nil
 	}
 
-	if *name == "" {
-		OutPut.Println("Error: el nombre del grupo no puede estar vacío")
-		return
+	if args.Bool("async") {
+		job, err := StartReportJob(p, path)
+		if err != nil {
+			return "", nil, err
+		}
+		out.Printf("Consulte el avance con GET /api/report-jobs/%s\n", job.ID)
+		return fmt.Sprintf("Reporte en cola: trabajo %s", job.ID), job, nil
 	}
 
-	err := UserManager.Rmgrp(*name)
+	// La ruta se confina a la carpeta de reportes, que también crea las carpetas padre
+	file, err := Reports.Resolve(path)
 	if err != nil {
-		OutPut.Println("ADVERTENCIA:", err.Error())
-		return
+		return "", nil, err
 	}
+	report, err := generateReport(context.Background(), out, p, file)
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("Reporte generado correctamente: %s (id %s)", report.Path, report.ID), report, nil
+}
 
-	OutPut.Println("Grupo eliminado correctamente")
+// DiffParams son los parámetros de diff. Con Snapshot la referencia es la
+// instantánea y ID1 es la partición que se compara con ella.
+type DiffParams struct {
+	ID1      string `json:"id1"`
+	ID2      string `json:"id2"`
+	Snapshot string `json:"snapshot"`
+	Path     string `json:"path"`
 }
 
-func fn_mkusr(params string) {
-	fs := flag.NewFlagSet("mkusr", flag.ContinueOnError)
-	user := fs.String("user", "", "Nombre del usuario")
-	pass := fs.String("pass", "", "Contraseña")
-	grp := fs.String("grp", "", "Grupo")
+// CompareFiles compara los archivos de los dos lados de p desde p.Path
+func CompareFiles(ctx context.Context, p DiffParams) (Diff.Result, error) {
+	if (p.ID2 == "") == (p.Snapshot == "") {
+		return Diff.Result{}, Results.Errorf(Results.InvalidParams, "indique -id2 o -snapshot para comparar con -id1")
+	}
+	if err := checkMounted(p.ID1); err != nil {
+		return Diff.Result{}, err
+	}
 
-	if err := fs.Parse(strings.Fields(params)); err != nil {
-		OutPut.Println("Error al parsear los parámetros:", err)
-		return
+	if p.Snapshot != "" {
+		saved, err := Diff.Load(p.Snapshot)
+		if err != nil {
+			return Diff.Result{}, err
+		}
+		// Sin -path se compara desde la carpeta en que se tomó la instantánea
+		if p.Path == "" {
+			p.Path = saved.Root
+		}
+		left, err := saved.Sub(p.Path)
+		if err != nil {
+			return Diff.Result{}, err
+		}
+		right, err := Diff.Take(ctx, p.ID1, p.Path)
+		if err != nil {
+			return Diff.Result{}, err
+		}
+		return Diff.Compare(left, right), nil
 	}
 
-	if *user == "" || *pass == "" || *grp == "" {
-		OutPut.Println("Error: todos los parámetros son obligatorios")
-		return
+	if err := checkMounted(p.ID2); err != nil {
+		return Diff.Result{}, err
 	}
+	left, err := Diff.Take(ctx, p.ID1, p.Path)
+	if err != nil {
+		return Diff.Result{}, err
+	}
+	right, err := Diff.Take(ctx, p.ID2, p.Path)
+	if err != nil {
+		return Diff.Result{}, err
+	}
+	return Diff.Compare(left, right), nil
+}
 
-	if err := UserManager.Mkusr(*user, *pass, *grp); err != nil {
-		OutPut.Println("ADVERTENCIA:", err.Error())
-	} else {
-		OutPut.Println("Usuario creado correctamente.")
+// SaveSnapshot guarda los archivos de la partición id desde path con el nombre indicado
+func SaveSnapshot(ctx context.Context, id string, name string, path string) (Diff.Snapshot, error) {
+	if err := checkMounted(id); err != nil {
+		return Diff.Snapshot{}, err
 	}
+	snapshot, err := Diff.Take(ctx, id, path)
+	if err != nil {
+		return Diff.Snapshot{}, err
+	}
+	return Diff.Save(snapshot, name)
 }
 
-func fn_rmusr(params string) {
-	fs := flag.NewFlagSet("rmusr", flag.ContinueOnError)
-	user := fs.String("user", "", "Nombre del usuario a eliminar")
+func fn_diff(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	p := DiffParams{ID1: args.String("id1"), ID2: args.String("id2"), Snapshot: args.String("snapshot")}
+	// Con -snapshot y sin -path se usa la carpeta de la instantánea
+	if args.Has("path") || p.Snapshot == "" {
+		p.Path = args.String("path")
+	}
+	result, err := CompareFiles(context.Background(), p)
+	if err != nil {
+		return "", nil, err
+	}
+	out.Printf("Comparando %s con %s desde %s\n", result.Left, result.Right, result.Root)
+	for _, change := range result.Changes {
+		out.Println(change.String())
+	}
+	if result.Equal {
+		return "Sin diferencias", result, nil
+	}
+	return fmt.Sprintf("%d diferencias: %d agregados, %d eliminados, %d modificados",
+		len(result.Changes), result.Added, result.Removed, result.Modified), result, nil
+}
 
-	if err := fs.Parse(strings.Fields(params)); err != nil {
-		OutPut.Println("Error al parsear los parámetros:", err)
-		return
+func fn_snapshot(out *OutPut.Output, args *Parser.Args) (string, interface{}, error) {
+	name := args.String("name")
+	// Un dry-run no escribe archivos fuera de los discos
+	if Overlay.Active() {
+		out.Printf("Dry-run: se guardaría la instantánea %s\n", name)
+		return "Instantánea guardada: " + name, nil, nil
+	}
+	snapshot, err := SaveSnapshot(context.Background(), args.String("id"), name, args.String("path"))
+	if err != nil {
+		return "", nil, err
 	}
+	out.Printf("Instantánea %s de la partición %s desde %s: %d entradas\n", name, snapshot.Partition, snapshot.Root, len(snapshot.Entries))
+	snapshot.Entries = nil
+	return "Instantánea guardada: " + name, snapshot, nil
+}
 
-	if *user == "" {
-		OutPut.Println("Error: el nombre del usuario es obligatorio")
-		return
+// reportPath valida la extensión de la ruta del reporte. Los reportes gráficos se
+// dibujan según la extensión; sin extensión son .svg. Los de texto son .txt si no
+// tienen extensión. Los tabulares de exportNames también aceptan .csv y .md.
+func reportPath(name string, path string) (string, error) {
+	if Export.Supported(path) {
+		if !slices.Contains(exportNames, name) {
+			return "", Results.Errorf(Results.InvalidParams, "el reporte %s no se puede exportar a %s; solo %s", name, filepath.Ext(path), strings.Join(exportNames, ", "))
+		}
+		return path, nil
+	}
+	if name == "bm_inode" || name == "bm_block" || name == "file" {
+		if filepath.Ext(path) == "" {
+			path += ".txt"
+		}
+		return path, nil
+	}
+	path = Render.Path(path)
+	if err := Render.Check(path); err != nil {
+		return "", err
 	}
+	return path, nil
+}
 
-	if err := UserManager.Rmusr(*user); err != nil {
-		OutPut.Println("ADVERTENCIA:", err.Error())
-	} else {
-		OutPut.Println("Usuario eliminado correctamente.")
+// generateReport genera el reporte en file, una ruta retornada por Reports.Resolve,
+// y lo registra. Los reportes que recorren toda la partición se detienen si ctx se
+// cancela.
+func generateReport(ctx context.Context, out *OutPut.Output, p ReportParams, file string) (Reports.Report, error) {
+	id := p.ID
+	var reportErr error
+	switch p.Name {
+	case "tree":
+		reportErr = Tree.TreeReport(ctx, id, file, p.Tree)
+	case "mbr":
+		reportErr = fn_reportMBR(out, id, file)
+	case "disk":
+		reportErr = DiskManagement.DiskReport(out, id, file)
+	case "inode":
+		reportErr = DiskManagement.InodeReport(ctx, out, id, file)
+	case "block":
+		reportErr = DiskManagement.BlockReport(ctx, out, id, file)
+	case "bm_inode":
+		reportErr = DiskManagement.BmInodeReport(out, id, file)
+	case "bm_block":
+		reportErr = DiskManagement.BmBlockReport(out, id, file)
+	case "file":
+		reportErr = UserManager.ReportFile(out, id, file, p.PathFileLs)
+	case "ls":
+		reportErr = UserManager.ReportLs(out, id, file, p.PathFileLs)
+	case "usage":
+		reportErr = Usage.UsageReport(ctx, id, file, p.Top)
+	case "sb":
+		reportErr = DiskManagement.SuperBlockReport(out, id, file)
+	}
+	if ctx.Err() != nil {
+		return Reports.Report{}, ctx.Err()
+	}
+	if reportErr != nil {
+		return Reports.Report{}, Results.Errorf(Results.CodeOf(reportErr), "Error al generar el reporte: %v", reportErr)
 	}
+	return Reports.Add(p.Name, id, file)
 }
 
-func fn_mkfile(params string) {
-	fs := flag.NewFlagSet("mkfile", flag.ContinueOnError)
+// StartReportJob valida un reporte y lo encola para generarlo en segundo plano
+// (ver paquete Jobs). path es la ruta pedida dentro de la carpeta de reportes.
+func StartReportJob(p ReportParams, path string) (Jobs.Job, error) {
+	if err := checkReport(&p); err != nil {
+		return Jobs.Job{}, err
+	}
+	path, err := reportPath(p.Name, path)
+	if err != nil {
+		return Jobs.Job{}, err
+	}
+	file, err := Reports.Resolve(path)
+	if err != nil {
+		return Jobs.Job{}, err
+	}
 
-	path := fs.String("path", "", "Ruta del archivo a crear")
-	size := fs.Int("size", 0, "Tamaño del archivo en bytes")
-	cont := fs.String("cont", "", "Ruta a un archivo externo con contenido")
-	createParents := fs.Bool("r", false, "Crear carpetas padre si no existen")
+	info := Jobs.Job{Name: p.Name, Partition: p.ID, Path: path}
+	return Jobs.Start(info, reportKey(p, file), func(ctx context.Context, out *OutPut.Output) (Reports.Report, error) {
+		defer Locks.RLockAll()()
+		return generateReport(ctx, out, p, file)
+	}), nil
+}
 
-	if err := fs.Parse(strings.Fields(params)); err != nil {
-		OutPut.Println("Error al parsear los parámetros:", err)
-		return
+// reportKey identifica un reporte de una partición en un estado dado. Combina el
+// S_mtime del superbloque, que cambia con cada mkfs, con la versión de la caché
+// de la partición, que cambia con cada escritura, porque S_mtime solo se escribe
+// al formatear y se guarda con resolución de minutos. mbr y disk leen el MBR y no
+// la partición, así que no se reutilizan.
+func reportKey(p ReportParams, file string) string {
+	id := p.ID
+	if p.Name == "mbr" || p.Name == "disk" {
+		return ""
 	}
-
-	// Validaciones
-	if *path == "" {
-		OutPut.Println("Error: El parámetro -path es obligatorio")
-		return
+	partitionPath := DiskManagement.GetPartitionPathByID(id)
+	partitionStart := DiskManagement.GetPartitionStartByID(id)
+	if partitionPath == "" || partitionStart < 0 {
+		return ""
 	}
-
-	if *size < 0 {
-		OutPut.Println("Error: El tamaño no puede ser negativo")
-		return
+	unlock := Locks.RLockPartition(partitionPath, id)
+	version := Cache.Version(id)
+	var sb Structs.Superblock
+	f, err := Utilities.OpenFile(partitionPath)
+	if err == nil {
+		err = Utilities.ReadObject(f, &sb, partitionStart)
+		f.Close()
 	}
-
-	if *cont != "" && *size > 0 {
-		OutPut.Println("Advertencia: Se usará el archivo de contenido. El parámetro -size será ignorado.")
+	unlock()
+	if err != nil {
+		return ""
 	}
+	return fmt.Sprintf("%+v|%s|%d|%s|%d|%s", p, partitionPath, partitionStart,
+		strings.TrimRight(string(sb.S_mtime[:]), "\x00"), version, file)
+}
 
-	result := UserManager.Mkfile(*path, *createParents, *size, *cont)
+// AnalyzeCommand ejecuta un comando y retorna su resultado. Los errores además se
+// escriben en la salida para que aparezcan en la consola. Si el comando necesita
+// confirmación, el resultado tiene estado confirm y no se ejecuta nada.
+func AnalyzeCommand(out *OutPut.Output, command string, params string) Results.Result {
+	defer lockCommand(command, params)()
+	return analyzeCommand(out, command, params, false)
+}
 
-	if result != "" {
-		OutPut.Println("ADVERTENCIA:", result)
-	} else {
-		OutPut.Println("Archivo creado correctamente.")
+// AnalyzeConfirmed ejecuta un comando que el usuario ya confirmó, como si tuviera -confirm
+func AnalyzeConfirmed(out *OutPut.Output, command string, params string) Results.Result {
+	defer lockCommand(command, params)()
+	return analyzeCommand(out, command, params, true)
+}
+
+// lockCommand toma el candado global en modo lectura para ejecutar un comando.
+// execute -dryrun no lo toma porque DryRun pide el candado exclusivo.
+func lockCommand(command string, params string) func() {
+	if isDryRunExecute(command, params) {
+		return func() {}
 	}
+	return Locks.RLockAll()
 }
 
-func fn_cat(params string) {
-	// Parse the parameters string to extract fileN parameters
-	matches := re.FindAllStringSubmatch(params, -1)
-	fileParams := make(map[string]string)
+// isDryRunExecute indica si el comando es execute -dryrun
+func isDryRunExecute(command string, params string) bool {
+	cmd, args, err := parseCommand(command, params)
+	return err == nil && cmd.Name == "execute" && args.Bool("dryrun")
+}
 
-	// Convert the parameters to the expected format
-	for _, match := range matches {
-		flagName := match[1]
-		flagValue := strings.Trim(match[2], "\"")
-		if strings.HasPrefix(flagName, "file") {
-			fileParams[flagName] = flagValue
+func analyzeCommand(out *OutPut.Output, command string, params string, confirmed bool) Results.Result {
+	var (
+		message string
+		data    interface{}
+	)
+	cmd, args, err := parseCommand(command, params)
+	if cmd != nil {
+		command = cmd.Name
+	}
+	if err == nil {
+		err = checkAccess(cmd)
+	}
+	if err == nil {
+		// En un dry-run no se pregunta nada: se simula como si el usuario confirmara
+		if confirmed || Overlay.Active() {
+			args.Confirm()
 		}
+		message, data, err = cmd.Run(out, args)
 	}
 
-	// Call the Cat function from UserManager
-	result := UserManager.Cat(fileParams)
+	var result Results.Result
+	switch {
+	case err == nil:
+		result = Results.OK(message, data)
+	case Results.CodeOf(err) == Results.ConfirmationRequired:
+		result = Results.Confirm(err.Error())
+	default:
+		printError(out, err)
+		result = Results.Fail(err)
+	}
+	result.Command = command
+	return result
+}
 
-	// Print the result
-	OutPut.Println(result)
+// ExecuteScript ejecuta una secuencia de comandos desde un string multilinea.
+// Se detiene en un pause o cuando un comando pide confirmación.
+func ExecuteScript(out *OutPut.Output, script string) ScriptResult {
+	steps, err := PrepareScript(script)
+	if err != nil {
+		printError(out, err)
+		return ScriptResult{}
+	}
+	return ExecuteLines(context.Background(), out, steps, 0, false, nil)
 }
 
-func fn_mkdir(params string) {
-	fs := flag.NewFlagSet("mkdir", flag.ContinueOnError)
-	path := fs.String("path", "", "Path of the directory to create")
-	createParents := fs.Bool("r", false, "Create parent directories if they don't exist")
+// PrepareScript resuelve las variables, los include y los bloques if-exists de un
+// script (ver Preprocessor) antes de ejecutarlo
+func PrepareScript(script string) ([]Preprocessor.Step, error) {
+	steps, err := Preprocessor.Expand(script)
+	if err != nil {
+		return nil, Results.Errorf(Results.InvalidParams, "el script tiene errores: %v", err)
+	}
+	return steps, nil
+}
 
-	// Parse parameters
-	managementFlags(fs, params)
+// ExecuteLines ejecuta los pasos de un script desde el índice start. Si confirmed es
+// verdadero, el primer paso se ejecuta como confirmado porque el usuario ya respondió
+// que sí. Al detenerse, Next es el índice del paso con el que se debe continuar.
+// onLine, si no es nil, se llama después de cada comando ejecutado. Si ctx se cancela,
+// el script se detiene antes del siguiente paso.
+func ExecuteLines(ctx context.Context, out *OutPut.Output, steps []Preprocessor.Step, start int, confirmed bool, onLine func(LineResult)) ScriptResult {
+	var res ScriptResult
+	res.Next = len(steps)
+
+	for i := start; i < len(steps); {
+		step := steps[i]
+		if step.Kind == Preprocessor.Comment {
+			i++
+			continue
+		}
 
-	if *path == "" {
-		OutPut.Println("Error: El parámetro -path es obligatorio")
-		return
+		if ctx.Err() != nil {
+			out.Warningf("Advertencia: Script cancelado antes de la %s\n", location(step.File, step.Line))
+			res.Cancelled = true
+			res.stop(step, i)
+			return res
+		}
+
+		if step.Kind != Preprocessor.Command {
+			unlock := Locks.RLockAll()
+			i = nextStep(out, steps, i)
+			unlock()
+			continue
+		}
+
+		command, params := step.Params()
+
+		// Si el comando es "pause", detener la ejecución
+		if command == "pause" {
+			res.Results = append(res.Results, fmt.Sprintf(">> %s\n", step.Text))
+			res.Results = append(res.Results, "Presione continuar para seguir...")
+			res.Paused = true
+			res.stop(step, i+1)
+			return res
+		}
+
+		before := out.Len()
+		unlock := lockCommand(command, params)
+		result := analyzeCommand(out, command, params, confirmed && i == start)
+		unlock()
+		res.Results = append(res.Results, fmt.Sprintf(">> %s\n", step.Text))
+		res.Commands = append(res.Commands, result)
+		if onLine != nil {
+			onLine(LineResult{File: step.File, Line: step.Line, Text: step.Text, Result: result, Output: out.Since(before)})
+		}
+		if result.Status == Results.StatusConfirm {
+			// La línea queda pendiente hasta que el usuario la confirme
+			res.Confirm = true
+			res.ConfirmMessage = result.Message
+			res.stop(step, i)
+			return res
+		}
+		if result.Failed() && step.OnError == Preprocessor.Stop {
+			res.stopOnError(out, step)
+			return res
+		}
+		i++
 	}
 
-	// Call Mkdir from UserManager
-	result := UserManager.Mkdir(*path, *createParents)
-	OutPut.Println(result)
+	return res
 }
 
-func fn_reportMBR(params string) {
-	fs := flag.NewFlagSet("rep", flag.ExitOnError)
-	name := fs.String("name", "", "Nombre del reporte")
-	path := fs.String("path", "", "Ruta del archivo de salida")
-	id := fs.String("id", "", "ID de la partición montada")
-	managementFlags(fs, params)
+// stop registra el paso donde se detuvo el script y el índice con el que continúa
+func (res *ScriptResult) stop(step Preprocessor.Step, next int) {
+	res.File = step.File
+	res.Line = step.Line
+	res.Next = next
+}
 
-	// Validaciones básicas
-	if strings.ToLower(*name) != "mbr" {
-		OutPut.Println("Error: Este handler solo soporta el reporte 'mbr'")
-		return
+// stopOnError termina el script porque un comando falló bajo on-error -action=stop
+func (res *ScriptResult) stopOnError(out *OutPut.Output, step Preprocessor.Step) {
+	out.Warningf("Advertencia: el script se detuvo porque falló el comando de la %s (on-error stop)\n", location(step.File, step.Line))
+	res.Stopped = true
+	res.File = step.File
+	res.Line = step.Line
+}
+
+// location describe una línea de un script para los mensajes
+func location(file string, line int) string {
+	if file == "" {
+		return fmt.Sprintf("línea %d", line)
 	}
-	if *path == "" || *id == "" {
-		OutPut.Println("Error: Debe especificar -path y -id")
-		return
+	return fmt.Sprintf("línea %d de %s", line, filepath.Base(file))
+}
+
+// nextStep evalúa un if-exists, else o end y retorna el índice del paso siguiente.
+// Un if-exists falso salta después de su else o de su end; un else alcanzado desde
+// su bloque verdadero salta después de su end.
+func nextStep(out *OutPut.Output, steps []Preprocessor.Step, i int) int {
+	step := steps[i]
+	switch step.Kind {
+	case Preprocessor.IfExists:
+		if conditionHolds(out, step) {
+			return i + 1
+		}
+		return step.Jump + 1
+	case Preprocessor.Else:
+		return step.Jump + 1
 	}
+	return i + 1
+}
 
-	// Buscar la partición montada con ese ID
-	partition, diskPath, err := stores.GetMountedPartition(*id)
+// conditionHolds indica si existen el disco (-driveletter), la partición
+// (-driveletter y -name) y el ID montado (-id) de un if-exists
+func conditionHolds(out *OutPut.Output, step Preprocessor.Step) bool {
+	args, err := step.Condition()
 	if err != nil {
-		OutPut.Println("Error:", err)
-		return
+		printError(out, err)
+		return false
 	}
-	if partition == nil {
-		OutPut.Println("Error: No se encontró la partición con ID", *id)
-		return
+
+	holds := true
+	if args.Has("driveletter") {
+		letter := strings.ToUpper(args.String("driveletter"))
+		diskPath := fmt.Sprintf("./tets/%s.dsk", letter)
+		if _, err := Overlay.Stat(diskPath); err != nil {
+			holds = false
+		} else if args.Has("name") {
+			holds = hasPartition(diskPath, args.String("name"))
+		}
 	}
+	if holds && args.Has("id") {
+		holds = isMounted(args.String("id"))
+	}
+
+	if holds {
+		out.Printf("%s: se cumple\n", step.Text)
+	} else {
+		out.Printf("%s: no se cumple, se omite el bloque\n", step.Text)
+	}
+	return holds
+}
 
-	// Abrir archivo y leer MBR
-	file, err := Utilities.OpenFile(diskPath)
+// hasPartition indica si el MBR del disco tiene una partición con ese nombre
+func hasPartition(diskPath string, name string) bool {
+	mbr, err := stores.LoadMBR(diskPath)
 	if err != nil {
-		OutPut.Println("Error al abrir el disco:", err)
-		return
+		return false
+	}
+	for _, p := range mbr.Partitions {
+		if p.Size != 0 && strings.EqualFold(strings.Trim(string(p.Name[:]), "\x00"), name) {
+			return true
+		}
 	}
-	defer file.Close()
+	return false
+}
 
-	var mbr Structs.MRB
-	if err := Utilities.ReadObject(file, &mbr, 0); err != nil {
-		OutPut.Println("Error al leer el MBR:", err)
-		return
+// isMounted indica si hay una partición montada con ese ID
+func isMounted(id string) bool {
+	for _, partitions := range DiskManagement.GetMountedPartitions() {
+		for _, p := range partitions {
+			if strings.EqualFold(p.ID, id) {
+				return true
+			}
+		}
 	}
+	return false
+}
 
-	// Llamar a la función que genera el reporte
-	if err := DiskManagement.ReportMBR(&mbr, *path); err != nil {
-		OutPut.Println("Error al generar el reporte MBR:", err)
+// DryRun ejecuta fn sobre copias de los discos y retorna los cambios que haría.
+// Toma el candado global de forma exclusiva, así que los demás comandos esperan a
+// que termine y nunca ven las copias. Las particiones montadas, el contador de
+// discos, la sesión y las cachés se restauran al terminar.
+func DryRun(fn func()) ([]Overlay.Change, error) {
+	defer Locks.LockAll()()
+	defer UserManager.SaveSession()()
+	defer DiskManagement.SaveState()()
+
+	restoreCache, err := Cache.Isolate()
+	if err != nil {
+		return nil, Results.Errorf(Results.IOError, "error al guardar las particiones antes del dry-run: %v", err)
+	}
+	o, err := Overlay.Begin(Overlay.DryRun)
+	if err != nil {
+		restoreCache()
+		return nil, Results.Errorf(Results.IOError, "%v", err)
 	}
+	defer o.End()
+
+	func() {
+		defer func() {
+			err = restoreCache()
+		}()
+		fn()
+	}()
+	if err != nil {
+		return nil, Results.Errorf(Results.IOError, "error al bajar los cambios del dry-run: %v", err)
+	}
+	return o.Changes(), nil
 }
 
-func generarReportes(params string) string {
-	//se crea un nuevo conjunto de flags
-	fs := flag.NewFlagSet("rep", flag.ContinueOnError)
+// AnalyzeDryRun simula un comando y retorna su resultado con los cambios que haría
+func AnalyzeDryRun(out *OutPut.Output, command string, params string) (Results.Result, []Overlay.Change) {
+	var result Results.Result
+	changes, err := DryRun(func() {
+		result = analyzeCommand(out, command, params, true)
+	})
+	if err != nil {
+		printError(out, err)
+		result = Results.Fail(err)
+		result.Command = command
+		return result, nil
+	}
+	printChanges(out, changes)
+	return result, changes
+}
 
-	//parametros
-	name := fs.String("name", "", "Nombre del reporte")
-	path := fs.String("path", "", "Ruta del reporte")
-	id := fs.String("id", "", "ID de la partición")
-	path_file_ls := fs.String("path_file_ls", "", "Ruta del archivo de salida para el reporte file y ls")
+// DryRunScript simula un script completo. Los pause se omiten y los comandos que
+// piden confirmación se simulan como confirmados, así que nunca se detiene a preguntar.
+func DryRunScript(ctx context.Context, out *OutPut.Output, steps []Preprocessor.Step, onLine func(LineResult)) ScriptResult {
+	res := ScriptResult{DryRun: true}
+	res.Next = len(steps)
 
-	//params = strings.ToLower(params)
+	changes, err := DryRun(func() {
+		runAll(ctx, out, steps, &res, runOptions{onLine: onLine})
+	})
+	if err != nil {
+		printError(out, err)
+		return res
+	}
+	printChanges(out, changes)
+	res.Changes = changes
+	return res
+}
 
-	//parsear los parametros
-	args := strings.Split(params, " ")
-	err := fs.Parse(args)
+// Transaction ejecuta fn guardando una copia de cada disco antes de modificarlo.
+// Si fn retorna falso se restauran los discos, las particiones montadas y la
+// sesión, y se descartan las cachés. Toma el candado global de forma exclusiva
+// para que ningún otro comando vea ni toque los discos a mitad de la transacción.
+func Transaction(fn func() bool) (committed bool, err error) {
+	defer Locks.LockAll()()
+	// Las cachés abiertas escriben en los discos sin pasar por el overlay: se cierran
+	// para que cada partición se vuelva a abrir con Overlay.Path y quede su copia
+	if err := Cache.CloseAll(); err != nil {
+		return false, Results.Errorf(Results.IOError, "error al guardar las particiones antes de la transacción: %v", err)
+	}
+	restoreSession := UserManager.SaveSession()
+	restoreDisks := DiskManagement.SaveState()
+	o, err := Overlay.Begin(Overlay.Snapshot)
 	if err != nil {
-		OutPut.Println("Error al parsear los parámetros:", err)
-		return "Error al parsear los parámetros:"
+		return false, Results.Errorf(Results.IOError, "%v", err)
 	}
+	defer o.End()
 
-	// Validar que los parámetros obligatorios estén presentes
-	if *name == "" || *path == "" || *id == "" {
-		OutPut.Println("Error: Los parámetros -name, -path e -id son obligatorios")
-		return "Error: Los parámetros -name, -path e -id son obligatorios"
+	// El rollback también corre si fn entra en pánico
+	defer func() {
+		if committed {
+			return
+		}
+		Cache.DiscardAll()
+		restoreDisks()
+		restoreSession()
+		if rollbackErr := o.Rollback(); rollbackErr != nil && err == nil {
+			err = Results.Errorf(Results.IOError, "%v", rollbackErr)
+		}
+	}()
+
+	if !fn() {
+		return false, nil
+	}
+	if err := Cache.FlushAll(); err != nil {
+		return false, Results.Errorf(Results.IOError, "error al guardar los cambios de la transacción: %v", err)
 	}
+	return true, nil
+}
 
-	// Validar que el nombre del reporte sea uno de los valores permitidos
-	validNames := map[string]bool{
-		"mbr": true, "disk": true, "inode": true, "block": true,
-		"bm_inode": true, "bm_block": true, "tree": true, "sb": true,
-		"file": true, "ls": true,
+// ExecuteTransaction ejecuta un script completo como una transacción: se detiene en
+// el primer comando que falla y deshace todo lo que hizo el script. Los pause se
+// omiten y un comando que pide confirmación cuenta como fallido, porque la
+// transacción no puede quedar abierta esperando al usuario. Cancelar el script
+// también deshace sus cambios.
+func ExecuteTransaction(ctx context.Context, out *OutPut.Output, steps []Preprocessor.Step, onLine func(LineResult)) ScriptResult {
+	res := ScriptResult{Transactional: true}
+	res.Next = len(steps)
+
+	committed, err := Transaction(func() bool {
+		return runAll(ctx, out, steps, &res, runOptions{stopOnError: true, onLine: onLine})
+	})
+	if err != nil {
+		printError(out, err)
+	}
+	if !committed {
+		res.RolledBack = true
+		out.Warningf("Advertencia: el script se detuvo en la %s; se deshicieron todos sus cambios\n", location(res.File, res.Line))
+		return res
 	}
+	out.Println("Script transaccional completado")
+	return res
+}
 
-	if !validNames[*name] {
+// runOptions ajusta cómo runAll ejecuta un script
+type runOptions struct {
+	stopOnError bool             // detenerse en el primer comando que no termina bien (transacciones)
+	echo        bool             // mostrar en la consola los comentarios y cada comando (execute)
+	onLine      func(LineResult) // se llama después de cada comando
+}
 
-		return "Error: El valor de -name debe ser uno de los siguientes: mbr, disk, inode, block, bm_inode, bm_block, tree, sb, file, ls"
+// runAll ejecuta todos los pasos de un script sin detenerse a preguntar; los pause
+// se omiten. Se detiene en un comando que falla bajo on-error stop y, con
+// stopOnError, en cualquier comando que falla o pide confirmación. Retorna falso
+// si el script no llegó al final.
+func runAll(ctx context.Context, out *OutPut.Output, steps []Preprocessor.Step, res *ScriptResult, opts runOptions) bool {
+	for i := 0; i < len(steps); {
+		step := steps[i]
+		if step.Kind == Preprocessor.Comment {
+			if opts.echo {
+				out.Println(step.Text)
+			}
+			i++
+			continue
+		}
+		if ctx.Err() != nil {
+			out.Warningf("Advertencia: Script cancelado antes de la %s\n", location(step.File, step.Line))
+			res.Cancelled = true
+			res.stop(step, i)
+			return false
+		}
+		if step.Kind != Preprocessor.Command {
+			i = nextStep(out, steps, i)
+			continue
+		}
+
+		command, params := step.Params()
+		res.Results = append(res.Results, fmt.Sprintf(">> %s\n", step.Text))
+		if opts.echo {
+			out.Printf(">> %s\n", step.Text)
+		}
+		if command == "pause" {
+			out.Printf("Se omite la pausa de la %s\n", location(step.File, step.Line))
+			i++
+			continue
+		}
+
+		before := out.Len()
+		var result Results.Result
+		if !Overlay.InProgress() && isDryRunExecute(command, params) {
+			// runAll corre con el candado global tomado y DryRun lo pediría de forma
+			// exclusiva; dentro de un dry-run o una transacción lo resuelve fn_execute
+			err := Results.Errorf(Results.InvalidParams, "execute -dryrun no se puede usar dentro de un script en ejecución; simule el script completo con -dryrun")
+			printError(out, err)
+			result = Results.Fail(err)
+			result.Command = command
+		} else {
+			result = analyzeCommand(out, command, params, false)
+		}
+		if result.Status == Results.StatusConfirm {
+			if opts.stopOnError {
+				out.Error("Error:", result.Message, "En un script transaccional use -confirm o -force.")
+			} else {
+				out.Warning("Advertencia:", result.Message, "Se omitió el comando; use -confirm o -force para ejecutarlo.")
+			}
+		}
+		res.Commands = append(res.Commands, result)
+		if opts.onLine != nil {
+			opts.onLine(LineResult{File: step.File, Line: step.Line, Text: step.Text, Result: result, Output: out.Since(before)})
+		}
+		if opts.stopOnError && result.Status != Results.StatusOK {
+			res.stop(step, i)
+			return false
+		}
+		if result.Failed() && step.OnError == Preprocessor.Stop {
+			res.stopOnError(out, step)
+			return false
+		}
+		i++
 	}
+	return true
+}
 
-	// Para reportes file y ls, validar que el parámetro path_file_ls esté presente
-	if (*name == "file" || *name == "ls") && *path_file_ls == "" {
-		OutPut.Println("Error: Para reportes file y ls, el parámetro -path_file_ls es obligatorio")
-		return "Error: Para reportes file y ls, el parámetro -path_file_ls es obligatorio"
+// printChanges muestra en la consola el resumen de un dry-run
+func printChanges(out *OutPut.Output, changes []Overlay.Change) {
+	if len(changes) == 0 {
+		out.Println("Dry-run: no se modificaría ningún disco")
+		return
+	}
+	out.Printf("Dry-run: se harían %d cambios (ningún disco fue modificado)\n", len(changes))
+	for _, change := range changes {
+		out.Println("  -", change.Message)
 	}
+}
 
-	// Verificar que la partición con el ID especificado esté montada
-	foundPartition := false
+// Diagnostic es un problema encontrado al validar un script sin ejecutarlo
+type Diagnostic struct {
+	File     string       `json:"file,omitempty"` // archivo incluido; vacío para el script principal
+	Line     int          `json:"line"`
+	Command  string       `json:"command,omitempty"`
+	Severity OutPut.Level `json:"severity"`
+	Code     Results.Code `json:"code"`
+	Message  string       `json:"message"`
+}
 
-	for _, partitions := range DiskManagement.GetMountedPartitions() {
-		for _, partition := range partitions {
-			if partition.ID == *id {
-				foundPartition = true
-				break
+// simDisk es el estado simulado de un disco durante la validación
+type simDisk struct {
+	partitions map[string]string // nombre en mayúsculas → id de montaje ("" si no está montada)
+}
+
+// validator simula los discos, particiones e IDs que existirán en cada línea del script
+type validator struct {
+	disks       map[string]*simDisk
+	ids         map[string]string // id montado → letra del disco
+	nextLetter  rune
+	user        string // sesión simulada
+	loggedIn    bool
+	file        string // archivo del paso que se está revisando
+	diagnostics []Diagnostic
+}
+
+// ValidateScript revisa un script sin ejecutarlo. Usa los mismos comandos que
+// AnalyzeCommand y simula mkdisk, fdisk, mount, unmount, rmdisk, login y logout para
+// detectar referencias a discos o IDs que no existirán al llegar a esa línea y
+// comandos que necesitarán una sesión que no habrá. Los if-exists
+// se evalúan contra el estado simulado, así que solo se revisa el bloque que se
+// ejecutaría.
+func ValidateScript(script string) []Diagnostic {
+	v := newValidator()
+	steps, err := Preprocessor.Expand(script)
+	var problems Preprocessor.Errors
+	if errors.As(err, &problems) {
+		for _, problem := range problems {
+			v.file = problem.File
+			v.report(problem.Line, "", OutPut.Error, Results.InvalidParams, "%s", problem.Message)
+		}
+	}
+	// Con errores de preparación los saltos de los if-exists pueden no ser válidos,
+	// así que los bloques se recorren completos
+	followJumps := err == nil
+
+	for i := 0; i < len(steps); {
+		step := steps[i]
+		v.file = step.File
+		switch step.Kind {
+		case Preprocessor.Command:
+			command, params := step.Params()
+			if cmd, args, err := parseCommand(command, params); err != nil {
+				v.report(step.Line, command, OutPut.Error, Results.CodeOf(err), "%s", err.Error())
+			} else if err := v.access(cmd); err != nil {
+				v.report(step.Line, cmd.Name, OutPut.Error, Results.CodeOf(err), "%s", err.Error())
+			} else {
+				v.check(step.Line, cmd.Name, args)
+			}
+		case Preprocessor.IfExists:
+			if followJumps && !v.exists(step) {
+				i = step.Jump + 1
+				continue
+			}
+		case Preprocessor.Else:
+			if followJumps {
+				i = step.Jump + 1
+				continue
 			}
 		}
-		if foundPartition {
-			break
+		i++
+	}
+	return v.diagnostics
+}
+
+// exists evalúa un if-exists contra los discos e IDs simulados
+func (v *validator) exists(step Preprocessor.Step) bool {
+	args, err := step.Condition()
+	if err != nil {
+		return false
+	}
+	if args.Has("driveletter") {
+		d, ok := v.disks[strings.ToUpper(args.String("driveletter"))]
+		if !ok {
+			return false
+		}
+		if args.Has("name") {
+			if _, ok := d.partitions[strings.ToUpper(args.String("name"))]; !ok {
+				return false
+			}
+		}
+	}
+	if args.Has("id") {
+		if _, ok := v.ids[strings.ToUpper(args.String("id"))]; !ok {
+			return false
 		}
 	}
+	return true
+}
 
-	if !foundPartition {
-		OutPut.Println("Error: No se encontró ninguna partición montada con el ID", *id)
-		return "Error: No se encontró ninguna partición montada con el ID" + *id
+// newValidator carga el estado actual de los discos en ./tets
+func newValidator() *validator {
+	v := &validator{
+		disks:      make(map[string]*simDisk),
+		ids:        make(map[string]string),
+		nextLetter: rune(DiskManagement.NextDiskLetter()[0]),
 	}
+	v.user, v.loggedIn = UserManager.CurrentUser()
+	files, _ := os.ReadDir("./tets")
+	for _, file := range files {
+		if filepath.Ext(file.Name()) != ".dsk" {
+			continue
+		}
+		letter := strings.ToUpper(strings.TrimSuffix(file.Name(), ".dsk"))
+		disk := &simDisk{partitions: make(map[string]string)}
+		v.disks[letter] = disk
+		mbr, err := stores.LoadMBR(filepath.Join("./tets", file.Name()))
+		if err != nil {
+			continue
+		}
+		for _, p := range mbr.Partitions {
+			if p.Size == 0 {
+				continue
+			}
+			name := strings.ToUpper(strings.Trim(string(p.Name[:]), "\x00"))
+			id := strings.Trim(string(p.Id[:]), "\x00")
+			disk.partitions[name] = id
+			if id != "" {
+				v.ids[id] = letter
+			}
+		}
+	}
+	return v
+}
+
+func (v *validator) report(line int, command string, severity OutPut.Level, code Results.Code, format string, a ...any) {
+	v.diagnostics = append(v.diagnostics, Diagnostic{
+		File:     v.file,
+		Line:     line,
+		Command:  command,
+		Severity: severity,
+		Code:     code,
+		Message:  fmt.Sprintf(format, a...),
+	})
+}
 
-	// Crear la carpeta de destino si no existe
-	dirPath := filepath.Dir(*path)
-	if err := os.MkdirAll(dirPath, os.ModePerm); err != nil {
-		OutPut.Println("Error al crear la carpeta de destino:", err)
-		return "Error al crear la carpeta de destino:"
+// disk retorna el disco simulado o reporta que no existirá en esa línea
+func (v *validator) disk(line int, command string, letter string) *simDisk {
+	letter = strings.ToUpper(letter)
+	d, ok := v.disks[letter]
+	if !ok {
+		v.report(line, command, OutPut.Error, Results.NotFound, "el disco %s no existe en este punto del script", letter)
 	}
+	return d
+}
 
-	switch *name {
-	case "tree":
-		Tree.TreeReport(*id, *path)
-	case "mbr":
-		fn_reportMBR(params)
-	case "disk":
-		DiskManagement.DiskReport(*id, *path)
-	case "inode":
-		DiskManagement.InodeReport(*id, *path)
-	case "block":
-		DiskManagement.BlockReport(*id, *path)
-	case "bm_inode":
-		DiskManagement.BmInodeReport(*id, *path)
-	case "bm_block":
-		DiskManagement.BmBlockReport(*id, *path)
-	case "file":
-		UserManager.ReportFile(*id, *path, *path_file_ls)
-	case "ls":
-		UserManager.ReportLs(*id, *path, *path_file_ls)
-	case "sb":
-		DiskManagement.SuperBlockReport(*id, *path)
-	default:
-		OutPut.Println("Error: El nombre del reporte no es válido")
+// access revisa el comando contra la sesión simulada, igual que checkAccess
+func (v *validator) access(cmd *Commands.Command) error {
+	if cmd.Access == Commands.Anyone {
+		return nil
+	}
+	if !v.loggedIn {
+		return Results.Errorf(Results.NotLoggedIn, "%s necesita iniciar sesión y no habrá una sesión en este punto del script", cmd.Name)
+	}
+	if cmd.Access == Commands.Root && v.user != "root" {
+		return Results.Errorf(Results.PermissionDenied, "solo el usuario root puede ejecutar %s y la sesión será de %s", cmd.Name, v.user)
+	}
+	return nil
+}
+
+// mounted reporta si el ID no estará montado en esa línea
+func (v *validator) mounted(line int, command string, id string) {
+	if _, ok := v.ids[strings.ToUpper(id)]; !ok {
+		v.report(line, command, OutPut.Error, Results.NotFound, "la partición %s no está montada en este punto del script", id)
 	}
-	return "Reporte generado correctamente: " + *path
 }
 
-func AnalyzeCommand(command string, params string) string {
+func (v *validator) check(line int, command string, args *Parser.Args) {
 	switch command {
 	case "mkdisk":
-		fn_mkdisk(params)
-		return "Disco creado correctamente"
+		if v.nextLetter > 'Z' {
+			v.report(line, command, OutPut.Error, Results.NoSpace, "no quedan letras disponibles para un disco nuevo")
+			return
+		}
+		v.disks[string(v.nextLetter)] = &simDisk{partitions: make(map[string]string)}
+		v.nextLetter++
 	case "fdisk":
-		fn_fdisk(params)
-		return "Partición gestionada correctamente"
+		d := v.disk(line, command, args.String("driveletter"))
+		if d == nil {
+			return
+		}
+		name := strings.ToUpper(args.String("name"))
+		_, exists := d.partitions[name]
+		switch {
+		case args.Has("delete"):
+			if !exists {
+				v.report(line, command, OutPut.Error, Results.NotFound, "la partición %s no existe en el disco %s", args.String("name"), strings.ToUpper(args.String("driveletter")))
+				return
+			}
+			if id := d.partitions[name]; id != "" {
+				delete(v.ids, id)
+			}
+			delete(d.partitions, name)
+		case args.Int("add") != 0:
+			if !exists {
+				v.report(line, command, OutPut.Error, Results.NotFound, "la partición %s no existe en el disco %s", args.String("name"), strings.ToUpper(args.String("driveletter")))
+			}
+		default:
+			if args.Int("size") <= 0 {
+				v.report(line, command, OutPut.Error, Results.InvalidParams, "-size debe ser mayor que 0")
+				return
+			}
+			if exists {
+				v.report(line, command, OutPut.Error, Results.AlreadyExists, "la partición %s ya existe en el disco %s", args.String("name"), strings.ToUpper(args.String("driveletter")))
+				return
+			}
+			d.partitions[name] = ""
+		}
 	case "mount":
-		fn_mount(params)
-		return "Partición montada correctamente"
-	case "rmdisk":
-		return fn_rmdisk(params)
-	case "pause":
-		fn_pause()
-		return "Pausa completada"
-	case "mkfs":
-		fn_mkfs(params)
-		return "Sistema de archivos creado correctamente"
-	case "listmount":
-		stores.ListMountedPartitions()
-		return "Particiones montadas listadas"
+		letter := strings.ToUpper(args.String("driveletter"))
+		d := v.disk(line, command, letter)
+		if d == nil {
+			return
+		}
+		name := strings.ToUpper(args.String("name"))
+		id, exists := d.partitions[name]
+		if !exists {
+			v.report(line, command, OutPut.Error, Results.NotFound, "la partición %s no existe en el disco %s", args.String("name"), letter)
+			return
+		}
+		if id != "" {
+			v.report(line, command, OutPut.Error, Results.AlreadyExists, "la partición %s ya está montada con el ID %s", args.String("name"), id)
+			return
+		}
+		// Mismo formato que DiskManagement.Mount: letra + correlativo + carnet
+		count := 1
+		for _, mountedID := range d.partitions {
+			if mountedID != "" {
+				count++
+			}
+		}
+		id = fmt.Sprintf("%s%d%s", letter, count, stores.Carnet)
+		d.partitions[name] = id
+		v.ids[id] = letter
 	case "unmount":
-		fn_unmount(params)
-		return "Partición desmontada correctamente"
-	case "login":
-		fn_login(params)
-		return "Sesión iniciada"
-	case "logout":
-		fn_logout(params)
-		return "Sesión cerrada"
-	case "mkgrp":
-		return fn_mkgrp(params)
-	case "rmgrp":
-		fn_rmgrp(params)
-		return "Grupo eliminado correctamente"
-	case "mkusr":
-		fn_mkusr(params)
-		return "Usuario creado correctamente"
-	case "rmusr":
-		fn_rmusr(params)
-		return "Usuario eliminado correctamente"
-	case "mkfile":
-		fn_mkfile(params)
-		return "Archivo creado correctamente"
-	case "cat":
-		fn_cat(params)
-		return "Comando cat ejecutado"
-	case "mkdir":
-		fn_mkdir(params)
-		return "Directorio creado correctamente"
-	case "rep":
-		return generarReportes(params)
-	case "execute":
-		fn_execute(params)
-		return "Script ejecutado"
-	case "exit":
-		OutPut.Println("Exiting the program.")
-		os.Exit(0)
-		return "Saliendo del programa"
-	default:
-		return "Error: comando no reconocido."
-	}
-}
-
-// ExecuteScript ejecuta una secuencia de comandos desde un string multilinea.
-// Retorna los resultados, si está pausado, las líneas restantes del script, si requiere confirmación y el mensaje de confirmación.
-func ExecuteScript(script string) ([]string, bool, []string, bool, string) {
-	var results []string
-	var remainingScriptLines []string
-	lines := strings.Split(script, "\n")
-
-	for i, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
-			continue
+		id := strings.ToUpper(args.String("id"))
+		letter, ok := v.ids[id]
+		if !ok {
+			v.mounted(line, command, id)
+			return
 		}
-
-		command, params := GetCommandAndParams(trimmed)
-
-		// Si el comando es "pause", detener la ejecución
-		if command == "pause" {
-			results = append(results, fmt.Sprintf(">> %s\n", trimmed))
-			results = append(results, "Presione continuar para seguir...")
-
-			// Guardar las líneas restantes del script
-			for j := i + 1; j < len(lines); j++ {
-				remainingLine := strings.TrimSpace(lines[j])
-				if remainingLine != "" && !strings.HasPrefix(remainingLine, "#") {
-					remainingScriptLines = append(remainingScriptLines, lines[j])
-				}
+		delete(v.ids, id)
+		for name, mountedID := range v.disks[letter].partitions {
+			if mountedID == id {
+				v.disks[letter].partitions[name] = ""
 			}
-
-			fmt.Println("DEBUG: Pausa detectada en ExecuteScript")
-			return results, true, remainingScriptLines, false, ""
 		}
-
-		result := AnalyzeCommand(command, params)
-		if strings.HasPrefix(result, "CONFIRM_RMDISK:") {
-			results = append(results, fmt.Sprintf(">> %s\n", trimmed))
-			// Guardar las líneas restantes del script
-			for j := i + 1; j < len(lines); j++ {
-				remainingLine := strings.TrimSpace(lines[j])
-				if remainingLine != "" && !strings.HasPrefix(remainingLine, "#") {
-					remainingScriptLines = append(remainingScriptLines, lines[j])
-				}
+	case "rmdisk":
+		letter := strings.ToUpper(args.String("driveletter"))
+		if v.disk(line, command, letter) == nil {
+			return
+		}
+		for id, diskLetter := range v.ids {
+			if diskLetter == letter {
+				delete(v.ids, id)
 			}
-			fmt.Println("DEBUG: Confirmación de rmdisk detectada en ExecuteScript")
-			return results, false, remainingScriptLines, true, result
 		}
-
-		results = append(results, fmt.Sprintf(">> %s\n", trimmed))
+		delete(v.disks, letter)
+	case "login":
+		if v.loggedIn {
+			v.report(line, command, OutPut.Error, Results.AlreadyExists, "ya hay una sesión iniciada en este punto del script")
+			return
+		}
+		if _, ok := v.ids[strings.ToUpper(args.String("id"))]; !ok {
+			v.mounted(line, command, args.String("id"))
+			return
+		}
+		v.user, v.loggedIn = args.String("user"), true
+	case "logout":
+		v.user, v.loggedIn = "", false
+	case "mkfs", "rep":
+		v.mounted(line, command, args.String("id"))
+	case "execute":
+		// Igual que fn_execute: las barras invertidas se convierten y solo se aceptan .sdaa
+		path := strings.ReplaceAll(args.String("path"), "\\", "/")
+		if !strings.HasSuffix(strings.ToLower(path), ".sdaa") {
+			v.report(line, command, OutPut.Error, Results.InvalidParams, "el archivo debe tener la extensión .sdaa")
+			return
+		}
+		if _, err := os.Stat(path); err != nil {
+			v.report(line, command, OutPut.Warning, Results.NotFound, "no se encontró el script %s", args.String("path"))
+		}
 	}
-
-	return results, false, nil, false, ""
 }
 
-// ExecuteScriptFromFile ejecuta un archivo .sdaa dado por el path.
-func ExecuteScriptFromFile(param string) string {
-	// Extraer -path=
-	if !strings.Contains(param, "-path=") {
-		return "Error: Falta el parámetro -path"
+// ExecuteScriptFromFile ejecuta un archivo .sdaa dado por el path. Los include del
+// script se buscan relativos a la carpeta del archivo.
+func ExecuteScriptFromFile(out *OutPut.Output, param string) string {
+	_, args, err := parseCommand("execute", param)
+	if err != nil {
+		return "Error: " + err.Error()
 	}
-	path := strings.Trim(strings.Split(param, "=")[1], "\" ")
+	path := args.String("path")
 	if !strings.HasSuffix(strings.ToLower(path), ".sdaa") {
 		return "Error: el archivo debe tener la extensión .sdaa"
 	}
-	content, err := os.ReadFile(path)
+	steps, err := Preprocessor.ExpandFile(path)
 	if err != nil {
+		var problems Preprocessor.Errors
+		if errors.As(err, &problems) {
+			return fmt.Sprintf("Error: el script tiene errores: %v", err)
+		}
 		return fmt.Sprintf("Error al leer el archivo: %v", err)
 	}
-	resultLines, _, _, _, _ := ExecuteScript(string(content))
-	return strings.Join(resultLines, "\n")
+	if args.Bool("dryrun") {
+		return strings.Join(DryRunScript(context.Background(), out, steps, nil).Results, "\n")
+	}
+	return strings.Join(ExecuteLines(context.Background(), out, steps, 0, false, nil).Results, "\n")
 }
@@ -0,0 +1,490 @@
+package Cache
+
+import (
+	"MIA_P1/Structs"
+	"MIA_P1/Utilities"
+	"bytes"
+	"container/list"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Cantidad máxima de inodos y bloques que se mantienen en memoria por partición
+const (
+	maxInodes = 256
+	maxBlocks = 1024
+)
+
+var (
+	inodeSize = int64(binary.Size(Structs.Inode{}))
+	blockSize = int64(binary.Size(Structs.Fileblock{}))
+)
+
+// entry es un inodo o bloque cacheado junto con su estado
+type entry struct {
+	index int32
+	data  []byte
+	dirty bool
+}
+
+// Partition mantiene en memoria el superbloque, los bitmaps y los inodos y bloques
+// usados recientemente de una partición. Las escrituras se acumulan en memoria y
+// se bajan al disco con Flush.
+//
+// Los métodos son seguros para usarse desde varias goroutines, pero el orden de
+// las operaciones de un comando lo garantiza el candado de partición (paquete Locks).
+type Partition struct {
+	ID    string
+	Path  string
+	Start int64
+
+	mu   sync.Mutex // protege el LRU, los bitmaps y el archivo
+	file *os.File
+
+	sb      Structs.Superblock
+	sbDirty bool
+
+	bmInode      []byte
+	bmInodeDirty bool
+	bmBlock      []byte
+	bmBlockDirty bool
+
+	inodes     map[int32]*list.Element
+	inodeOrder *list.List
+	blocks     map[int32]*list.Element
+	blockOrder *list.List
+}
+
+var (
+	mu         sync.Mutex
+	partitions = make(map[string]*Partition) // id de partición → caché
+
+	// versions cuenta los cambios de cada partición. Se conserva aunque la caché se
+	// libere para que un resultado calculado con una versión anterior no se reutilice.
+	versionsMu sync.Mutex
+	versions   = make(map[string]uint64) // id de partición → versión
+)
+
+// Version retorna la versión de la partición, que aumenta con cada cambio hecho a
+// través de la caché y cada vez que la partición se reescribe directamente en disco
+func Version(id string) uint64 {
+	versionsMu.Lock()
+	defer versionsMu.Unlock()
+	return versions[id]
+}
+
+// changed aumenta la versión de la partición
+func changed(id string) {
+	versionsMu.Lock()
+	versions[id]++
+	versionsMu.Unlock()
+}
+
+// Open retorna la caché de la partición con el id indicado, creándola si no existe.
+// Si la caché existente apunta a otro disco u otro inicio se baja a disco y se recarga.
+func Open(id string, diskPath string, start int64) (*Partition, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if p, ok := partitions[id]; ok {
+		if p.Path == diskPath && p.Start == start {
+			return p, nil
+		}
+		if err := p.close(true); err != nil {
+			return nil, err
+		}
+		delete(partitions, id)
+	}
+
+	p, err := load(id, diskPath, start)
+	if err != nil {
+		return nil, err
+	}
+	partitions[id] = p
+	return p, nil
+}
+
+// load abre el disco y lee el superbloque y ambos bitmaps de la partición
+func load(id string, diskPath string, start int64) (*Partition, error) {
+	file, err := Utilities.OpenFile(diskPath)
+	if err != nil {
+		return nil, fmt.Errorf("error al abrir el disco: %v", err)
+	}
+
+	p := &Partition{
+		ID:         id,
+		Path:       diskPath,
+		Start:      start,
+		file:       file,
+		inodes:     make(map[int32]*list.Element),
+		inodeOrder: list.New(),
+		blocks:     make(map[int32]*list.Element),
+		blockOrder: list.New(),
+	}
+
+	if err := Utilities.ReadObject(file, &p.sb, start); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error al leer el Superblock: %v", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// Los bitmaps se limitan al tamaño del disco por si el superbloque trae conteos inválidos
+	p.bmInode = make([]byte, bitmapLength(p.sb.S_inodes_count, p.sb.S_bm_inode_start, info.Size()))
+	if _, err := file.ReadAt(p.bmInode, int64(p.sb.S_bm_inode_start)); err != nil && len(p.bmInode) > 0 {
+		file.Close()
+		return nil, fmt.Errorf("error al leer el bitmap de inodos: %v", err)
+	}
+	p.bmBlock = make([]byte, bitmapLength(p.sb.S_blocks_count, p.sb.S_bm_block_start, info.Size()))
+	if _, err := file.ReadAt(p.bmBlock, int64(p.sb.S_bm_block_start)); err != nil && len(p.bmBlock) > 0 {
+		file.Close()
+		return nil, fmt.Errorf("error al leer el bitmap de bloques: %v", err)
+	}
+	return p, nil
+}
+
+func bitmapLength(count int32, start int32, diskSize int64) int64 {
+	if count <= 0 || start <= 0 || int64(start) >= diskSize {
+		return 0
+	}
+	if int64(start)+int64(count) > diskSize {
+		return diskSize - int64(start)
+	}
+	return int64(count)
+}
+
+// Flush baja a disco los cambios pendientes de la partición con el id indicado
+func Flush(id string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if p, ok := partitions[id]; ok {
+		return p.Flush()
+	}
+	return nil
+}
+
+// FlushAll baja a disco los cambios pendientes de todas las particiones cacheadas
+func FlushAll() error {
+	mu.Lock()
+	defer mu.Unlock()
+	var firstErr error
+	for _, p := range partitions {
+		if err := p.Flush(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// CloseAll baja a disco y cierra todas las cachés. La siguiente apertura de cada
+// partición vuelve a abrir el disco, por ejemplo a través de un overlay recién activado.
+func CloseAll() error {
+	mu.Lock()
+	defer mu.Unlock()
+	var firstErr error
+	for id, p := range partitions {
+		delete(partitions, id)
+		if err := p.close(true); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Drop baja a disco los cambios de la partición y libera su caché
+func Drop(id string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	p, ok := partitions[id]
+	if !ok {
+		return nil
+	}
+	delete(partitions, id)
+	return p.close(true)
+}
+
+// Invalidate descarta la caché de la partición sin escribir los cambios pendientes.
+// Se usa cuando la partición se reescribe directamente en disco (por ejemplo mkfs).
+func Invalidate(id string) {
+	mu.Lock()
+	defer mu.Unlock()
+	changed(id)
+	if p, ok := partitions[id]; ok {
+		delete(partitions, id)
+		p.close(false)
+	}
+}
+
+// DiscardAll descarta todas las cachés sin escribir sus cambios. Se usa al
+// deshacer un script transaccional, después de restaurar los discos.
+func DiscardAll() {
+	mu.Lock()
+	defer mu.Unlock()
+	for id, p := range partitions {
+		changed(id)
+		delete(partitions, id)
+		p.close(false)
+	}
+}
+
+// DropDisk libera todas las cachés de particiones que viven en el disco indicado.
+// Si flush es false los cambios pendientes se descartan.
+func DropDisk(diskPath string, flush bool) error {
+	mu.Lock()
+	defer mu.Unlock()
+	var firstErr error
+	for id, p := range partitions {
+		if p.Path != diskPath {
+			continue
+		}
+		if !flush {
+			changed(id)
+		}
+		delete(partitions, id)
+		if err := p.close(flush); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Isolate baja a disco las cachés actuales y las aparta para que un dry-run use
+// cachés propias. La función retornada baja y cierra las cachés del dry-run y
+// restaura las anteriores.
+func Isolate() (func() error, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, p := range partitions {
+		if err := p.Flush(); err != nil {
+			return nil, err
+		}
+	}
+	saved := partitions
+	partitions = make(map[string]*Partition)
+	return func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		var firstErr error
+		for _, p := range partitions {
+			if err := p.close(true); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		partitions = saved
+		return firstErr
+	}, nil
+}
+
+func (p *Partition) close(flush bool) error {
+	var err error
+	if flush {
+		err = p.Flush()
+	}
+	p.mu.Lock()
+	p.file.Close()
+	p.mu.Unlock()
+	return err
+}
+
+// Superblock retorna el superbloque cacheado. Si se modifica se debe llamar a MarkSuperblockDirty.
+func (p *Partition) Superblock() *Structs.Superblock {
+	return &p.sb
+}
+
+// MarkSuperblockDirty marca el superbloque para ser escrito en el siguiente Flush
+func (p *Partition) MarkSuperblockDirty() {
+	p.sbDirty = true
+	changed(p.ID)
+}
+
+// ReadInode retorna una copia del inodo con el índice indicado
+func (p *Partition) ReadInode(index int32) (*Structs.Inode, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e, err := p.getEntry(index, p.inodes, p.inodeOrder, maxInodes, int64(p.sb.S_inode_start), inodeSize)
+	if err != nil {
+		return nil, err
+	}
+	var inode Structs.Inode
+	if err := binary.Read(bytes.NewReader(e.data), binary.LittleEndian, &inode); err != nil {
+		return nil, err
+	}
+	return &inode, nil
+}
+
+// WriteInode actualiza el inodo en memoria y lo marca como modificado
+func (p *Partition) WriteInode(index int32, inode Structs.Inode) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.putEntry(index, inode, p.inodes, p.inodeOrder, maxInodes, int64(p.sb.S_inode_start), inodeSize)
+}
+
+// ReadBlock decodifica el bloque con el índice indicado en data (Folderblock, Fileblock o Pointerblock)
+func (p *Partition) ReadBlock(index int32, data interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e, err := p.getEntry(index, p.blocks, p.blockOrder, maxBlocks, int64(p.sb.S_block_start), blockSize)
+	if err != nil {
+		return err
+	}
+	return binary.Read(bytes.NewReader(e.data), binary.LittleEndian, data)
+}
+
+// WriteBlock actualiza el bloque en memoria y lo marca como modificado
+func (p *Partition) WriteBlock(index int32, data interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.putEntry(index, data, p.blocks, p.blockOrder, maxBlocks, int64(p.sb.S_block_start), blockSize)
+}
+
+// AllocateBlock marca como usado el primer bloque libre del bitmap y retorna su índice
+func (p *Partition) AllocateBlock() (int32, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, bit := range p.bmBlock {
+		if bit == 0 {
+			p.bmBlock[i] = 1
+			p.bmBlockDirty = true
+			changed(p.ID)
+			return int32(i), nil
+		}
+	}
+	return -1, fmt.Errorf("no se encontró bloque libre")
+}
+
+// FreeBlock marca un bloque como libre en el bitmap
+func (p *Partition) FreeBlock(index int32) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if index < 0 || int(index) >= len(p.bmBlock) {
+		return
+	}
+	p.bmBlock[index] = 0
+	p.bmBlockDirty = true
+	changed(p.ID)
+}
+
+// SetInodeUsed actualiza el estado de un inodo en el bitmap de inodos
+func (p *Partition) SetInodeUsed(index int32, used bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if index < 0 || int(index) >= len(p.bmInode) {
+		return
+	}
+	if used {
+		p.bmInode[index] = 1
+	} else {
+		p.bmInode[index] = 0
+	}
+	p.bmInodeDirty = true
+	changed(p.ID)
+}
+
+// Flush escribe en disco el superbloque, los bitmaps y los inodos y bloques modificados
+func (p *Partition) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.sbDirty {
+		if err := Utilities.WriteObject(p.file, p.sb, p.Start); err != nil {
+			return fmt.Errorf("error al escribir el Superblock: %v", err)
+		}
+		p.sbDirty = false
+	}
+	if p.bmInodeDirty {
+		if _, err := p.file.WriteAt(p.bmInode, int64(p.sb.S_bm_inode_start)); err != nil {
+			return fmt.Errorf("error al escribir el bitmap de inodos: %v", err)
+		}
+		p.bmInodeDirty = false
+	}
+	if p.bmBlockDirty {
+		if _, err := p.file.WriteAt(p.bmBlock, int64(p.sb.S_bm_block_start)); err != nil {
+			return fmt.Errorf("error al escribir el bitmap de bloques: %v", err)
+		}
+		p.bmBlockDirty = false
+	}
+	for _, el := range p.inodes {
+		if err := p.writeBack(el.Value.(*entry), int64(p.sb.S_inode_start), inodeSize); err != nil {
+			return fmt.Errorf("error al escribir el inodo: %v", err)
+		}
+	}
+	for _, el := range p.blocks {
+		if err := p.writeBack(el.Value.(*entry), int64(p.sb.S_block_start), blockSize); err != nil {
+			return fmt.Errorf("error al escribir el bloque: %v", err)
+		}
+	}
+	return nil
+}
+
+// getEntry busca un inodo o bloque en memoria y si no está lo lee del disco
+func (p *Partition) getEntry(index int32, table map[int32]*list.Element, order *list.List, limit int, base int64, size int64) (*entry, error) {
+	if index < 0 {
+		return nil, fmt.Errorf("índice inválido: %d", index)
+	}
+	if el, ok := table[index]; ok {
+		order.MoveToFront(el)
+		return el.Value.(*entry), nil
+	}
+	e := &entry{index: index, data: make([]byte, size)}
+	if _, err := p.file.ReadAt(e.data, base+int64(index)*size); err != nil {
+		return nil, err
+	}
+	if err := p.insert(e, table, order, limit, base, size); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// putEntry codifica data y la guarda en memoria como modificada
+func (p *Partition) putEntry(index int32, data interface{}, table map[int32]*list.Element, order *list.List, limit int, base int64, size int64) error {
+	if index < 0 {
+		return fmt.Errorf("índice inválido: %d", index)
+	}
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, data); err != nil {
+		return err
+	}
+	raw := make([]byte, size)
+	copy(raw, buf.Bytes())
+	changed(p.ID)
+
+	if el, ok := table[index]; ok {
+		e := el.Value.(*entry)
+		e.data = raw
+		e.dirty = true
+		order.MoveToFront(el)
+		return nil
+	}
+	return p.insert(&entry{index: index, data: raw, dirty: true}, table, order, limit, base, size)
+}
+
+// insert agrega una entrada y expulsa la menos usada si se supera el límite
+func (p *Partition) insert(e *entry, table map[int32]*list.Element, order *list.List, limit int, base int64, size int64) error {
+	table[e.index] = order.PushFront(e)
+	for order.Len() > limit {
+		oldest := order.Back()
+		old := oldest.Value.(*entry)
+		if err := p.writeBack(old, base, size); err != nil {
+			return err
+		}
+		order.Remove(oldest)
+		delete(table, old.index)
+	}
+	return nil
+}
+
+func (p *Partition) writeBack(e *entry, base int64, size int64) error {
+	if !e.dirty {
+		return nil
+	}
+	if _, err := p.file.WriteAt(e.data, base+int64(e.index)*size); err != nil {
+		return err
+	}
+	e.dirty = false
+	return nil
+}
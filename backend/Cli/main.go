@@ -0,0 +1,250 @@
+package main
+
+import (
+	"MIA_P1/Analyzer"
+	"MIA_P1/Cache"
+	"MIA_P1/Commands"
+	"MIA_P1/LineEditor"
+	"MIA_P1/OutPut"
+	"MIA_P1/Results"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CLI del sistema de archivos. Tiene tres modos:
+//
+//	mia                         consola interactiva sobre los discos locales (./tets)
+//	mia -f script.sdaa          ejecuta un script y termina; el código de salida es 1 si algo falla
+//	mia -remote http://host:8080 [-f script.sdaa]
+//	                            envía los comandos a un backend en ejecución
+//
+// El historial de la consola se guarda en ~/.mia_history.
+
+func main() {
+	file := flag.String("f", "", "Script .sdaa a ejecutar sin consola interactiva")
+	remote := flag.String("remote", "", "URL de un backend en ejecución, por ejemplo http://localhost:8080")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Uso: mia [-f script.sdaa] [-remote URL]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *remote != "" {
+		client := &remoteClient{
+			baseURL: strings.TrimRight(*remote, "/"),
+			http:    &http.Client{Timeout: 5 * time.Minute},
+		}
+		if *file != "" {
+			os.Exit(client.runScript(*file))
+		}
+		client.shell(newEditor())
+		return
+	}
+
+	if *file != "" {
+		os.Exit(runScript(*file))
+	}
+	fmt.Println("Consola del sistema de archivos. Ctrl-D o exit para salir.")
+	Analyzer.Analyze(newEditor())
+}
+
+// newEditor crea el editor de la consola con el historial y el autocompletado de comandos
+func newEditor() *LineEditor.Editor {
+	editor := LineEditor.New(historyFile())
+	editor.Completer = Commands.CompleteLine
+	return editor
+}
+
+// historyFile retorna la ruta del historial, o "" si no hay carpeta personal
+func historyFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".mia_history")
+}
+
+// runScript ejecuta un script local igual que el comando execute: los pause y los
+// comandos que piden confirmación se omiten. Retorna el código de salida.
+func runScript(path string) int {
+	out := OutPut.NewConsole(os.Stdout)
+	result := Analyzer.AnalyzeCommand(out, "execute", `-path="`+path+`"`)
+	if err := Cache.FlushAll(); err != nil {
+		out.Error("Error al guardar las particiones:", err)
+		return 1
+	}
+	// Los errores ya se mostraron en la salida, incluido el resumen de comandos fallidos
+	if result.Failed() {
+		return 1
+	}
+	return 0
+}
+
+// remoteClient envía comandos a la API de un backend en ejecución
+type remoteClient struct {
+	baseURL string
+	http    *http.Client
+}
+
+// executeResponse son los campos de /api/execute que usa la CLI
+type executeResponse struct {
+	Confirm bool           `json:"confirm"`
+	Message string         `json:"message"`
+	Console string         `json:"console"`
+	Result  Results.Result `json:"result"`
+}
+
+// scriptResponse son los campos de /api/executeScript que usa la CLI
+type scriptResponse struct {
+	Confirm   bool             `json:"confirm"`
+	Message   string           `json:"message"`
+	Console   string           `json:"console"`
+	Commands  []Results.Result `json:"commands"`
+	Paused    bool             `json:"paused"`
+	Cancelled bool             `json:"cancelled"`
+	Stopped   bool             `json:"stopped"`
+	ScriptID  string           `json:"scriptId"`
+	Line      int              `json:"line"`
+}
+
+// shell es la consola interactiva del modo remoto. exit cierra la CLI sin
+// enviarse al servidor, porque ahí detendría el backend.
+func (c *remoteClient) shell(editor *LineEditor.Editor) {
+	fmt.Printf("Conectado a %s. Ctrl-D o exit para salir.\n", c.baseURL)
+	for {
+		input, err := editor.ReadLine("mia@remoto> ")
+		if err != nil {
+			if err != io.EOF {
+				fmt.Fprintln(os.Stderr, "Error reading input:", err)
+			}
+			return
+		}
+		input = strings.TrimSpace(input)
+		if input == "" || strings.HasPrefix(input, "#") {
+			continue
+		}
+		editor.AddHistory(input)
+		if command, _ := Analyzer.GetCommandAndParams(input); command == "exit" {
+			return
+		}
+
+		res, err := c.execute(input, false)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			continue
+		}
+		fmt.Print(console(res.Console))
+		if res.Confirm {
+			answer, err := editor.ReadLine(res.Message + " (s/n): ")
+			if err != nil || !Analyzer.IsYes(answer) {
+				fmt.Println("Operación cancelada")
+				continue
+			}
+			res, err = c.execute(input, true)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				continue
+			}
+			fmt.Print(console(res.Console))
+		}
+	}
+}
+
+// runScript envía un script local al servidor y lo ejecuta completo: las pausas se
+// continúan y los comandos que piden confirmación se omiten. Los include del script
+// se resuelven en el servidor. Retorna el código de salida.
+func (c *remoteClient) runScript(path string) int {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error al leer el archivo %s: %v\n", path, err)
+		return 1
+	}
+
+	var res scriptResponse
+	if err := c.post("/api/executeScript", map[string]interface{}{"script": string(content)}, &res); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	code := 0
+	for {
+		fmt.Print(console(res.Console))
+		for _, result := range res.Commands {
+			if result.Failed() {
+				code = 1
+			}
+		}
+		if res.Cancelled || res.Stopped {
+			return 1
+		}
+		if !res.Paused && !res.Confirm {
+			return code
+		}
+		if res.Confirm {
+			fmt.Printf("Advertencia: %s Se omitió el comando de la línea %d; use -confirm o -force para ejecutarlo.\n", res.Message, res.Line)
+			code = 1
+		}
+		// continue reanuda después de una pausa y omite el comando que espera confirmación
+		id := res.ScriptID
+		res = scriptResponse{}
+		if err := c.post("/api/scripts/"+id+"/continue", nil, &res); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return 1
+		}
+	}
+}
+
+// execute envía un comando a /api/execute
+func (c *remoteClient) execute(input string, confirm bool) (executeResponse, error) {
+	var res executeResponse
+	err := c.post("/api/execute", map[string]interface{}{"input": input, "confirm": confirm}, &res)
+	return res, err
+}
+
+// post envía body como JSON y decodifica la respuesta en res. Las respuestas con
+// error traen {"error": "..."}.
+func (c *remoteClient) post(path string, body interface{}, res interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	response, err := c.http.Post(c.baseURL+path, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("no se pudo conectar con %s: %v", c.baseURL, err)
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusOK {
+		var problem struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &problem) == nil && problem.Error != "" {
+			return fmt.Errorf("%s", problem.Error)
+		}
+		return fmt.Errorf("el servidor respondió %s", response.Status)
+	}
+	return json.Unmarshal(data, res)
+}
+
+// console asegura que la salida del servidor termine en un salto de línea
+func console(text string) string {
+	if text == "" || strings.HasSuffix(text, "\n") {
+		return text
+	}
+	return text + "\n"
+}
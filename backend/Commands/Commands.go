@@ -0,0 +1,263 @@
+package Commands
+
+import (
+	"MIA_P1/OutPut"
+	"MIA_P1/Parser"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Cada comando se registra una sola vez con su nombre, alias, parámetros, ayuda,
+// la sesión que necesita y la función que lo ejecuta. El analizador, la validación
+// de scripts y el autocompletado de la consola se generan a partir del registro,
+// así que agregar un comando es solo llamar a Register.
+
+// Access es la sesión que necesita un comando
+type Access int
+
+const (
+	Anyone   Access = iota // no necesita sesión
+	LoggedIn               // necesita una sesión iniciada
+	Root                   // solo el usuario root
+)
+
+func (a Access) String() string {
+	switch a {
+	case LoggedIn:
+		return "sesión iniciada"
+	case Root:
+		return "usuario root"
+	default:
+		return "ninguna"
+	}
+}
+
+// Handler ejecuta un comando con sus parámetros ya validados y retorna el mensaje
+// y los datos de su resultado
+type Handler func(out *OutPut.Output, args *Parser.Args) (message string, data interface{}, err error)
+
+// Command describe un comando de la consola
+type Command struct {
+	Name        string
+	Aliases     []string
+	Summary     string // descripción de una línea
+	Flags       []Parser.Flag
+	Confirmable bool // puede pedir confirmación y acepta -confirm y -force
+	Access      Access
+	Examples    []string
+	Run         Handler
+
+	schema *Parser.Schema
+}
+
+// Schema retorna el esquema con el que se validan los parámetros del comando
+func (c *Command) Schema() *Parser.Schema {
+	return c.schema
+}
+
+var (
+	commands []*Command              // en orden de registro
+	byName   = map[string]*Command{} // nombres y alias
+)
+
+// Register agrega un comando al registro. Un nombre o alias repetido es un error
+// de programación, así que entra en pánico igual que http.HandleFunc.
+func Register(c Command) {
+	if c.Name == "" || c.Run == nil {
+		panic("Commands: el comando necesita nombre y función")
+	}
+	c.schema = &Parser.Schema{Command: c.Name, Flags: c.Flags, Confirmable: c.Confirmable}
+	cmd := &c
+	for _, name := range append([]string{c.Name}, c.Aliases...) {
+		name = strings.ToLower(name)
+		if _, exists := byName[name]; exists {
+			panic(fmt.Sprintf("Commands: %s ya está registrado", name))
+		}
+		byName[name] = cmd
+	}
+	commands = append(commands, cmd)
+}
+
+// Lookup busca un comando por su nombre o por uno de sus alias
+func Lookup(name string) (*Command, bool) {
+	cmd, ok := byName[strings.ToLower(name)]
+	return cmd, ok
+}
+
+// All retorna los comandos en el orden en que se registraron
+func All() []*Command {
+	return append([]*Command(nil), commands...)
+}
+
+// FlagDoc es la documentación de un parámetro
+type FlagDoc struct {
+	Name       string   `json:"name"`
+	Type       string   `json:"type"`
+	Required   bool     `json:"required"`
+	Default    string   `json:"default,omitempty"`
+	Values     []string `json:"values,omitempty"`
+	Numbered   bool     `json:"numbered,omitempty"`   // se escribe -name1, -name2, ...
+	Positional bool     `json:"positional,omitempty"` // se puede escribir sin nombre
+	Help       string   `json:"help"`
+}
+
+// Doc es la documentación de un comando, la misma que muestra man
+type Doc struct {
+	Name        string    `json:"name"`
+	Aliases     []string  `json:"aliases,omitempty"`
+	Summary     string    `json:"summary"`
+	Usage       string    `json:"usage"`
+	Access      string    `json:"access"`
+	Confirmable bool      `json:"confirmable"`
+	Flags       []FlagDoc `json:"flags"`
+	Examples    []string  `json:"examples,omitempty"`
+}
+
+// Doc retorna la documentación del comando
+func (c *Command) Doc() Doc {
+	doc := Doc{
+		Name:        c.Name,
+		Aliases:     c.Aliases,
+		Summary:     c.Summary,
+		Usage:       c.Usage(),
+		Access:      c.Access.String(),
+		Confirmable: c.Confirmable,
+		Flags:       []FlagDoc{},
+		Examples:    c.Examples,
+	}
+	for _, flag := range c.schema.AllFlags() {
+		doc.Flags = append(doc.Flags, FlagDoc{
+			Name:       flag.Name,
+			Type:       flag.Kind.String(),
+			Required:   flag.Required,
+			Default:    flag.Default,
+			Values:     flag.Values,
+			Numbered:   flag.Prefix,
+			Positional: flag.Positional,
+			Help:       flag.Help,
+		})
+	}
+	return doc
+}
+
+// Usage retorna la forma de escribir el comando, con los parámetros opcionales
+// entre corchetes, por ejemplo "mkdisk -size=<int> [-fit=BF|FF|WF] [-unit=K|M]"
+func (c *Command) Usage() string {
+	parts := []string{c.Name}
+	for _, flag := range c.schema.AllFlags() {
+		part := "-" + flag.Name
+		if flag.Prefix {
+			part += "N"
+		}
+		switch {
+		case flag.Kind == Parser.Bool:
+		case len(flag.Values) > 0:
+			part += "=" + strings.Join(flag.Values, "|")
+		default:
+			part += "=<" + flag.Kind.String() + ">"
+		}
+		if flag.Positional {
+			part = "<" + flag.Name + ">"
+		}
+		if !flag.Required {
+			part = "[" + part + "]"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Manual retorna el texto de man para el comando
+func (c *Command) Manual() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s - %s\n", strings.ToUpper(c.Name), c.Summary)
+	fmt.Fprintf(&b, "Uso: %s\n", c.Usage())
+	if len(c.Aliases) > 0 {
+		fmt.Fprintf(&b, "Alias: %s\n", strings.Join(c.Aliases, ", "))
+	}
+	fmt.Fprintf(&b, "Sesión requerida: %s\n", c.Access)
+
+	flags := c.schema.AllFlags()
+	if len(flags) > 0 {
+		b.WriteString("Parámetros:\n")
+		width := 0
+		for _, flag := range flags {
+			width = max(width, len(flagName(flag)))
+		}
+		for _, flag := range flags {
+			var notes []string
+			if flag.Required {
+				notes = append(notes, "obligatorio")
+			}
+			notes = append(notes, flag.Kind.String())
+			if len(flag.Values) > 0 {
+				notes = append(notes, "valores: "+strings.Join(flag.Values, ", "))
+			}
+			if flag.Default != "" {
+				notes = append(notes, "por defecto: "+flag.Default)
+			}
+			fmt.Fprintf(&b, "  %-*s  %s (%s)\n", width, flagName(flag), flag.Help, strings.Join(notes, "; "))
+		}
+	}
+	if len(c.Examples) > 0 {
+		b.WriteString("Ejemplos:\n")
+		for _, example := range c.Examples {
+			fmt.Fprintf(&b, "  %s\n", example)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func flagName(flag Parser.Flag) string {
+	if flag.Prefix {
+		return "-" + flag.Name + "N"
+	}
+	return "-" + flag.Name
+}
+
+// CompleteLine retorna las palabras que pueden completar la última palabra de line
+// (el texto antes del cursor): nombres de comando en la primera palabra y
+// parámetros del comando en las que empiezan con guion. Cada candidato incluye lo
+// que sigue a la palabra, un espacio o el = de los parámetros con valor.
+func CompleteLine(line string) []string {
+	fields := strings.Fields(line)
+	word := ""
+	if len(fields) > 0 && !strings.HasSuffix(line, " ") {
+		word = fields[len(fields)-1]
+		fields = fields[:len(fields)-1]
+	}
+
+	var candidates []string
+	if len(fields) == 0 {
+		prefix := strings.ToLower(word)
+		for name := range byName {
+			if strings.HasPrefix(name, prefix) {
+				candidates = append(candidates, name+" ")
+			}
+		}
+		sort.Strings(candidates)
+		return candidates
+	}
+
+	cmd, ok := Lookup(fields[0])
+	if !ok || (word != "" && !strings.HasPrefix(word, "-")) || strings.Contains(word, "=") {
+		return nil
+	}
+	prefix := strings.ToLower(strings.TrimPrefix(word, "-"))
+	for _, flag := range cmd.schema.AllFlags() {
+		name := flag.Name
+		if flag.Prefix {
+			name += "1"
+		}
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if flag.Kind == Parser.Bool {
+			candidates = append(candidates, "-"+name+" ")
+		} else {
+			candidates = append(candidates, "-"+name+"=")
+		}
+	}
+	return candidates
+}
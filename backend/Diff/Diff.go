@@ -0,0 +1,350 @@
+package Diff
+
+import (
+	"MIA_P1/Cache"
+	"MIA_P1/DiskManagement"
+	"MIA_P1/Locks"
+	"MIA_P1/Results"
+	"MIA_P1/Tree"
+	"MIA_P1/UserManager"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Un diff compara los archivos de dos particiones, o de una partición y una
+// instantánea guardada antes con snapshot. Cada lado se lee como una lista de
+// entradas con la ruta relativa a la carpeta comparada, el tipo, el dueño, los
+// permisos, el tamaño y un hash del contenido de los archivos. Así dos
+// particiones con los mismos archivos son iguales aunque sus inodos y bloques
+// estén en otras posiciones.
+//
+// Las instantáneas se guardan como JSON en SNAPSHOT_DIR (por defecto
+// ./snapshots), un archivo por nombre.
+
+// Entry es un archivo o carpeta de un lado de la comparación
+type Entry struct {
+	Path string `json:"path"` // relativa a la carpeta comparada; la carpeta es "/"
+	Type string `json:"type"` // directorio o archivo
+	UID  int32  `json:"uid"`
+	GID  int32  `json:"gid"`
+	Size int32  `json:"size"`
+	Perm string `json:"perm"`
+	Hash string `json:"hash,omitempty"` // SHA-256 del contenido; vacío en carpetas
+}
+
+// Snapshot son las entradas de una partición desde la carpeta Root
+type Snapshot struct {
+	Name      string    `json:"name,omitempty"` // vacío si no se guardó
+	Partition string    `json:"partition"`
+	Root      string    `json:"root"`
+	CreatedAt time.Time `json:"createdAt"`
+	Entries   []Entry   `json:"entries,omitempty"`
+}
+
+// Status es el tipo de cambio de una entrada
+type Status string
+
+const (
+	Added    Status = "added"
+	Removed  Status = "removed"
+	Modified Status = "modified"
+)
+
+// Change es una entrada que no es igual en los dos lados. Fields lista lo que
+// cambió en una entrada modificada: type, perm, uid, gid, size o content.
+type Change struct {
+	Path   string   `json:"path"`
+	Status Status   `json:"status"`
+	Fields []string `json:"fields,omitempty"`
+	Before *Entry   `json:"before,omitempty"` // nil si se agregó
+	After  *Entry   `json:"after,omitempty"`  // nil si se eliminó
+}
+
+// Result es el resultado de comparar Left (antes) con Right (después)
+type Result struct {
+	Left     string   `json:"left"`
+	Right    string   `json:"right"`
+	Root     string   `json:"root"`
+	Equal    bool     `json:"equal"`
+	Added    int      `json:"added"`
+	Removed  int      `json:"removed"`
+	Modified int      `json:"modified"`
+	Changes  []Change `json:"changes"`
+}
+
+// Take lee las entradas de la partición id desde la carpeta root
+func Take(ctx context.Context, id string, root string) (Snapshot, error) {
+	root = cleanPath(root)
+	// El árbol y el contenido se leen con la partición bloqueada una sola vez, así
+	// las entradas y sus hashes corresponden al mismo estado
+	partitionPath := DiskManagement.GetPartitionPathByID(id)
+	partitionStart := DiskManagement.GetPartitionStartByID(id)
+	if partitionPath == "" || partitionStart < 0 {
+		return Snapshot{}, Results.Errorf(Results.NotFound, "no se encontró la partición montada %s", id)
+	}
+	defer Locks.RLockPartition(partitionPath, id)()
+	tree, err := Tree.ReadTree(ctx, id, partitionPath, Tree.Options{Root: root})
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	var entries []Entry
+	var inodes []int // inodo de cada entrada, para leer el contenido
+	var walk func(node *Tree.TreeNode, rel string)
+	walk = func(node *Tree.TreeNode, rel string) {
+		// Un inodo repetido ya se agregó donde apareció primero
+		if node.Repeated {
+			return
+		}
+		entries = append(entries, Entry{Path: rel, Type: node.Type, UID: node.UID, GID: node.GID, Size: node.Size, Perm: node.Perm})
+		inodes = append(inodes, node.Index)
+		for _, child := range node.Children {
+			walk(child, path.Join(rel, child.Name))
+		}
+	}
+	walk(tree, "/")
+
+	part, err := Cache.Open(id, partitionPath, partitionStart)
+	if err != nil {
+		return Snapshot{}, Results.Errorf(Results.IOError, "error abriendo la partición: %v", err)
+	}
+	for i := range entries {
+		if err := ctx.Err(); err != nil {
+			return Snapshot{}, err
+		}
+		if entries[i].Type == "directorio" {
+			continue
+		}
+		inode, err := part.ReadInode(int32(inodes[i]))
+		if err != nil {
+			return Snapshot{}, Results.Errorf(Results.IOError, "error al leer el inodo %d: %v", inodes[i], err)
+		}
+		sum := sha256.Sum256([]byte(UserManager.GetInodeFileData(*inode, part)))
+		entries[i].Hash = hex.EncodeToString(sum[:])
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return Snapshot{Partition: id, Root: root, CreatedAt: time.Now(), Entries: entries}, nil
+}
+
+// Sub retorna las entradas de la instantánea dentro de la carpeta root, con las
+// rutas relativas a ella
+func (s Snapshot) Sub(root string) (Snapshot, error) {
+	root = cleanPath(root)
+	if root == s.Root {
+		return s, nil
+	}
+	rel, ok := strings.CutPrefix(root, strings.TrimSuffix(s.Root, "/")+"/")
+	if !ok {
+		return Snapshot{}, Results.Errorf(Results.InvalidParams, "la instantánea %s se tomó desde %s y no incluye %s", s.Name, s.Root, root)
+	}
+	prefix := "/" + rel
+	sub := s
+	sub.Root = root
+	sub.Entries = nil
+	for _, e := range s.Entries {
+		switch {
+		case e.Path == prefix:
+			e.Path = "/"
+		case strings.HasPrefix(e.Path, prefix+"/"):
+			e.Path = strings.TrimPrefix(e.Path, prefix)
+		default:
+			continue
+		}
+		sub.Entries = append(sub.Entries, e)
+	}
+	if len(sub.Entries) == 0 {
+		return Snapshot{}, Results.Errorf(Results.NotFound, "la instantánea %s no tiene la ruta %s", s.Name, root)
+	}
+	return sub, nil
+}
+
+// Describe nombra la instantánea en los mensajes: la partición o el nombre guardado
+func (s Snapshot) Describe() string {
+	if s.Name != "" {
+		return "instantánea " + s.Name + " (" + s.Partition + ")"
+	}
+	return "partición " + s.Partition
+}
+
+// Compare retorna los cambios para pasar de left a right
+func Compare(left, right Snapshot) Result {
+	before := make(map[string]Entry, len(left.Entries))
+	for _, e := range left.Entries {
+		before[e.Path] = e
+	}
+	after := make(map[string]Entry, len(right.Entries))
+	for _, e := range right.Entries {
+		after[e.Path] = e
+	}
+
+	result := Result{Left: left.Describe(), Right: right.Describe(), Root: right.Root, Changes: []Change{}}
+	for _, e := range left.Entries {
+		if _, ok := after[e.Path]; !ok {
+			result.Changes = append(result.Changes, Change{Path: e.Path, Status: Removed, Before: &e})
+			result.Removed++
+		}
+	}
+	for _, e := range right.Entries {
+		old, ok := before[e.Path]
+		if !ok {
+			result.Changes = append(result.Changes, Change{Path: e.Path, Status: Added, After: &e})
+			result.Added++
+			continue
+		}
+		if fields := changedFields(old, e); len(fields) > 0 {
+			result.Changes = append(result.Changes, Change{Path: e.Path, Status: Modified, Fields: fields, Before: &old, After: &e})
+			result.Modified++
+		}
+	}
+	sort.SliceStable(result.Changes, func(i, j int) bool {
+		return result.Changes[i].Path < result.Changes[j].Path
+	})
+	result.Equal = len(result.Changes) == 0
+	return result
+}
+
+// changedFields retorna los campos distintos entre dos versiones de una entrada
+func changedFields(a, b Entry) []string {
+	var fields []string
+	if a.Type != b.Type {
+		fields = append(fields, "type")
+	}
+	if a.Perm != b.Perm {
+		fields = append(fields, "perm")
+	}
+	if a.UID != b.UID {
+		fields = append(fields, "uid")
+	}
+	if a.GID != b.GID {
+		fields = append(fields, "gid")
+	}
+	if a.Size != b.Size {
+		fields = append(fields, "size")
+	}
+	if a.Hash != b.Hash && a.Type == b.Type {
+		fields = append(fields, "content")
+	}
+	return fields
+}
+
+// cleanPath normaliza la carpeta comparada; vacío es "/"
+func cleanPath(p string) string {
+	return path.Clean("/" + p)
+}
+
+// Dir retorna la carpeta donde se guardan las instantáneas
+func Dir() string {
+	if dir := os.Getenv("SNAPSHOT_DIR"); dir != "" {
+		return dir
+	}
+	return "./snapshots"
+}
+
+// Los nombres de instantánea son nombres de archivo simples
+var validName = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9_.-]*$`)
+
+func snapshotFile(name string) (string, error) {
+	if !validName.MatchString(name) {
+		return "", Results.Errorf(Results.InvalidParams, "nombre de instantánea inválido: %q (use letras, números, _, - y .)", name)
+	}
+	return filepath.Join(Dir(), name+".json"), nil
+}
+
+// Save guarda la instantánea con el nombre indicado; reemplaza la anterior
+func Save(s Snapshot, name string) (Snapshot, error) {
+	file, err := snapshotFile(name)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	s.Name = name
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if err := os.MkdirAll(Dir(), os.ModePerm); err != nil {
+		return Snapshot{}, Results.Errorf(Results.IOError, "error al crear la carpeta de instantáneas: %v", err)
+	}
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		return Snapshot{}, Results.Errorf(Results.IOError, "error al guardar la instantánea %s: %v", name, err)
+	}
+	return s, nil
+}
+
+// Load lee una instantánea guardada
+func Load(name string) (Snapshot, error) {
+	file, err := snapshotFile(name)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return Snapshot{}, Results.Errorf(Results.NotFound, "no existe la instantánea %s", name)
+	}
+	if err != nil {
+		return Snapshot{}, Results.Errorf(Results.IOError, "error al leer la instantánea %s: %v", name, err)
+	}
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return Snapshot{}, Results.Errorf(Results.IOError, "la instantánea %s está dañada: %v", name, err)
+	}
+	s.Name = name
+	return s, nil
+}
+
+// List retorna las instantáneas guardadas, sin sus entradas, de la más reciente
+// a la más antigua
+func List() []Snapshot {
+	list := []Snapshot{}
+	files, _ := filepath.Glob(filepath.Join(Dir(), "*.json"))
+	for _, file := range files {
+		s, err := Load(strings.TrimSuffix(filepath.Base(file), ".json"))
+		if err != nil {
+			continue
+		}
+		s.Entries = nil
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+	return list
+}
+
+// String describe el cambio en una línea: + agregado, - eliminado, ~ modificado
+func (c Change) String() string {
+	switch c.Status {
+	case Added:
+		return fmt.Sprintf("+ %s (%s)", c.Path, c.After.Type)
+	case Removed:
+		return fmt.Sprintf("- %s (%s)", c.Path, c.Before.Type)
+	}
+	var details []string
+	for _, field := range c.Fields {
+		switch field {
+		case "type":
+			details = append(details, fmt.Sprintf("tipo %s → %s", c.Before.Type, c.After.Type))
+		case "perm":
+			details = append(details, fmt.Sprintf("permisos %s → %s", c.Before.Perm, c.After.Perm))
+		case "uid":
+			details = append(details, fmt.Sprintf("UID %d → %d", c.Before.UID, c.After.UID))
+		case "gid":
+			details = append(details, fmt.Sprintf("GID %d → %d", c.Before.GID, c.After.GID))
+		case "size":
+			details = append(details, fmt.Sprintf("tamaño %d → %d", c.Before.Size, c.After.Size))
+		case "content":
+			details = append(details, "contenido")
+		}
+	}
+	return fmt.Sprintf("~ %s: %s", c.Path, strings.Join(details, ", "))
+}
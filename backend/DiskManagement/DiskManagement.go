@@ -1,24 +1,36 @@
 package DiskManagement
 
 import (
+	"MIA_P1/Cache"
+	"MIA_P1/Export"
+	"MIA_P1/Jobs"
+	"MIA_P1/Locks"
 	"MIA_P1/OutPut"
+	"MIA_P1/Overlay"
+	"MIA_P1/Render"
+	"MIA_P1/Results"
 	"MIA_P1/Structs"
 	"MIA_P1/Utilities"
 	"MIA_P1/stores"
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"math/rand"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
-var diskCounter int = 0 // contador para generar los nombres de los discos
+var (
+	diskCounter   int = 0 // contador para generar los nombres de los discos
+	diskCounterMu sync.Mutex
+)
 
 type MountedPartition struct {
 	Path     string
@@ -29,9 +41,9 @@ type MountedPartition struct {
 	Start    int64 // Nuevo campo para indicar el offset de inicio de la partición en el disco
 }
 
-func Mount(driveLetter string, name string) {
-	OutPut.Println("======Start MOUNT======")
-	OutPut.Println("Drive Letter:", driveLetter, "Name:", name)
+func Mount(out *OutPut.Output, driveLetter string, name string) (string, error) {
+	out.Println("======Start MOUNT======")
+	out.Println("Drive Letter:", driveLetter, "Name:", name)
 
 	// Normalize name and drive letter
 	name = strings.ToUpper(name)
@@ -39,17 +51,17 @@ func Mount(driveLetter string, name string) {
 
 	// Ruta del disco
 	filepath := fmt.Sprintf("./tets/%s.dsk", strings.ToUpper(driveLetter))
+	defer Locks.LockDisk(filepath)()
+
 	file, err := Utilities.OpenFile(filepath)
 	if err != nil {
-		OutPut.Println("Error opening file:", err)
-		return
+		return "", Results.Errorf(Results.NotFound, "failed to open disk: %v", err)
 	}
 	defer file.Close()
 
 	var tempMBR Structs.MRB
 	if err := Utilities.ReadObject(file, &tempMBR, 0); err != nil {
-		OutPut.Println("Error reading MRB from file:", err)
-		return
+		return "", Results.Errorf(Results.IOError, "failed to read MBR: %v", err)
 	}
 
 	// Buscar la partición y contar cuántas ya están montadas
@@ -61,8 +73,7 @@ func Mount(driveLetter string, name string) {
 		partName := strings.Trim(string(tempMBR.Partitions[i].Name[:]), "\x00")
 		if strings.ToUpper(partName) == name && tempMBR.Partitions[i].Size != 0 {
 			if tempMBR.Partitions[i].Id != emptyId {
-				OutPut.Println("Error: Partition already mounted")
-				return
+				return "", Results.Errorf(Results.AlreadyExists, "Partition already mounted")
 			}
 			index = i
 		}
@@ -72,8 +83,7 @@ func Mount(driveLetter string, name string) {
 	}
 
 	if index == -1 {
-		OutPut.Println("Error: Partition not found")
-		return
+		return "", Results.Errorf(Results.NotFound, "Partition not found")
 	}
 
 	// Crear ID único: Letra + correlativo + carnet
@@ -85,8 +95,7 @@ func Mount(driveLetter string, name string) {
 
 	// Guardar MBR actualizado
 	if err := Utilities.WriteObject(file, tempMBR, 0); err != nil {
-		OutPut.Println("Error writing MRB to file:", err)
-		return
+		return "", Results.Errorf(Results.IOError, "failed to write MBR: %v", err)
 	}
 
 	// Guardar en el mapa de particiones montadas
@@ -99,58 +108,116 @@ func Mount(driveLetter string, name string) {
 		LoggedIn: false,
 		Start:    int64(tempMBR.Partitions[index].Start),
 	}
+	mountedMu.Lock()
 	mountedPartitions[disk] = append(mountedPartitions[disk], newPart)
+	mountedMu.Unlock()
+
+	out.Println("Partition mounted successfully")
+	Structs.PrintPartition(out, tempMBR.Partitions[index])
+	out.Println("======End MOUNT======")
+	return id, nil
+}
+
+var (
+	mountedPartitions = make(map[string][]MountedPartition) // disco → lista de particiones montadas
+	mountedMu         sync.RWMutex
+)
+
+// SaveState guarda las particiones montadas y el contador de discos. La función
+// retornada los restaura; se usa para descartar lo que cambió un dry-run.
+func SaveState() func() {
+	mountedMu.RLock()
+	saved := make(map[string][]MountedPartition, len(mountedPartitions))
+	for disk, parts := range mountedPartitions {
+		saved[disk] = append([]MountedPartition(nil), parts...)
+	}
+	mountedMu.RUnlock()
+
+	diskCounterMu.Lock()
+	counter := diskCounter
+	diskCounterMu.Unlock()
 
-	OutPut.Println("Partition mounted successfully")
-	Structs.PrintPartition(tempMBR.Partitions[index])
-	OutPut.Println("======End MOUNT======")
+	return func() {
+		mountedMu.Lock()
+		mountedPartitions = saved
+		mountedMu.Unlock()
+
+		diskCounterMu.Lock()
+		diskCounter = counter
+		diskCounterMu.Unlock()
+	}
 }
 
-var mountedPartitions = make(map[string][]MountedPartition) // disco → lista de particiones montadas
+// GetMountedPartitions retorna una copia de las particiones montadas agrupadas por disco
 func GetMountedPartitions() map[string][]MountedPartition {
-	return mountedPartitions
+	mountedMu.RLock()
+	defer mountedMu.RUnlock()
+	mounted := make(map[string][]MountedPartition, len(mountedPartitions))
+	for disk, partitions := range mountedPartitions {
+		mounted[disk] = append([]MountedPartition(nil), partitions...)
+	}
+	return mounted
+}
+
+// NextDiskLetter retorna la letra que recibirá el próximo disco creado con mkdisk
+func NextDiskLetter() string {
+	diskCounterMu.Lock()
+	defer diskCounterMu.Unlock()
+	return string(rune('A' + diskCounter))
+}
+
+// SetLoggedIn marca si hay una sesión iniciada en la partición montada con el id indicado
+func SetLoggedIn(id string, loggedIn bool) {
+	mountedMu.Lock()
+	defer mountedMu.Unlock()
+	for disk := range mountedPartitions {
+		for i := range mountedPartitions[disk] {
+			if mountedPartitions[disk][i].ID == id {
+				mountedPartitions[disk][i].LoggedIn = loggedIn
+				return
+			}
+		}
+	}
 }
 
-func Mkdisk(size int, fit string, unit string) {
-	OutPut.Println("======Start MKDISK======")
-	OutPut.Println("Size:", size, "Fit:", fit, "Unit:", unit)
+func Mkdisk(out *OutPut.Output, size int, fit string, unit string) (string, error) {
+	out.Println("======Start MKDISK======")
+	out.Println("Size:", size, "Fit:", fit, "Unit:", unit)
 
 	// Validate fit
 	if fit != "BF" && fit != "FF" && fit != "WF" {
-		OutPut.Println("Error: Fit must be BF, FF, or WF")
-		return
+		return "", Results.Errorf(Results.InvalidParams, "Fit must be BF, FF, or WF")
 	}
 
 	// Valida el tamaño
 	if size <= 0 {
-		OutPut.Println("Error: Size must be greater than 0")
-		return
+		return "", Results.Errorf(Results.InvalidParams, "Size must be greater than 0")
 	}
 
 	// Valida las unidades
 	if unit != "K" && unit != "M" {
-		OutPut.Println("Error: Unit must be K or M")
-		return
+		return "", Results.Errorf(Results.InvalidParams, "Unit must be K or M")
 	}
 
 	// Genera el nombre del disco
+	diskCounterMu.Lock()
 	diskCounter++
 	diskLetter := string(rune('A' + diskCounter - 1))
+	diskCounterMu.Unlock()
 	basePath := "./tets"
 	filepath := fmt.Sprintf("%s/%s.dsk", basePath, diskLetter)
+	defer Locks.LockDisk(filepath)()
 
 	// Crea el archivo binario
 	err := Utilities.CreateFile(filepath)
 	if err != nil {
-		OutPut.Println("Error creating file:", err)
-		return
+		return "", Results.Errorf(Results.IOError, "failed to create disk: %v", err)
 	}
 
 	//abre el archivo
 	file, err := Utilities.OpenFile(filepath)
 	if err != nil {
-		OutPut.Println("Error opening file:", err)
-		return
+		return "", Results.Errorf(Results.NotFound, "failed to open disk: %v", err)
 	}
 	defer file.Close()
 
@@ -165,8 +232,7 @@ func Mkdisk(size int, fit string, unit string) {
 	zeroBuffer := make([]byte, 1024)
 	for i := 0; i < size/1024; i++ {
 		if err := Utilities.WriteObject(file, zeroBuffer, int64(i*1024)); err != nil {
-			OutPut.Println("Error writing to file:", err)
-			return
+			return "", Results.Errorf(Results.IOError, "failed to write disk: %v", err)
 		}
 	}
 
@@ -177,25 +243,26 @@ func Mkdisk(size int, fit string, unit string) {
 	copy(newMRB.Fit[:], strings.ToUpper(fit))
 	copy(newMRB.CreationDate[:], time.Now().Format("2006-01-02 15:04:05"))
 	if err := Utilities.WriteObject(file, newMRB, 0); err != nil {
-		OutPut.Println("Error writing MRB to file:", err)
-		return
+		return "", Results.Errorf(Results.IOError, "failed to write MBR: %v", err)
 	}
 
 	// Read and verify MRB
 	var tempMBR Structs.MRB
 	if err := Utilities.ReadObject(file, &tempMBR, 0); err != nil {
-		OutPut.Println("Error reading MRB from file:", err)
-		return
+		return "", Results.Errorf(Results.IOError, "failed to read MBR: %v", err)
 	}
 
-	OutPut.Println("File size:", tempMBR.MbrSize)
-	OutPut.Println("Fit:", string(tempMBR.Fit[:]))
-	OutPut.Println("Creation date:", string(tempMBR.CreationDate[:]))
-	OutPut.Println("Signature:", tempMBR.Signature)
-	OutPut.Println("======End MKDISK======")
+	out.Println("File size:", tempMBR.MbrSize)
+	out.Println("Fit:", string(tempMBR.Fit[:]))
+	out.Println("Creation date:", string(tempMBR.CreationDate[:]))
+	out.Println("Signature:", tempMBR.Signature)
+	out.Println("======End MKDISK======")
+	return diskLetter, nil
 }
 
 func GetPartitionPathByID(partitionID string) string {
+	mountedMu.RLock()
+	defer mountedMu.RUnlock()
 	for _, partitions := range mountedPartitions {
 		for _, partition := range partitions {
 			if partition.ID == partitionID {
@@ -222,31 +289,25 @@ func GetPartitionStartByID(id string) int64 {
 	return -1
 }
 
-func Fdisk(size int, driveLetter string, name string, type_ string, fit string, delete string, unit string, add int) {
-	OutPut.Println("======Start FDISK======")
-	OutPut.Println("Size:", size, "Drive Letter:", driveLetter, "Name:", name, "Type:", type_, "Fit:", fit, "Unit:", unit, "Add:", add)
+func Fdisk(out *OutPut.Output, size int, driveLetter string, name string, type_ string, fit string, delete string, unit string, add int, confirm bool) error {
+	out.Println("======Start FDISK======")
+	out.Println("Size:", size, "Drive Letter:", driveLetter, "Name:", name, "Type:", type_, "Fit:", fit, "Unit:", unit, "Add:", add)
 
 	// Validaciones básicas
 	if fit != "B" && fit != "F" && fit != "W" {
-		OutPut.Println("Error: Fit must be B, F, or W")
-		return
+		return Results.Errorf(Results.InvalidParams, "Fit must be B, F, or W")
 	}
 	if type_ != "P" && type_ != "E" {
-		OutPut.Println("Error: Type must be P or E")
-		return
+		return Results.Errorf(Results.InvalidParams, "Type must be P or E")
 	}
 	if delete != "" && delete != "FULL" {
-		OutPut.Println("Error: Delete must be FULL")
-		return
+		return Results.Errorf(Results.InvalidParams, "Delete must be FULL")
 	}
-	//if size <= 0 && delete == "" && add == 0 {
-	if size <= 0 && add == 0 {
-		OutPut.Println("Error: Size must be greater than 0")
-		return
+	if size <= 0 && delete == "" && add == 0 {
+		return Results.Errorf(Results.InvalidParams, "Size must be greater than 0")
 	}
 	if unit != "B" && unit != "K" && unit != "M" {
-		OutPut.Println("Error: Unit must be B, K, or M")
-		return
+		return Results.Errorf(Results.InvalidParams, "Unit must be B, K, or M")
 	}
 
 	// Convertir tamaño y add a bytes
@@ -263,18 +324,18 @@ func Fdisk(size int, driveLetter string, name string, type_ string, fit string,
 
 	// Abrir archivo
 	filepath := fmt.Sprintf("./tets/%s.dsk", strings.ToUpper(driveLetter))
+	defer Locks.LockDisk(filepath)()
+
 	file, err := Utilities.OpenFile(filepath)
 	if err != nil {
-		OutPut.Println("Error opening file:", err)
-		return
+		return Results.Errorf(Results.NotFound, "failed to open disk: %v", err)
 	}
 	defer file.Close()
 
 	// Read MBR
 	var tempMBR Structs.MRB
 	if err := Utilities.ReadObject(file, &tempMBR, 0); err != nil {
-		OutPut.Println("Error reading MRB from file:", err)
-		return
+		return Results.Errorf(Results.IOError, "failed to read MBR: %v", err)
 	}
 
 	// Manejar -add
@@ -284,8 +345,7 @@ func Fdisk(size int, driveLetter string, name string, type_ string, fit string,
 				if addBytes < 0 {
 					// Validar que no quede tamaño negativo
 					if tempMBR.Partitions[i].Size+addBytes <= 0 {
-						OutPut.Println("Error: No se puede reducir tanto el tamaño, resultaría en tamaño negativo")
-						return
+						return Results.Errorf(Results.InvalidParams, "No se puede reducir tanto el tamaño, resultaría en tamaño negativo")
 					}
 					tempMBR.Partitions[i].Size += addBytes
 				} else {
@@ -301,31 +361,27 @@ func Fdisk(size int, driveLetter string, name string, type_ string, fit string,
 						}
 					}
 					if !available {
-						OutPut.Println("Error: No hay espacio contiguo suficiente para expandir la partición")
-						return
+						return Results.Errorf(Results.NoSpace, "No hay espacio contiguo suficiente para expandir la partición")
 					}
 					tempMBR.Partitions[i].Size += addBytes
 				}
 
 				if err := Utilities.WriteObject(file, tempMBR, 0); err != nil {
-					OutPut.Println("Error writing MRB:", err)
-					return
+					return Results.Errorf(Results.IOError, "failed to write MBR: %v", err)
 				}
-				OutPut.Println("Partición actualizada correctamente")
-				Structs.PrintMBR(tempMBR)
-				return
+				out.Println("Partición actualizada correctamente")
+				Structs.PrintMBR(out, tempMBR)
+				return nil
 			}
 		}
-		OutPut.Println("Error: Partición no encontrada para aplicar -add")
-		return
+		return Results.Errorf(Results.NotFound, "Partición no encontrada para aplicar -add")
 	}
 
 	//=======================================================================
 	// Check for duplicate name
 	for i := 0; i < 4; i++ {
 		if strings.Trim(string(tempMBR.Partitions[i].Name[:]), "\x00") == name && tempMBR.Partitions[i].Size != 0 && delete == "" {
-			OutPut.Println("Error: Partition name already exists")
-			return
+			return Results.Errorf(Results.AlreadyExists, "Partition name already exists")
 		}
 	}
 
@@ -333,25 +389,22 @@ func Fdisk(size int, driveLetter string, name string, type_ string, fit string,
 	if delete != "" {
 		for i := 0; i < 4; i++ {
 			if strings.Trim(string(tempMBR.Partitions[i].Name[:]), "\x00") == name && tempMBR.Partitions[i].Size != 0 {
-				fmt.Printf("Confirm deletion of partition %s? (y/n): ", name)
-				var response string
-				fmt.Scanln(&response)
-				if strings.ToLower(response) != "y" {
-					OutPut.Println("Deletion cancelled")
-					return
+				if !confirm {
+					return Results.Errorf(Results.ConfirmationRequired, "¿Está seguro que desea eliminar la partición %s del disco %s.dsk?", name, driveLetter)
+				}
+				if id := strings.Trim(string(tempMBR.Partitions[i].Id[:]), "\x00"); id != "" {
+					Cache.Invalidate(id)
 				}
 				tempMBR.Partitions[i] = Structs.Partition{}
 				if err := Utilities.WriteObject(file, tempMBR, 0); err != nil {
-					OutPut.Println("Error writing MRB:", err)
-					return
+					return Results.Errorf(Results.IOError, "failed to write MBR: %v", err)
 				}
-				OutPut.Println("Partition deleted successfully")
-				Structs.PrintMBR(tempMBR)
-				return
+				out.Println("Partition deleted successfully")
+				Structs.PrintMBR(out, tempMBR)
+				return nil
 			}
 		}
-		OutPut.Println("Error: Partition not found")
-		return
+		return Results.Errorf(Results.NotFound, "Partition not found")
 	}
 
 	// Check extended partition limit
@@ -362,8 +415,7 @@ func Fdisk(size int, driveLetter string, name string, type_ string, fit string,
 		}
 	}
 	if type_ == "e" && extendedCount > 0 {
-		OutPut.Println("Error: Only one extended partition allowed")
-		return
+		return Results.Errorf(Results.AlreadyExists, "Only one extended partition allowed")
 	}
 
 	// Find free space and create partition
@@ -389,64 +441,71 @@ func Fdisk(size int, driveLetter string, name string, type_ string, fit string,
 	}
 
 	if index == -1 {
-		OutPut.Println("Error: No empty partition found")
-		return
+		return Results.Errorf(Results.NoSpace, "No empty partition found")
 	}
 
 	// Write updated MBR
 	if err := Utilities.WriteObject(file, tempMBR, 0); err != nil {
-		OutPut.Println("Error writing MRB to file:", err)
-		return
+		return Results.Errorf(Results.IOError, "failed to write MBR: %v", err)
 	}
 	//=======================================================================
 
-	Structs.PrintMBR(tempMBR)
-	OutPut.Println("======End FDISK======")
+	Structs.PrintMBR(out, tempMBR)
+	out.Println("======End FDISK======")
+	return nil
 }
 
-func Rmdisk(driveLetter string, confirm bool) string {
-	OutPut.Println("======Start RMDISK======")
-	OutPut.Println("Drive Letter:", driveLetter)
+func Rmdisk(out *OutPut.Output, driveLetter string, confirm bool) error {
+	out.Println("======Start RMDISK======")
+	out.Println("Drive Letter:", driveLetter)
 
 	filepath := fmt.Sprintf("./tets/%s.dsk", strings.ToUpper(driveLetter))
-	if _, err := os.Stat(filepath); os.IsNotExist(err) {
-		return "Error: Disk does not exist"
+	defer Locks.LockDisk(filepath)()
+
+	if _, err := Overlay.Stat(filepath); os.IsNotExist(err) {
+		return Results.Errorf(Results.NotFound, "Disk does not exist")
 	}
 
 	if !confirm {
-		return "CONFIRM_RMDISK: ¿Está seguro que desea eliminar el disco " + driveLetter + ".dsk?"
+		return Results.Errorf(Results.ConfirmationRequired, "¿Está seguro que desea eliminar el disco %s.dsk?", driveLetter)
 	}
 
-	if err := os.Remove(filepath); err != nil {
-		return fmt.Sprintf("Error deleting disk: %v", err)
+	// Las cachés de particiones de este disco quedan inválidas
+	Cache.DropDisk(filepath, false)
+
+	if err := Overlay.Remove(filepath); err != nil {
+		return Results.Errorf(Results.IOError, "failed to delete disk: %v", err)
 	}
 
-	OutPut.Println("Disk deleted successfully")
-	OutPut.Println("======End RMDISK======")
-	return "Disk deleted successfully"
+	out.Println("Disk deleted successfully")
+	out.Println("======End RMDISK======")
+	return nil
 }
 
-func Unmount(id string) {
-	OutPut.Println("======Start UNMOUNT======")
-	OutPut.Println("ID:", id)
+func Unmount(out *OutPut.Output, id string) error {
+	out.Println("======Start UNMOUNT======")
+	out.Println("ID:", id)
 
 	_, diskPath, err := stores.GetMountedPartition(id)
 	if err != nil {
-		OutPut.Println("Error:", err)
-		return
+		return Results.Errorf(Results.NotFound, "%v", err)
 	}
+	defer Locks.LockDisk(diskPath)()
 
 	file, err := Utilities.OpenFile(diskPath)
 	if err != nil {
-		OutPut.Println("Error opening file:", err)
-		return
+		return Results.Errorf(Results.NotFound, "failed to open disk: %v", err)
 	}
 	defer file.Close()
 
 	var tempMBR Structs.MRB
 	if err := Utilities.ReadObject(file, &tempMBR, 0); err != nil {
-		OutPut.Println("Error reading MRB:", err)
-		return
+		return Results.Errorf(Results.IOError, "failed to read MBR: %v", err)
+	}
+
+	// Bajar a disco y liberar la caché de la partición antes de desmontarla
+	if err := Cache.Drop(id); err != nil {
+		return Results.Errorf(Results.IOError, "no se pudo guardar la partición: %v", err)
 	}
 
 	for i := 0; i < 4; i++ {
@@ -454,268 +513,473 @@ func Unmount(id string) {
 			tempMBR.Partitions[i].Status = [1]byte{'0'}
 			tempMBR.Partitions[i].Id = [4]byte{}
 			if err := Utilities.WriteObject(file, tempMBR, 0); err != nil {
-				OutPut.Println("Error writing MRB:", err)
-				return
+				return Results.Errorf(Results.IOError, "failed to write MBR: %v", err)
 			}
-			OutPut.Println("Partition unmounted successfully")
-			Structs.PrintMBR(tempMBR)
-			OutPut.Println("======End UNMOUNT======")
-			return
+			out.Println("Partition unmounted successfully")
+			Structs.PrintMBR(out, tempMBR)
+			out.Println("======End UNMOUNT======")
+			return nil
 		}
 	}
 
-	OutPut.Println("Error: Partition not found")
-	OutPut.Println("======End UNMOUNT======")
+	out.Println("======End UNMOUNT======")
+	return Results.Errorf(Results.NotFound, "Partition not found")
 }
 
-// ReportMBR genera un reporte del MBR y lo guarda en la ruta especificada
-func ReportMBR(mbr *Structs.MRB, path string) error {
-	// Crear las carpetas padre si no existen
-	err := Utilities.CreateParentDirs(path)
-	if err != nil {
-		return err
-	}
+// Los reportes se generan en dos pasos: una función XxxData reúne los datos de
+// la partición (la API también los sirve como JSON) y XxxReport los dibuja con el
+// paquete Render o los escribe como texto.
+
+// MBRData son los datos del reporte mbr
+type MBRData struct {
+	Size         int64           `json:"size"`
+	CreationDate string          `json:"creationDate"`
+	Signature    int32           `json:"signature"`
+	Fit          string          `json:"fit"`
+	Partitions   []PartitionData `json:"partitions"`
+}
 
-	// Obtener el nombre base del archivo sin la extensión
-	dotFileName, OutPutImage := Utilities.GetFileNames(path)
+// PartitionData es una entrada de la tabla de particiones del MBR
+type PartitionData struct {
+	Status string `json:"status"`
+	Type   string `json:"type"`
+	Fit    string `json:"fit"`
+	Start  int64  `json:"start"`
+	Size   int64  `json:"size"`
+	Name   string `json:"name"`
+}
 
-	// Definir el contenido DOT con una tabla
-	dotContent := fmt.Sprintf(`digraph G {
-        node [shape=plaintext]
-        tabla [label=<
-            <table border="0" cellborder="1" cellspacing="0">
-                <tr><td colspan="2"> REPORTE MBR </td></tr>
-                <tr><td>mbr_tamano</td><td>%d</td></tr>
-                <tr><td>mrb_fecha_creacion</td><td>%s</td></tr>
-                <tr><td>mbr_disk_signature</td><td>%d</td></tr>
-			`, mbr.MbrSize, strings.TrimRight(string(mbr.CreationDate[:]), "\x00"), mbr.Signature)
+// MBRReportData retorna los datos del MBR y de sus cuatro particiones
+func MBRReportData(mbr *Structs.MRB) MBRData {
+	data := MBRData{
+		Size:         mbr.MbrSize,
+		CreationDate: strings.TrimRight(string(mbr.CreationDate[:]), "\x00"),
+		Signature:    mbr.Signature,
+		Fit:          strings.Trim(string(mbr.Fit[:]), "\x00"),
+	}
+	for _, part := range mbr.Partitions {
+		data.Partitions = append(data.Partitions, PartitionData{
+			Status: strings.Trim(string(part.Status[:]), "\x00"),
+			Type:   strings.Trim(string(part.Type[:]), "\x00"),
+			Fit:    strings.Trim(string(part.Fit[:]), "\x00"),
+			Start:  part.Start,
+			Size:   part.Size,
+			Name:   strings.TrimRight(string(part.Name[:]), "\x00"),
+		})
+	}
+	return data
+}
 
-	// Agregar las particiones a la tabla
-	for i, part := range mbr.Partitions {
-		/*
-			// Continuar si el tamaño de la partición es -1 (o sea, no está asignada)
-			if part.Part_size == -1 {
-				continue
-			}
-		*/
-
-		// Convertir Part_name a string y eliminar los caracteres nulos
-		partName := strings.TrimRight(string(part.Name[:]), "\x00")
-		// Convertir Part_status, Part_type y Part_fit a char
-		partStatus := rune(part.Status[0])
-		partType := rune(part.Type[0])
-		partFit := rune(part.Fit[0])
-
-		// Agregar la partición a la tabla
-		dotContent += fmt.Sprintf(`
-				<tr><td colspan="2"> PARTICIÓN %d </td></tr>
-				<tr><td>part_status</td><td>%c</td></tr>
-				<tr><td>part_type</td><td>%c</td></tr>
-				<tr><td>part_fit</td><td>%c</td></tr>
-				<tr><td>part_start</td><td>%d</td></tr>
-				<tr><td>part_size</td><td>%d</td></tr>
-				<tr><td>part_name</td><td>%s</td></tr>
-			`, i+1, partStatus, partType, partFit, part.Start, part.Size, partName)
-	}
-
-	// Cerrar la tabla y el contenido DOT
-	dotContent += "</table>>] }"
-
-	// Guardar el contenido DOT en un archivo
-	file, err := os.Create(dotFileName)
-	if err != nil {
-		return fmt.Errorf("error al crear el archivo: %v", err)
-	}
-	defer file.Close()
+// ReportMBR genera un reporte del MBR y lo guarda en la ruta especificada
+func ReportMBR(out *OutPut.Output, mbr *Structs.MRB, path string) error {
+	data := MBRReportData(mbr)
 
-	_, err = file.WriteString(dotContent)
-	if err != nil {
-		return fmt.Errorf("error al escribir en el archivo: %v", err)
+	// Tabla con los datos del MBR y de cada partición
+	table := &Render.Table{}
+	table.Header("REPORTE MBR", "#B3D9FF")
+	table.Field("mbr_tamano", data.Size)
+	table.Field("mrb_fecha_creacion", data.CreationDate)
+	table.Field("mbr_disk_signature", data.Signature)
+
+	// Agregar las particiones a la tabla
+	for i, part := range data.Partitions {
+		table.Header(fmt.Sprintf("PARTICIÓN %d", i+1), "#E6F3FF")
+		table.Field("part_status", part.Status)
+		table.Field("part_type", part.Type)
+		table.Field("part_fit", part.Fit)
+		table.Field("part_start", part.Start)
+		table.Field("part_size", part.Size)
+		table.Field("part_name", part.Name)
 	}
 
-	// Ejecutar el comando Graphviz para generar la imagen
-	cmd := exec.Command("dot", "-Tpng", dotFileName, "-o", OutPutImage)
-	err = cmd.Run()
+	OutPutImage, err := Render.Write(Render.Single("", table), path)
 	if err != nil {
-		return fmt.Errorf("error al ejecutar el comando Graphviz: %v", err)
+		return err
 	}
 
-	OutPut.Println("Imagen de la tabla generada:", OutPutImage)
+	out.Println("Imagen de la tabla generada:", OutPutImage)
 	return nil
 }
 
-// DiskReport
-func DiskReport(id string, OutPutPath string) error {
+// DiskData son los datos del reporte disk: el disco dividido en secciones. Las
+// particiones que se salen del disco o se superponen con otra no se dibujan y se
+// describen en Errors.
+type DiskData struct {
+	Disk     string        `json:"disk"`
+	Size     int64         `json:"size"`
+	Sections []DiskSection `json:"sections"`
+	Errors   []string      `json:"errors,omitempty"`
+}
+
+// DiskSection es una parte del disco: el MBR, una partición primaria, la
+// partición extendida o espacio libre. La extendida contiene sus EBR, sus
+// particiones lógicas y su espacio libre en Children.
+type DiskSection struct {
+	Kind     string        `json:"kind"` // mbr, primary, extended, ebr, logical o free
+	Name     string        `json:"name,omitempty"`
+	Start    int64         `json:"start"`
+	Size     int64         `json:"size"`
+	Percent  float64       `json:"percent"` // respecto al tamaño total del disco
+	Children []DiskSection `json:"children,omitempty"`
+}
+
+// End retorna el byte siguiente al último de la sección
+func (s DiskSection) End() int64 {
+	return s.Start + s.Size
+}
+
+// DiskReportData retorna las secciones del disco que contiene la partición id
+func DiskReportData(id string) (DiskData, error) {
 	// 1. Obtain disk path from partition ID.
 	diskPath := GetPartitionPathByID(id)
 	if diskPath == "" {
-		return errors.New("no se encontró una partición montada con ID " + id)
+		return DiskData{}, errors.New("no se encontró una partición montada con ID " + id)
 	}
+	defer Locks.RLockDisk(diskPath)()
 
 	// 2. Open the disk file.
 	file, err := Utilities.OpenFile(diskPath)
 	if err != nil {
-		return fmt.Errorf("no se pudo abrir el archivo: %v", err)
+		return DiskData{}, fmt.Errorf("no se pudo abrir el archivo: %v", err)
 	}
 	defer file.Close()
 
 	// 3. Read the MBR.
 	var mbr Structs.MRB
 	if err := Utilities.ReadObject(file, &mbr, 0); err != nil {
-		return fmt.Errorf("no se pudo leer el MBR: %v", err)
+		return DiskData{}, fmt.Errorf("no se pudo leer el MBR: %v", err)
 	}
 
-	// 4. Define struct for partition information (primary only).
-	type partitionInfo struct {
-		Name   string
-		Size   int32
-		Start  int32
-		Type   byte
-		Fit    byte
-		Status byte
+	// 4. Disk name without the directory.
+	fileName := diskPath
+	if lastSlash := strings.LastIndex(diskPath, "/"); lastSlash != -1 {
+		fileName = diskPath[lastSlash+1:]
+	}
+	data := DiskData{Disk: fileName, Size: mbr.MbrSize}
+	toPercent := func(size int64) float64 {
+		if mbr.MbrSize == 0 {
+			return 0
+		}
+		return float64(size) * 100.0 / float64(mbr.MbrSize)
 	}
 
-	// 5. Collect active primary partitions (Status='1' or '0').
-	var partitions []partitionInfo
+	// 5. Collect the partitions of the MBR, discarding the ones outside the disk.
+	mbrSize := int64(binary.Size(mbr))
+	var partitions []DiskSection
 	for i := 0; i < 4; i++ {
 		p := mbr.Partitions[i]
-		if (p.Status[0] == '1' || p.Status[0] == '0') && (p.Type[0] == 'p' || p.Type[0] == 'P') {
-			pi := partitionInfo{
-				Name:   strings.TrimRight(string(p.Name[:]), "\x00 "),
-				Size:   int32(p.Size),
-				Start:  int32(p.Start),
-				Type:   p.Type[0],
-				Fit:    p.Fit[0],
-				Status: p.Status[0],
-			}
-			partitions = append(partitions, pi)
+		if p.Size == 0 {
+			continue
+		}
+		name := strings.TrimRight(string(p.Name[:]), "\x00 ")
+		kind := ""
+		switch p.Type[0] {
+		case 'p', 'P':
+			kind = "primary"
+		case 'e', 'E':
+			kind = "extended"
+		default:
+			data.Errors = append(data.Errors, fmt.Sprintf("la partición %s tiene un tipo desconocido %q", name, p.Type[0]))
+			continue
+		}
+		if p.Size < 0 || p.Start < mbrSize || p.Start+p.Size > mbr.MbrSize {
+			data.Errors = append(data.Errors, fmt.Sprintf("la partición %s (inicio %d, tamaño %d) está fuera de los límites del disco", name, p.Start, p.Size))
+			continue
 		}
+		partitions = append(partitions, DiskSection{Kind: kind, Name: name, Start: p.Start, Size: p.Size, Percent: toPercent(p.Size)})
 	}
 
-	// 6. Sort partitions by start position.
-	sort.Slice(partitions, func(i, j int) bool {
+	// 6. Sort partitions by start position and discard the overlapping ones.
+	sort.SliceStable(partitions, func(i, j int) bool {
 		return partitions[i].Start < partitions[j].Start
 	})
+	partitions = dropOverlaps(partitions, &data.Errors)
+
+	// 7. The MBR is the first section; the free gaps go between the partitions.
+	data.Sections = append(data.Sections, DiskSection{Kind: "mbr", Start: 0, Size: mbrSize, Percent: toPercent(mbrSize)})
+	data.Sections = append(data.Sections, withGaps(partitions, mbrSize, mbr.MbrSize, toPercent)...)
 
-	// 7. Calculate used and free space.
-	usedSpace := int32(0)
-	for _, pi := range partitions {
-		usedSpace += pi.Size
+	// 8. Read the EBR chain of the extended partition.
+	for i, section := range data.Sections {
+		if section.Kind == "extended" {
+			children := readLogicalPartitions(file, section, toPercent, &data.Errors)
+			data.Sections[i].Children = withGaps(children, section.Start, section.End(), toPercent)
+		}
 	}
-	freeSpace := int32(mbr.MbrSize) - usedSpace
+	return data, nil
+}
 
-	// 8. Initialize DOT content.
-	var buffer strings.Builder
-	buffer.WriteString(`digraph DISK_Report {
-    rankdir=LR;
-    graph [bgcolor="#ffffff", fontsize=12, labelloc="t", labeljust="c"];
-    node [shape=none];
-    `)
+// readLogicalPartitions recorre la cadena de EBR de la partición extendida y
+// retorna cada EBR seguido de su partición lógica. El primer EBR está al inicio
+// de la extendida; fdisk todavía no escribe EBR, así que si el registro no parece
+// un EBR (validEBR) la extendida se muestra como espacio libre.
+func readLogicalPartitions(file *os.File, extended DiskSection, toPercent func(int64) float64, errs *[]string) []DiskSection {
+	ebrSize := int64(binary.Size(Structs.EBR{}))
+	var sections []DiskSection
+	visited := map[int64]bool{}
+	for position := extended.Start; ; {
+		if position < extended.Start || position+ebrSize > extended.End() {
+			*errs = append(*errs, fmt.Sprintf("el EBR en %d está fuera de la partición extendida %s", position, extended.Name))
+			break
+		}
+		if visited[position] {
+			*errs = append(*errs, fmt.Sprintf("la cadena de EBR de %s vuelve al EBR en %d", extended.Name, position))
+			break
+		}
+		visited[position] = true
 
-	// Add disk title with name and total size.
-	fileName := diskPath
-	if lastSlash := strings.LastIndex(diskPath, "/"); lastSlash != -1 {
-		fileName = diskPath[lastSlash+1:]
+		var ebr Structs.EBR
+		if err := Utilities.ReadObject(file, &ebr, position); err != nil {
+			*errs = append(*errs, fmt.Sprintf("no se pudo leer el EBR en %d: %v", position, err))
+			break
+		}
+		if position == extended.Start && !validEBR(ebr, position, extended) {
+			break
+		}
+		sections = append(sections, DiskSection{Kind: "ebr", Start: position, Size: ebrSize, Percent: toPercent(ebrSize)})
+
+		if ebr.Size != 0 {
+			name := strings.TrimRight(string(ebr.Name[:]), "\x00 ")
+			switch {
+			case ebr.Size < 0 || ebr.Start+ebr.Size > extended.End():
+				*errs = append(*errs, fmt.Sprintf("la partición lógica %s (inicio %d, tamaño %d) está fuera de la partición extendida %s", name, ebr.Start, ebr.Size, extended.Name))
+			case ebr.Start < position+ebrSize:
+				*errs = append(*errs, fmt.Sprintf("la partición lógica %s (inicio %d, tamaño %d) empieza antes del final de su EBR en %d", name, ebr.Start, ebr.Size, position))
+			default:
+				sections = append(sections, DiskSection{Kind: "logical", Name: name, Start: ebr.Start, Size: ebr.Size, Percent: toPercent(ebr.Size)})
+			}
+		}
+
+		if ebr.Next <= 0 {
+			break
+		}
+		position = ebr.Next
+	}
+
+	sort.SliceStable(sections, func(i, j int) bool {
+		return sections[i].Start < sections[j].Start
+	})
+	return dropOverlaps(sections, errs)
+}
+
+// validEBR indica si el registro leído en position parece un EBR, para no mostrar
+// como particiones lógicas los bytes sin escribir o sobrantes de otro uso de la
+// región: tiene tamaño o siguiente, su partición lógica empieza después de él y
+// queda dentro de la extendida, y el siguiente EBR también está dentro.
+func validEBR(ebr Structs.EBR, position int64, extended DiskSection) bool {
+	ebrSize := int64(binary.Size(Structs.EBR{}))
+	switch {
+	case ebr.Size == 0 && ebr.Next == 0:
+		return false
+	case ebr.Size < 0:
+		return false
+	case ebr.Size > 0 && (ebr.Start < position+ebrSize || ebr.Start+ebr.Size > extended.End()):
+		return false
+	}
+	return ebr.Next <= 0 || (ebr.Next >= extended.Start && ebr.Next+ebrSize <= extended.End())
+}
+
+// dropOverlaps quita de sections, ordenadas por inicio, las que empiezan antes de
+// que termine la anterior y agrega un error por cada una
+func dropOverlaps(sections []DiskSection, errs *[]string) []DiskSection {
+	var kept []DiskSection
+	for _, section := range sections {
+		if len(kept) > 0 && section.Start < kept[len(kept)-1].End() {
+			prev := kept[len(kept)-1]
+			*errs = append(*errs, fmt.Sprintf("%s se superpone con %s", describeSection(section), describeSection(prev)))
+			continue
+		}
+		kept = append(kept, section)
+	}
+	return kept
+}
+
+// describeSection nombra una sección en los mensajes de error
+func describeSection(section DiskSection) string {
+	switch section.Kind {
+	case "ebr":
+		return fmt.Sprintf("el EBR en %d", section.Start)
+	case "logical":
+		return fmt.Sprintf("la partición lógica %s (inicio %d, tamaño %d)", section.Name, section.Start, section.Size)
+	default:
+		return fmt.Sprintf("la partición %s (inicio %d, tamaño %d)", section.Name, section.Start, section.Size)
+	}
+}
+
+// withGaps agrega secciones libres entre sections, ordenadas y sin superponerse,
+// para cubrir desde start hasta end
+func withGaps(sections []DiskSection, start, end int64, toPercent func(int64) float64) []DiskSection {
+	var result []DiskSection
+	cursor := start
+	for _, section := range sections {
+		if section.Start > cursor {
+			result = append(result, DiskSection{Kind: "free", Start: cursor, Size: section.Start - cursor, Percent: toPercent(section.Start - cursor)})
+		}
+		result = append(result, section)
+		cursor = section.End()
+	}
+	if end > cursor {
+		result = append(result, DiskSection{Kind: "free", Start: cursor, Size: end - cursor, Percent: toPercent(end - cursor)})
+	}
+	return result
+}
+
+// DiskReport dibuja el disco como una fila con una celda por sección. Si hay una
+// partición extendida, su celda ocupa una segunda fila con sus EBR, sus
+// particiones lógicas y su espacio libre.
+func DiskReport(out *OutPut.Output, id string, OutPutPath string) error {
+	data, err := DiskReportData(id)
+	if err != nil {
+		return err
 	}
-	buffer.WriteString(fmt.Sprintf(`label="DISCO: %s (Tamaño Total: %d bytes)";`, fileName, mbr.MbrSize))
-	buffer.WriteString("\n")
 
-	// Function to calculate percentage.
-	toPercent := func(size, total int32) string {
-		if total == 0 {
-			return "0%"
+	extended := slices.ContainsFunc(data.Sections, func(s DiskSection) bool { return s.Kind == "extended" })
+	table := &Render.Table{}
+	if !extended {
+		var cells []Render.Cell
+		for _, section := range data.Sections {
+			cells = append(cells, diskCell(section))
+		}
+		table.Row(cells...)
+	} else {
+		// Las secciones fuera de la extendida ocupan las dos filas: el tipo arriba
+		// y el detalle abajo
+		var top, bottom []Render.Cell
+		for _, section := range data.Sections {
+			if section.Kind != "extended" {
+				cell := diskCell(section)
+				title, detail, _ := strings.Cut(cell.Text, "\n")
+				top = append(top, Render.Cell{Text: title, Bold: true, Color: cell.Color})
+				bottom = append(bottom, Render.Cell{Text: detail, Color: cell.Color})
+				continue
+			}
+			top = append(top, Render.Cell{
+				Text:  fmt.Sprintf("Extendida %s (%.2f%%)\nInicio: %d  Tamaño: %d bytes", section.Name, section.Percent, section.Start, section.Size),
+				Bold:  true,
+				Color: "#FFF3E0",
+				Span:  max(len(section.Children), 1),
+			})
+			if len(section.Children) == 0 {
+				bottom = append(bottom, Render.Cell{Color: "#FFF3E0"})
+			}
+			for _, child := range section.Children {
+				bottom = append(bottom, diskCell(child))
+			}
 		}
-		return fmt.Sprintf("%.2f%%", float64(size)*100.0/float64(total))
-	}
-
-	// Calculate MBR size.
-	mbrSize := int32(binary.Size(mbr))
-
-	// 9. Create node with table for partitions.
-	buffer.WriteString("disk [label=<\n")
-	buffer.WriteString(`<TABLE BORDER="1" CELLBORDER="1" CELLSPACING="0" CELLPADDING="4">
-    <TR>`)
-
-	// Add MBR cell.
-	buffer.WriteString(fmt.Sprintf(`
-        <TD BGCOLOR="#B3E5FC" PORT="mbr">
-            <B>MBR</B><br/>
-            Inicio: 0<br/>
-            Tamaño: %d bytes<br/>
-        </TD>
-    `, mbrSize))
-
-	// 10. Add cells for primary partitions.
-	for _, pi := range partitions {
-		percentage := toPercent(pi.Size, int32(mbr.MbrSize))
-		bgColor := "#E8F5E9" // Green for primary partitions.
-		title := fmt.Sprintf("Primaria<br/>%s<br/>(%s)", pi.Name, percentage)
-		buffer.WriteString(fmt.Sprintf(`
-        <TD BGCOLOR="%s" PORT="%s">
-            <B>%s</B><br/>
-            Inicio: %d<br/>
-            Tamaño: %d bytes
-        </TD>
-        `, bgColor, pi.Name, title, pi.Start, pi.Size))
-	}
-
-	// 11. Add free space cell if applicable.
-	if freeSpace > 0 {
-		freePercent := toPercent(freeSpace, int32(mbr.MbrSize))
-		buffer.WriteString(fmt.Sprintf(`
-        <TD BGCOLOR="#ECEFF1">
-            <B>Libre</B><br/>
-            %d bytes<br/>
-            (%s)
-        </TD>`, freeSpace, freePercent))
-	}
-
-	buffer.WriteString("</TR>\n</TABLE>\n>];\n")
-
-	// 12. Close the graph.
-	buffer.WriteString("}\n")
-
-	// 13. Write DOT file to disk.
-	dotFile := OutPutPath + ".dot"
-	if err := os.WriteFile(dotFile, []byte(buffer.String()), 0644); err != nil {
-		return fmt.Errorf("no se pudo escribir el archivo DOT: %v", err)
-	}
-
-	// 14. Execute Graphviz to generate PNG.
-	cmd := exec.Command("dot", "-Tpng", "-Gdpi=300", dotFile, "-o", OutPutPath)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("error al ejecutar dot: %v", err)
-	}
-	OutPut.Println("Reporte de disco generado en:", OutPutPath)
+		table.Row(top...)
+		table.Row(bottom...)
+	}
+	for _, message := range data.Errors {
+		table.Row(Render.Cell{Text: "Error: " + message, Color: "#FFCDD2", Span: Render.All, Left: true})
+		out.Warning("Reporte disk:", message)
+	}
+
+	title := fmt.Sprintf("DISCO: %s (Tamaño Total: %d bytes)", data.Disk, data.Size)
+	OutPutPath, err = Render.Write(Render.Single(title, table), OutPutPath)
+	if err != nil {
+		return err
+	}
+	out.Println("Reporte de disco generado en:", OutPutPath)
 	return nil
 }
 
-func InodeReport(id string, OutPutPath string) error {
+// diskCell retorna la celda de una sección del disco; la primera línea es el tipo
+func diskCell(section DiskSection) Render.Cell {
+	switch section.Kind {
+	case "mbr":
+		return Render.Cell{
+			Text:  fmt.Sprintf("MBR\nInicio: 0\nTamaño: %d bytes", section.Size),
+			Color: "#B3E5FC",
+		}
+	case "primary":
+		return Render.Cell{
+			Text:  fmt.Sprintf("Primaria\n%s\n(%.2f%%)\nInicio: %d\nTamaño: %d bytes", section.Name, section.Percent, section.Start, section.Size),
+			Color: "#E8F5E9", // Green for primary partitions.
+		}
+	case "ebr":
+		return Render.Cell{
+			Text:  fmt.Sprintf("EBR\nInicio: %d\nTamaño: %d bytes", section.Start, section.Size),
+			Color: "#FFE0B2",
+		}
+	case "logical":
+		return Render.Cell{
+			Text:  fmt.Sprintf("Lógica\n%s\n(%.2f%%)\nInicio: %d\nTamaño: %d bytes", section.Name, section.Percent, section.Start, section.Size),
+			Color: "#FFF8E1",
+		}
+	default:
+		return Render.Cell{
+			Text:  fmt.Sprintf("Libre\n%d bytes\n(%.2f%%)", section.Size, section.Percent),
+			Color: "#ECEFF1",
+		}
+	}
+}
+
+// InodeData son los datos de un inodo
+type InodeData struct {
+	Index  int       `json:"index"`
+	Type   string    `json:"type"` // directorio o archivo
+	UID    int32     `json:"uid"`
+	GID    int32     `json:"gid"`
+	Size   int32     `json:"size"`
+	ATime  string    `json:"atime"`
+	CTime  string    `json:"ctime"`
+	MTime  string    `json:"mtime"`
+	Perm   string    `json:"perm"`
+	Blocks [15]int32 `json:"blocks"` // I_block; -1 es un apuntador sin usar
+}
+
+// NewInodeData convierte un inodo leído del disco
+func NewInodeData(index int, inode Structs.Inode) InodeData {
+	inodeType := "archivo"
+	if inode.I_type[0] == '0' {
+		inodeType = "directorio"
+	}
+	return InodeData{
+		Index:  index,
+		Type:   inodeType,
+		UID:    inode.I_uid,
+		GID:    inode.I_gid,
+		Size:   inode.I_size,
+		ATime:  strings.Trim(string(inode.I_atime[:]), "\x00"),
+		CTime:  strings.Trim(string(inode.I_ctime[:]), "\x00"),
+		MTime:  strings.Trim(string(inode.I_mtime[:]), "\x00"),
+		Perm:   strings.Trim(string(inode.I_perm[:]), "\x00"),
+		Blocks: inode.I_block,
+	}
+}
+
+// InodeReportData retorna los inodos en uso de la partición, en orden
+func InodeReportData(ctx context.Context, out *OutPut.Output, id string) ([]InodeData, error) {
 	// Obtener la ruta del disco
 	partitionPath := GetPartitionPathByID(id)
 	if partitionPath == "" {
-		return fmt.Errorf("no se encontró la ruta para el id: %s", id)
+		return nil, fmt.Errorf("no se encontró la ruta para el id: %s", id)
 	}
+	defer Locks.RLockPartition(partitionPath, id)()
+
+	// Bajar a disco los cambios cacheados antes de leer la partición directamente
+	if err := Cache.Flush(id); err != nil {
+		return nil, err
+	}
+
 	file, err := Utilities.OpenFile(partitionPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer file.Close()
 
 	// Obtener el inicio de la partición
 	partitionStart := GetPartitionStartByID(id)
-	fmt.Printf("Partition start: %d\n", partitionStart)
 
 	if partitionStart < 0 {
-		return fmt.Errorf("no se encontró la partición con el id %s", id)
+		return nil, fmt.Errorf("no se encontró la partición con el id %s", id)
 	}
 
 	// Leer el superblock desde el inicio de la partición
 	var sb Structs.Superblock
 	if err := Utilities.ReadObject(file, &sb, partitionStart); err != nil {
-		return err
+		return nil, err
 	}
-	fmt.Printf("Superblock leído: S_inode_start=%d\n", sb.S_inode_start)
 
 	// Aquí usamos S_inode_start directamente (ya es absoluto)
 	inodeStart := int64(sb.S_inode_start)
@@ -725,30 +989,26 @@ func InodeReport(id string, OutPutPath string) error {
 	// Obtener tamaño del archivo para verificar límites
 	fileInfo, err := file.Stat()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	fileSize := fileInfo.Size()
 
-	// Generar reporte DOT
-	var buffer strings.Builder
-	buffer.WriteString("digraph Inode_Report {\n")
-	buffer.WriteString("    rankdir=TB;\n")
-	buffer.WriteString("    node [fontname=\"Arial\", shape=plain, style=\"filled\", fontsize=10];\n")
-	buffer.WriteString("    edge [dir=none];\n")
-	buffer.WriteString("    graph [bgcolor=\"#ffffff\", pencolor=\"#333333\", penwidth=2.0, style=\"rounded\"];\n\n")
-
-	var lastInodeIndex int = -1 // Para almacenar el índice del último inodo válido
+	inodes := []InodeData{}
 
 	// Recorrer la tabla de inodos.
 	for i := 0; i < int(count); i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		Jobs.Progress(ctx, i, int(count))
 		pos := inodeStart + (inodeSize * int64(i))
 		if pos+inodeSize > fileSize {
-			fmt.Printf("Se alcanzó el final del archivo en el inodo %d (offset: %d, archivo: %d bytes)\n", i, pos, fileSize)
+			out.Warningf("Se alcanzó el final del archivo en el inodo %d (offset: %d, archivo: %d bytes)\n", i, pos, fileSize)
 			break
 		}
 		var inode Structs.Inode
 		if err := Utilities.ReadObject(file, &inode, pos); err != nil {
-			return err
+			return nil, err
 		}
 		// Verificar si el inodo está en uso
 		used := inode.I_size > 0
@@ -762,55 +1022,72 @@ func InodeReport(id string, OutPutPath string) error {
 			continue
 		}
 
+		inodes = append(inodes, NewInodeData(i, inode))
+	}
+	return inodes, nil
+}
+
+// InodeReport dibuja los inodos en uso encadenados en orden
+func InodeReport(ctx context.Context, out *OutPut.Output, id string, OutPutPath string) error {
+	inodes, err := InodeReportData(ctx, out, id)
+	if err != nil {
+		return err
+	}
+	if Export.Supported(OutPutPath) {
+		table := &Export.Table{
+			Title:   "Reporte de inodos - partición " + id,
+			Columns: []string{"index", "type", "uid", "gid", "size", "atime", "ctime", "mtime", "perm", "blocks"},
+		}
+		for _, inode := range inodes {
+			blocks := make([]string, len(inode.Blocks))
+			for j, b := range inode.Blocks {
+				blocks[j] = fmt.Sprint(b)
+			}
+			table.Row(inode.Index, inode.Type, inode.UID, inode.GID, inode.Size, inode.ATime, inode.CTime, inode.MTime, inode.Perm, strings.Join(blocks, " "))
+		}
+		if OutPutPath, err = Export.Write(table, OutPutPath); err != nil {
+			return err
+		}
+		out.Println("Reporte de inodos generado en:", OutPutPath)
+		return nil
+	}
+
+	graph := &Render.Graph{}
+	lastInode := "" // Para almacenar el último inodo válido
+	for _, inode := range inodes {
 		// Construir cadena de bloques asignados
-		blocksStr := ""
-		for j, b := range inode.I_block {
+		var blocks []string
+		for j, b := range inode.Blocks {
 			if b != -1 {
-				blocksStr += fmt.Sprintf("Bloque%d: %d\\n", j, b)
+				blocks = append(blocks, fmt.Sprintf("Bloque%d: %d", j, b))
 			}
 		}
-		atime := strings.Trim(string(inode.I_atime[:]), "\x00")
-		ctime := strings.Trim(string(inode.I_ctime[:]), "\x00")
-		mtime := strings.Trim(string(inode.I_mtime[:]), "\x00")
-		perm := strings.Trim(string(inode.I_perm[:]), "\x00")
-
-		// Construcción del nodo inodo en DOT
-		buffer.WriteString(fmt.Sprintf("    inode%d [\n", i))
-		buffer.WriteString("        fillcolor=\"#E6F3FF\",\n")
-		buffer.WriteString("        label=<\n")
-		buffer.WriteString("            <TABLE BORDER=\"0\" CELLBORDER=\"1\" CELLSPACING=\"0\" CELLPADDING=\"4\" BGCOLOR=\"#E6F3FF\">\n")
-		buffer.WriteString(fmt.Sprintf("                <TR><TD COLSPAN=\"2\" BGCOLOR=\"#B3D9FF\"><B>Inodo %d</B></TD></TR>\n", i))
-		buffer.WriteString(fmt.Sprintf("                <TR><TD><B>UID</B></TD><TD>%d</TD></TR>\n", inode.I_uid))
-		buffer.WriteString(fmt.Sprintf("                <TR><TD><B>GID</B></TD><TD>%d</TD></TR>\n", inode.I_gid))
-		buffer.WriteString(fmt.Sprintf("                <TR><TD><B>Tamaño</B></TD><TD>%d bytes</TD></TR>\n", inode.I_size))
-		buffer.WriteString(fmt.Sprintf("                <TR><TD><B>ATime</B></TD><TD>%s</TD></TR>\n", atime))
-		buffer.WriteString(fmt.Sprintf("                <TR><TD><B>CTime</B></TD><TD>%s</TD></TR>\n", ctime))
-		buffer.WriteString(fmt.Sprintf("                <TR><TD><B>MTime</B></TD><TD>%s</TD></TR>\n", mtime))
-		buffer.WriteString(fmt.Sprintf("                <TR><TD><B>Permisos</B></TD><TD>%s</TD></TR>\n", perm))
-		buffer.WriteString(fmt.Sprintf("                <TR><TD><B>Bloques</B></TD><TD>%s</TD></TR>\n", blocksStr))
-		buffer.WriteString("            </TABLE>\n")
-		buffer.WriteString("        >\n")
-		buffer.WriteString("    ];\n\n")
 
+		// Tabla del inodo
+		table := &Render.Table{Color: "#E6F3FF"}
+		table.Header(fmt.Sprintf("Inodo %d", inode.Index), "#B3D9FF")
+		table.Field("UID", inode.UID)
+		table.Field("GID", inode.GID)
+		table.Field("Tamaño", fmt.Sprintf("%d bytes", inode.Size))
+		table.Field("ATime", inode.ATime)
+		table.Field("CTime", inode.CTime)
+		table.Field("MTime", inode.MTime)
+		table.Field("Permisos", inode.Perm)
+		table.Field("Bloques", strings.Join(blocks, "\n"))
+
+		node := graph.Add(fmt.Sprintf("inode%d", inode.Index), table)
 		// Si hay un inodo previo válido, conectar con el actual
-		if lastInodeIndex != -1 {
-			buffer.WriteString(fmt.Sprintf("    inode%d -> inode%d;\n", lastInodeIndex, i))
+		if lastInode != "" {
+			graph.Connect(lastInode, node.ID)
 		}
-		lastInodeIndex = i // Guardar el índice del último inodo válido
-	}
-	buffer.WriteString("}\n")
-
-	// Guardar el archivo DOT
-	dotFile := OutPutPath + ".dot"
-	if err := os.WriteFile(dotFile, []byte(buffer.String()), 0644); err != nil {
-		return err
+		lastInode = node.ID
 	}
 
-	// Generar el PNG con Graphviz
-	cmd := exec.Command("dot", "-Tpng", "-Gdpi=300", dotFile, "-o", OutPutPath+".png")
-	if err := cmd.Run(); err != nil {
+	OutPutPath, err = Render.WriteContext(ctx, graph, OutPutPath)
+	if err != nil {
 		return err
 	}
+	out.Println("Reporte de inodos generado en:", OutPutPath)
 	return nil
 }
 
@@ -821,7 +1098,7 @@ func parseBlock(rawBlock []byte) (string, string) {
 		for i, content := range fblock.B_content {
 			name := strings.TrimRight(string(content.B_name[:]), "\x00")
 			if name != "" || content.B_inodo != 0 {
-				label.WriteString(fmt.Sprintf("Carpeta[%d]: %s (Inodo: %d)<br/>", i, name, content.B_inodo))
+				label.WriteString(fmt.Sprintf("Carpeta[%d]: %s (Inodo: %d)\n", i, name, content.B_inodo))
 			}
 		}
 		if label.Len() > 0 {
@@ -833,7 +1110,7 @@ func parseBlock(rawBlock []byte) (string, string) {
 	if err := binary.Read(bytes.NewReader(rawBlock), binary.LittleEndian, &ffile); err == nil {
 		contentStr := strings.TrimRight(string(ffile.B_content[:]), "\x00")
 		if len(contentStr) > 0 && strings.IndexFunc(contentStr, func(r rune) bool { return r < 32 && r != 10 }) == -1 {
-			return "Archivo", fmt.Sprintf("Datos (64 bytes): <br/>%s", contentStr)
+			return "Archivo", fmt.Sprintf("Datos (64 bytes): \n%s", contentStr)
 		}
 	}
 
@@ -842,7 +1119,7 @@ func parseBlock(rawBlock []byte) (string, string) {
 		var label strings.Builder
 		for i, ptr := range pblock.B_pointers {
 			if ptr > 0 {
-				label.WriteString(fmt.Sprintf("Puntero[%d] = %d<br/>", i, ptr))
+				label.WriteString(fmt.Sprintf("Puntero[%d] = %d\n", i, ptr))
 			}
 		}
 		if label.Len() > 0 {
@@ -853,30 +1130,44 @@ func parseBlock(rawBlock []byte) (string, string) {
 	return "Desconocido", "Datos binarios (64 bytes)"
 }
 
-func BlockReport(id string, OutPutPath string) error {
+// BlockData son los datos de un bloque en uso
+type BlockData struct {
+	Index   int32    `json:"index"`
+	Type    string   `json:"type"`    // Directorio, Archivo, PointerBlock, Vacío o Desconocido
+	Content []string `json:"content"` // una línea por entrada, dato o apuntador
+}
+
+// BlockReportData retorna los bloques marcados como usados en el bitmap, en orden
+func BlockReportData(ctx context.Context, out *OutPut.Output, id string) ([]BlockData, error) {
 	// Obtener la ruta del disco
 	partitionPath := GetPartitionPathByID(id)
 	if partitionPath == "" {
-		return fmt.Errorf("no se encontró la ruta para el id: %s", id)
+		return nil, fmt.Errorf("no se encontró la ruta para el id: %s", id)
+	}
+	defer Locks.RLockPartition(partitionPath, id)()
+
+	// Bajar a disco los cambios cacheados antes de leer la partición directamente
+	if err := Cache.Flush(id); err != nil {
+		return nil, err
 	}
+
 	file, err := Utilities.OpenFile(partitionPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer file.Close()
 
 	// Obtener el inicio de la partición
 	partitionStart := GetPartitionStartByID(id)
 	if partitionStart < 0 {
-		return fmt.Errorf("no se encontró la partición con el id %s", id)
+		return nil, fmt.Errorf("no se encontró la partición con el id %s", id)
 	}
 
 	// Leer el Superblock desde el inicio de la partición
 	var sb Structs.Superblock
 	if err := Utilities.ReadObject(file, &sb, partitionStart); err != nil {
-		return err
+		return nil, err
 	}
-	fmt.Printf("Superblock leído: S_block_start=%d, S_block_size=%d, S_blocks_count=%d\n", sb.S_block_start, sb.S_block_size, sb.S_blocks_count)
 
 	// Tamaños y offsets para lectura
 	blockCount := sb.S_blocks_count
@@ -887,49 +1178,33 @@ func BlockReport(id string, OutPutPath string) error {
 	// Verificar límites del archivo
 	fileInfo, err := file.Stat()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	fileSize := fileInfo.Size()
 
-	// Iniciar construcción del reporte DOT
-	var buffer strings.Builder
-	buffer.WriteString("digraph Block_Report {\n")
-	buffer.WriteString("    rankdir=TB;\n")
-	buffer.WriteString("    node [fontname=\"Arial\", shape=plain, style=\"filled\", fontsize=10];\n")
-	buffer.WriteString("    edge [color=\"#5A5A5A\", arrowhead=normal];\n")
-	buffer.WriteString("    graph [bgcolor=\"#ffffff\", pencolor=\"#333333\", penwidth=2.0, style=\"rounded\"];\n\n")
-
-	lastUsedBlock := -1
+	blocks := []BlockData{}
 
 	// Recorrer todos los bloques y verificar si están usados en el bitmap
 	for i := int32(0); i < blockCount; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		Jobs.Progress(ctx, int(i), int(blockCount))
 		bmOffset := bmBlockStart + int64(i)
 		if bmOffset >= fileSize {
-			fmt.Printf("Se alcanzó el final del archivo al leer el bitmap de bloques (índice: %d)\n", i)
+			out.Warningf("Se alcanzó el final del archivo al leer el bitmap de bloques (índice: %d)\n", i)
 			break
 		}
 		var bitValue byte
 		if err := Utilities.ReadObject(file, &bitValue, bmOffset); err != nil {
-			return err
-		}
-
-		if bitValue == 1 {
-			blockPos := blockStart + int64(i)*int64(blockSize)
-
-			// Verificar si el offset está dentro de los límites del archivo
-			if blockPos+int64(blockSize) > fileSize {
-				fmt.Printf("Se alcanzó el final del archivo en el bloque %d (offset: %d, tamaño archivo: %d)\n", i, blockPos, fileSize)
-				break
-			}
-
-			fmt.Printf("Leyendo bloque %d en offset %d\n", i, blockPos)
+			return nil, err
 		}
 
 		if bitValue == 1 {
 			// Calcular la posición del bloque en el disco
 			blockPos := blockStart + int64(i)*blockSize
 			if blockPos+blockSize > fileSize {
-				fmt.Printf("Se alcanzó el final del archivo en el bloque %d (offset: %d, tamaño archivo: %d bytes)\n", i, blockPos, fileSize)
+				out.Warningf("Se alcanzó el final del archivo en el bloque %d (offset: %d, tamaño archivo: %d bytes)\n", i, blockPos, fileSize)
 				break
 			}
 
@@ -937,11 +1212,10 @@ func BlockReport(id string, OutPutPath string) error {
 			rawBlock := make([]byte, blockSize)
 			n, err := file.ReadAt(rawBlock, blockPos)
 			if err != nil {
-				return fmt.Errorf("error al leer bloque %d: %v", i, err)
+				return nil, fmt.Errorf("error al leer bloque %d: %v", i, err)
 			}
-			fmt.Printf("📦 Bloque %d contenido (primeros 16 bytes): %x\n", i, rawBlock[:16])
 			if int64(n) != blockSize {
-				fmt.Printf("Advertencia: leído %d bytes en bloque %d, se esperaba %d bytes\n", n, i, blockSize)
+				out.Warningf("Advertencia: leído %d bytes en bloque %d, se esperaba %d bytes\n", n, i, blockSize)
 			}
 
 			// Verificar si el bloque es todo ceros
@@ -960,44 +1234,61 @@ func BlockReport(id string, OutPutPath string) error {
 			} else {
 				// Intentar parsear el bloque (usa tu función parseBlock, que debe estar adaptada)
 				blockType, blockLabel = parseBlock(rawBlock)
-				fmt.Printf("Bloque %d detectado como tipo: %s - %s\n", i, blockType, blockLabel)
 			}
 
-			// Log de depuración
-			fmt.Printf("Bloque %d: offset %d, Tipo: %s\n", i, blockPos, blockType)
-
-			// Crear nodo DOT para el bloque
-			buffer.WriteString(fmt.Sprintf("    block%d [\n", i))
-			buffer.WriteString("        label=<\n")
-			buffer.WriteString("            <TABLE BORDER=\"0\" CELLBORDER=\"1\" CELLSPACING=\"0\" CELLPADDING=\"4\" BGCOLOR=\"#CDEFFA\">\n")
-			buffer.WriteString(fmt.Sprintf("                <TR><TD COLSPAN=\"1\" BGCOLOR=\"#92E6F1\"><B>Bloque %d</B></TD></TR>\n", i))
-			buffer.WriteString(fmt.Sprintf("                <TR><TD ALIGN=\"LEFT\">Tipo: %s<br/>%s</TD></TR>\n", blockType, blockLabel))
-			buffer.WriteString("            </TABLE>\n")
-			buffer.WriteString("        >\n")
-			buffer.WriteString("    ];\n\n")
-
-			// Conectar con el bloque anterior si existe.
-			if lastUsedBlock != -1 {
-				buffer.WriteString(fmt.Sprintf("    block%d -> block%d;\n", lastUsedBlock, i))
+			block := BlockData{Index: i, Type: blockType, Content: []string{}}
+			if blockLabel != "" {
+				block.Content = strings.Split(strings.TrimSuffix(blockLabel, "\n"), "\n")
 			}
-			lastUsedBlock = int(i)
+			blocks = append(blocks, block)
 		}
 	}
+	return blocks, nil
+}
 
-	buffer.WriteString("}\n")
-
-	// Guardar el archivo DOT
-	dotFile := OutPutPath + ".dot"
-	if err := os.WriteFile(dotFile, []byte(buffer.String()), 0644); err != nil {
+// BlockReport dibuja los bloques en uso encadenados en orden
+func BlockReport(ctx context.Context, out *OutPut.Output, id string, OutPutPath string) error {
+	blocks, err := BlockReportData(ctx, out, id)
+	if err != nil {
 		return err
 	}
+	if Export.Supported(OutPutPath) {
+		table := &Export.Table{
+			Title:   "Reporte de bloques - partición " + id,
+			Columns: []string{"index", "type", "content"},
+		}
+		for _, block := range blocks {
+			table.Row(block.Index, block.Type, strings.Join(block.Content, "\n"))
+		}
+		if OutPutPath, err = Export.Write(table, OutPutPath); err != nil {
+			return err
+		}
+		out.Println("Reporte de bloques generado en:", OutPutPath)
+		return nil
+	}
+
+	graph := &Render.Graph{}
+	lastUsedBlock := ""
+	for _, block := range blocks {
+		// Crear nodo para el bloque
+		table := &Render.Table{Color: "#CDEFFA"}
+		table.Header(fmt.Sprintf("Bloque %d", block.Index), "#92E6F1")
+		text := strings.Join(append([]string{"Tipo: " + block.Type}, block.Content...), "\n")
+		table.Row(Render.Cell{Text: text, Left: true})
+		node := graph.Add(fmt.Sprintf("block%d", block.Index), table)
+
+		// Conectar con el bloque anterior si existe.
+		if lastUsedBlock != "" {
+			graph.Connect(lastUsedBlock, node.ID)
+		}
+		lastUsedBlock = node.ID
+	}
 
-	// Generar la imagen PNG con Graphviz.
-	cmd := exec.Command("dot", "-Tpng", "-Gdpi=300", dotFile, "-o", OutPutPath+".png")
-	if err := cmd.Run(); err != nil {
+	OutPutPath, err = Render.WriteContext(ctx, graph, OutPutPath)
+	if err != nil {
 		return err
 	}
-
+	out.Println("Reporte de bloques generado en:", OutPutPath)
 	return nil
 }
 
@@ -1018,30 +1309,45 @@ func GetDiskNameByID(id string) string {
 	return ""
 }
 
-func BmInodeReport(id string, OutPutPath string) error {
+// BitmapData son los datos de los reportes bm_inode y bm_block: un carácter 0 o 1
+// por inodo o bloque
+type BitmapData struct {
+	Partition string `json:"partition"`
+	Disk      string `json:"disk"`
+	Bits      string `json:"bits"`
+}
+
+// BmInodeReportData retorna el bitmap de inodos de la partición
+func BmInodeReportData(id string) (BitmapData, error) {
 	// 1. Obtener la ruta del disco a partir del id
 	partitionPath := GetPartitionPathByID(id)
 	if partitionPath == "" {
-		return fmt.Errorf("no se encontró la ruta para el id: %s", id)
+		return BitmapData{}, fmt.Errorf("no se encontró la ruta para el id: %s", id)
+	}
+	defer Locks.RLockPartition(partitionPath, id)()
+
+	// Bajar a disco los cambios cacheados antes de leer la partición directamente
+	if err := Cache.Flush(id); err != nil {
+		return BitmapData{}, err
 	}
 
 	// 2. Abrir el archivo
 	file, err := Utilities.OpenFile(partitionPath)
 	if err != nil {
-		return err
+		return BitmapData{}, err
 	}
 	defer file.Close()
 
 	// 3. Obtener el inicio de la partición
 	partitionStart := GetPartitionStartByID(id)
 	if partitionStart < 0 {
-		return fmt.Errorf("no se encontró la partición con el id %s", id)
+		return BitmapData{}, fmt.Errorf("no se encontró la partición con el id %s", id)
 	}
 
 	// 4. Leer el superblock desde el inicio de la partición
 	var sb Structs.Superblock
 	if err := Utilities.ReadObject(file, &sb, partitionStart); err != nil {
-		return err
+		return BitmapData{}, err
 	}
 
 	// 5. Obtener la cantidad de inodos y el tamaño de cada inodo.
@@ -1055,7 +1361,7 @@ func BmInodeReport(id string, OutPutPath string) error {
 		offset := int64(sb.S_inode_start) + int64(i)*int64(inodeSize)
 		var inode Structs.Inode
 		if err := Utilities.ReadObject(file, &inode, offset); err != nil {
-			return fmt.Errorf("error al leer el inodo %d: %v", i, err)
+			return BitmapData{}, fmt.Errorf("error al leer el inodo %d: %v", i, err)
 		}
 		if inode.I_type[0] != 0 {
 			bitmap[i] = 1
@@ -1064,53 +1370,54 @@ func BmInodeReport(id string, OutPutPath string) error {
 		}
 	}
 
-	// 7. Construir la cadena del reporte con 20 registros (bits) por línea.
-	var OutPutBuilder strings.Builder
-	OutPutBuilder.WriteString(fmt.Sprintf("============= REPORTE BITMAP DE INODOS - PARTICIÓN: %s =============\n", id))
-	for i, b := range bitmap {
-		OutPutBuilder.WriteString(fmt.Sprintf("%d", b))
-		if (i+1)%20 == 0 {
-			OutPutBuilder.WriteString("\n")
-		}
-	}
-	if inodesCount%20 != 0 {
-		OutPutBuilder.WriteString("\n")
-	}
+	return newBitmapData(id, bitmap), nil
+}
 
-	// 8. Guardar el reporte en un archivo de texto.
-	reportFile := OutPutPath + ".txt"
-	if err := os.WriteFile(reportFile, []byte(OutPutBuilder.String()), 0644); err != nil {
+func BmInodeReport(out *OutPut.Output, id string, OutPutPath string) error {
+	data, err := BmInodeReportData(id)
+	if err != nil {
+		return err
+	}
+	title := fmt.Sprintf("============= REPORTE BITMAP DE INODOS - PARTICIÓN: %s =============", id)
+	if err := writeBitmap(title, data.Bits, OutPutPath); err != nil {
 		return err
 	}
 
-	fmt.Printf("Reporte bm_inode generado en: %s\n", reportFile)
+	out.Printf("Reporte bm_inode generado en: %s\n", OutPutPath)
 	return nil
 }
 
-func BmBlockReport(id string, OutPutPath string) error {
+// BmBlockReportData retorna el bitmap de bloques de la partición
+func BmBlockReportData(id string) (BitmapData, error) {
 	// 1. Obtener la ruta del disco a partir del id
 	partitionPath := GetPartitionPathByID(id)
 	if partitionPath == "" {
-		return fmt.Errorf("no se encontró la ruta para el id: %s", id)
+		return BitmapData{}, fmt.Errorf("no se encontró la ruta para el id: %s", id)
+	}
+	defer Locks.RLockPartition(partitionPath, id)()
+
+	// Bajar a disco los cambios cacheados antes de leer la partición directamente
+	if err := Cache.Flush(id); err != nil {
+		return BitmapData{}, err
 	}
 
 	// 2. Abrir el archivo
 	file, err := Utilities.OpenFile(partitionPath)
 	if err != nil {
-		return err
+		return BitmapData{}, err
 	}
 	defer file.Close()
 
 	// 3. Obtener el inicio de la partición
 	partitionStart := GetPartitionStartByID(id)
 	if partitionStart < 0 {
-		return fmt.Errorf("no se encontró la partición con el id %s", id)
+		return BitmapData{}, fmt.Errorf("no se encontró la partición con el id %s", id)
 	}
 
 	// 4. Leer el superblock desde la partición
 	var sb Structs.Superblock
 	if err := Utilities.ReadObject(file, &sb, partitionStart); err != nil {
-		return err
+		return BitmapData{}, err
 	}
 
 	// 5. Obtener la cantidad de bloques y el inicio del bitmap de bloques
@@ -1121,62 +1428,111 @@ func BmBlockReport(id string, OutPutPath string) error {
 	bmData := make([]byte, blocksCount)
 	_, err = file.ReadAt(bmData, int64(bmBlockStart))
 	if err != nil {
-		return fmt.Errorf("error al leer bitmap de bloques: %v", err)
+		return BitmapData{}, fmt.Errorf("error al leer bitmap de bloques: %v", err)
 	}
 
-	// 7. Construir la cadena con 20 registros por línea
-	var OutPutBuilder strings.Builder
+	return newBitmapData(id, bmData), nil
+}
 
-	disk := GetDiskNameByID(id)
+func BmBlockReport(out *OutPut.Output, id string, OutPutPath string) error {
+	data, err := BmBlockReportData(id)
+	if err != nil {
+		return err
+	}
+	title := fmt.Sprintf("=============  REPORTE BITMAP DE BLOQUES  - PARTICION: %s ===================== ", data.Disk)
+	if err := writeBitmap(title, data.Bits, OutPutPath); err != nil {
+		return err
+	}
 
-	OutPutBuilder.WriteString(fmt.Sprintf("=============  REPORTE BITMAP DE BLOQUES  - PARTICION: %s ===================== \n", disk))
-	for i, b := range bmData {
-		// Cada byte b es 0 ó 1
-		OutPutBuilder.WriteString(fmt.Sprintf("%d", b))
+	out.Printf("Reporte bm_block generado en: %s\n", OutPutPath)
+	return nil
+}
 
-		// Cada 20 bits hacemos un salto de línea
-		if (i+1)%20 == 0 {
-			OutPutBuilder.WriteString("\n")
+// newBitmapData convierte un bitmap de bytes 0 y 1 en texto
+func newBitmapData(id string, bitmap []byte) BitmapData {
+	bits := make([]byte, len(bitmap))
+	for i, b := range bitmap {
+		bits[i] = '0'
+		if b != 0 {
+			bits[i] = '1'
 		}
 	}
+	return BitmapData{Partition: id, Disk: GetDiskNameByID(id), Bits: string(bits)}
+}
 
-	// Si la cantidad de bloques no es múltiplo de 20, forzamos un salto de línea final
-	if blocksCount%20 != 0 {
-		OutPutBuilder.WriteString("\n")
+// writeBitmap guarda un bitmap como texto con 20 registros (bits) por línea, o
+// con una fila por registro si path es .csv o .md
+func writeBitmap(title string, bits string, path string) error {
+	if Export.Supported(path) {
+		table := &Export.Table{Title: strings.Trim(title, "= "), Columns: []string{"index", "used"}}
+		for i := range len(bits) {
+			table.Row(i, bits[i:i+1])
+		}
+		_, err := Export.Write(table, path)
+		return err
 	}
 
-	// 8. Guardar la salida en un archivo de texto
-	reportFile := OutPutPath + ".txt"
-	if err := os.WriteFile(reportFile, []byte(OutPutBuilder.String()), 0644); err != nil {
-		return err
+	var OutPutBuilder strings.Builder
+	OutPutBuilder.WriteString(title + "\n")
+	for i := 0; i < len(bits); i += 20 {
+		OutPutBuilder.WriteString(bits[i:min(i+20, len(bits))] + "\n")
 	}
+	return os.WriteFile(path, []byte(OutPutBuilder.String()), 0644)
+}
 
-	fmt.Printf("Reporte bm_block generado en: %s\n", reportFile)
-	return nil
+// SuperBlockData son los datos del reporte sb
+type SuperBlockData struct {
+	Disk             string `json:"disk"`
+	Partition        string `json:"partition"`
+	FilesystemType   string `json:"filesystemType"`
+	Magic            int32  `json:"magic"`
+	MountCount       int32  `json:"mountCount"`
+	InodesCount      int32  `json:"inodesCount"`
+	FreeInodes       int32  `json:"freeInodes"`
+	UsedInodes       int32  `json:"usedInodes"`
+	BlocksCount      int32  `json:"blocksCount"`
+	FreeBlocks       int32  `json:"freeBlocks"`
+	UsedBlocks       int32  `json:"usedBlocks"`
+	InodeSize        int32  `json:"inodeSize"`
+	BlockSize        int32  `json:"blockSize"`
+	FirstFreeInode   int32  `json:"firstFreeInode"`
+	FirstFreeBlock   int32  `json:"firstFreeBlock"`
+	BitmapInodeStart int32  `json:"bitmapInodeStart"`
+	BitmapBlockStart int32  `json:"bitmapBlockStart"`
+	InodeStart       int32  `json:"inodeStart"`
+	BlockStart       int32  `json:"blockStart"`
 }
 
-func SuperBlockReport(id string, OutPutPath string) error {
+// SuperBlockReportData retorna el superbloque de la partición
+func SuperBlockReportData(id string) (SuperBlockData, error) {
 	// 1. Obtener la ruta del disco a partir del id de la partición
 	partitionPath := GetPartitionPathByID(id)
 	if partitionPath == "" {
-		return fmt.Errorf("no se encontró la ruta para el id: %s", id)
+		return SuperBlockData{}, fmt.Errorf("no se encontró la ruta para el id: %s", id)
+	}
+	defer Locks.RLockPartition(partitionPath, id)()
+
+	// Bajar a disco los cambios cacheados antes de leer la partición directamente
+	if err := Cache.Flush(id); err != nil {
+		return SuperBlockData{}, err
 	}
+
 	file, err := Utilities.OpenFile(partitionPath)
 	if err != nil {
-		return err
+		return SuperBlockData{}, err
 	}
 	defer file.Close()
 
 	// 2. Obtener el inicio de la partición
 	partitionStart := GetPartitionStartByID(id)
 	if partitionStart < 0 {
-		return fmt.Errorf("no se encontró la partición con el id %s", id)
+		return SuperBlockData{}, fmt.Errorf("no se encontró la partición con el id %s", id)
 	}
 
 	// 3. Leer el superbloque desde el inicio de la partición
 	var sb Structs.Superblock
 	if err := Utilities.ReadObject(file, &sb, partitionStart); err != nil {
-		return err
+		return SuperBlockData{}, err
 	}
 
 	// Determinar el tipo de sistema de archivos
@@ -1184,61 +1540,66 @@ func SuperBlockReport(id string, OutPutPath string) error {
 	if sb.S_filesystem_type == 3 {
 		fsType = "EXT3"
 	}
-	disk := GetDiskNameByID(id)
-	// Calcular inodos y bloques usados.
-	usedInodes := sb.S_inodes_count - sb.S_free_inodes_count
-	usedBlocks := sb.S_blocks_count - sb.S_free_blocks_count
-
-	// 4. Generar el reporte DOT
-	var buffer strings.Builder
-	buffer.WriteString("digraph Superblock_Report {\n")
-	buffer.WriteString("    rankdir=TB;\n")
-	buffer.WriteString("    node [fontname=\"Arial\", shape=plaintext, style=\"filled\", fontsize=10];\n")
-	buffer.WriteString("    graph [bgcolor=\"#ffffff\", pencolor=\"#333333\", penwidth=2.0, style=\"rounded\"];\n\n")
-
-	buffer.WriteString("    superblock [\n")
-	buffer.WriteString("        label=<\n")
-	buffer.WriteString("            <TABLE BORDER=\"1\" CELLBORDER=\"1\" CELLSPACING=\"0\" CELLPADDING=\"6\">\n")
-	buffer.WriteString("                <TR><TD COLSPAN=\"2\" BGCOLOR=\"#B3D9FF\"><B>REPORTE DE SUPERBLOQUE</B></TD></TR>\n")
-	buffer.WriteString("                <TR><TD COLSPAN=\"2\" BGCOLOR=\"#E6F3FF\"><B>Información General</B></TD></TR>\n")
-	buffer.WriteString(fmt.Sprintf("                <TR><TD>Ruta del Disco</TD><TD>%s</TD></TR>\n", disk))
-	buffer.WriteString(fmt.Sprintf("                <TR><TD>ID de Partición</TD><TD>%s</TD></TR>\n", id))
-	buffer.WriteString(fmt.Sprintf("                <TR><TD>Tipo de Sistema</TD><TD>%s</TD></TR>\n", fsType))
-	buffer.WriteString(fmt.Sprintf("                <TR><TD>Valor Mágico</TD><TD>0x%X</TD></TR>\n", sb.S_magic))
-	buffer.WriteString("                <TR><TD COLSPAN=\"2\" BGCOLOR=\"#E6F3FF\"><B>Estado del Sistema</B></TD></TR>\n")
-	buffer.WriteString(fmt.Sprintf("                <TR><TD>Veces Montado</TD><TD>%d</TD></TR>\n", sb.S_mnt_count))
-	buffer.WriteString("                <TR><TD COLSPAN=\"2\" BGCOLOR=\"#E6F3FF\"><B>Uso de Inodos y Bloques</B></TD></TR>\n")
-	buffer.WriteString(fmt.Sprintf("                <TR><TD>Total de Inodos</TD><TD>%d</TD></TR>\n", sb.S_inodes_count))
-	buffer.WriteString(fmt.Sprintf("                <TR><TD>Inodos Libres</TD><TD>%d</TD></TR>\n", sb.S_free_inodes_count))
-	buffer.WriteString(fmt.Sprintf("                <TR><TD>Inodos Usados</TD><TD>%d</TD></TR>\n", usedInodes))
-	buffer.WriteString(fmt.Sprintf("                <TR><TD>Total de Bloques</TD><TD>%d</TD></TR>\n", sb.S_blocks_count))
-	buffer.WriteString(fmt.Sprintf("                <TR><TD>Bloques Libres</TD><TD>%d</TD></TR>\n", sb.S_free_blocks_count))
-	buffer.WriteString(fmt.Sprintf("                <TR><TD>Bloques Usados</TD><TD>%d</TD></TR>\n", usedBlocks))
-	buffer.WriteString("                <TR><TD COLSPAN=\"2\" BGCOLOR=\"#E6F3FF\"><B>Detalles de la Estructura</B></TD></TR>\n")
-	buffer.WriteString(fmt.Sprintf("                <TR><TD>Tamaño de Inodo</TD><TD>%d bytes</TD></TR>\n", sb.S_inode_size))
-	buffer.WriteString(fmt.Sprintf("                <TR><TD>Tamaño de Bloque</TD><TD>%d bytes</TD></TR>\n", sb.S_block_size))
-	buffer.WriteString(fmt.Sprintf("                <TR><TD>Primer Inodo Libre</TD><TD>%d</TD></TR>\n", sb.S_fist_ino))
-	buffer.WriteString(fmt.Sprintf("                <TR><TD>Primer Bloque Libre</TD><TD>%d</TD></TR>\n", sb.S_first_blo))
-	buffer.WriteString(fmt.Sprintf("                <TR><TD>Inicio Bitmap Inodos</TD><TD>%d</TD></TR>\n", sb.S_bm_inode_start))
-	buffer.WriteString(fmt.Sprintf("                <TR><TD>Inicio Bitmap Bloques</TD><TD>%d</TD></TR>\n", sb.S_bm_block_start))
-	buffer.WriteString(fmt.Sprintf("                <TR><TD>Inicio Tabla Inodos</TD><TD>%d</TD></TR>\n", sb.S_inode_start))
-	buffer.WriteString(fmt.Sprintf("                <TR><TD>Inicio Tabla Bloques</TD><TD>%d</TD></TR>\n", sb.S_block_start))
-	buffer.WriteString("            </TABLE>\n")
-	buffer.WriteString("        >\n")
-	buffer.WriteString("    ];\n")
-	buffer.WriteString("}\n")
-
-	// Guardar el archivo DOT
-	dotFile := OutPutPath + ".dot"
-	if err := os.WriteFile(dotFile, []byte(buffer.String()), 0664); err != nil {
-		return fmt.Errorf("no se pudo escribir el archivo DOT: %v", err)
-	}
-	// Ejecutar Graphviz para generar la imagen (PNG)
-	cmd := exec.Command("dot", "-Tpng", "-Gdpi=300", dotFile, "-o", OutPutPath+".png")
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("error al ejecutar dot: %v", err)
-	}
-	OutPut.Println("Reporte de superbloque generado en:", OutPutPath+".png")
+	return SuperBlockData{
+		Disk:             GetDiskNameByID(id),
+		Partition:        id,
+		FilesystemType:   fsType,
+		Magic:            sb.S_magic,
+		MountCount:       sb.S_mnt_count,
+		InodesCount:      sb.S_inodes_count,
+		FreeInodes:       sb.S_free_inodes_count,
+		UsedInodes:       sb.S_inodes_count - sb.S_free_inodes_count,
+		BlocksCount:      sb.S_blocks_count,
+		FreeBlocks:       sb.S_free_blocks_count,
+		UsedBlocks:       sb.S_blocks_count - sb.S_free_blocks_count,
+		InodeSize:        sb.S_inode_size,
+		BlockSize:        sb.S_block_size,
+		FirstFreeInode:   sb.S_fist_ino,
+		FirstFreeBlock:   sb.S_first_blo,
+		BitmapInodeStart: sb.S_bm_inode_start,
+		BitmapBlockStart: sb.S_bm_block_start,
+		InodeStart:       sb.S_inode_start,
+		BlockStart:       sb.S_block_start,
+	}, nil
+}
+
+func SuperBlockReport(out *OutPut.Output, id string, OutPutPath string) error {
+	sb, err := SuperBlockReportData(id)
+	if err != nil {
+		return err
+	}
+
+	table := &Render.Table{}
+	table.Header("REPORTE DE SUPERBLOQUE", "#B3D9FF")
+	table.Header("Información General", "#E6F3FF")
+	table.Field("Ruta del Disco", sb.Disk)
+	table.Field("ID de Partición", sb.Partition)
+	table.Field("Tipo de Sistema", sb.FilesystemType)
+	table.Field("Valor Mágico", fmt.Sprintf("0x%X", sb.Magic))
+	table.Header("Estado del Sistema", "#E6F3FF")
+	table.Field("Veces Montado", sb.MountCount)
+	table.Header("Uso de Inodos y Bloques", "#E6F3FF")
+	table.Field("Total de Inodos", sb.InodesCount)
+	table.Field("Inodos Libres", sb.FreeInodes)
+	table.Field("Inodos Usados", sb.UsedInodes)
+	table.Field("Total de Bloques", sb.BlocksCount)
+	table.Field("Bloques Libres", sb.FreeBlocks)
+	table.Field("Bloques Usados", sb.UsedBlocks)
+	table.Header("Detalles de la Estructura", "#E6F3FF")
+	table.Field("Tamaño de Inodo", fmt.Sprintf("%d bytes", sb.InodeSize))
+	table.Field("Tamaño de Bloque", fmt.Sprintf("%d bytes", sb.BlockSize))
+	table.Field("Primer Inodo Libre", sb.FirstFreeInode)
+	table.Field("Primer Bloque Libre", sb.FirstFreeBlock)
+	table.Field("Inicio Bitmap Inodos", sb.BitmapInodeStart)
+	table.Field("Inicio Bitmap Bloques", sb.BitmapBlockStart)
+	table.Field("Inicio Tabla Inodos", sb.InodeStart)
+	table.Field("Inicio Tabla Bloques", sb.BlockStart)
+
+	OutPutPath, err = Render.Write(Render.Single("", table), OutPutPath)
+	if err != nil {
+		return err
+	}
+	out.Println("Reporte de superbloque generado en:", OutPutPath)
 	return nil
 }
 
@@ -1254,6 +1615,12 @@ func ExploreDisk(id string) ([]DiskExplorerResponse, error) {
 	if partitionPath == "" {
 		return nil, fmt.Errorf("no se encontró la ruta para el id: %s", id)
 	}
+	// La exploración solo lee, así que puede correr en paralelo con otras lecturas
+	defer Locks.RLockPartition(partitionPath, id)()
+
+	if err := Cache.Flush(id); err != nil {
+		return nil, err
+	}
 	file, err := Utilities.OpenFile(partitionPath)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,96 @@
+package Export
+
+import (
+	"MIA_P1/Utilities"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Los reportes tabulares (inode, block, bm_inode, bm_block y ls) también se
+// pueden escribir como .csv o .md para revisarlos con diff o abrirlos en una hoja
+// de cálculo. Las columnas usan los mismos nombres que los campos JSON de
+// GET /api/reports/:name/:id y cada valor se escribe completo, sin recortar.
+
+// Table es una tabla de texto: una fila de columnas y filas de valores
+type Table struct {
+	Title   string // solo se escribe en Markdown
+	Columns []string
+	Rows    [][]string
+}
+
+// Row agrega una fila con los valores convertidos a texto
+func (t *Table) Row(values ...any) {
+	row := make([]string, len(values))
+	for i, v := range values {
+		row[i] = fmt.Sprint(v)
+	}
+	t.Rows = append(t.Rows, row)
+}
+
+// Supported indica si la extensión de path es un formato de exportación
+func Supported(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv", ".md":
+		return true
+	}
+	return false
+}
+
+// Write escribe la tabla en path con el formato de su extensión
+func Write(t *Table, path string) (string, error) {
+	var content []byte
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		content = CSV(t)
+	case ".md":
+		content = Markdown(t)
+	default:
+		return "", fmt.Errorf("formato de exportación no soportado: %s (use .csv o .md)", filepath.Ext(path))
+	}
+	if err := Utilities.CreateParentDirs(path); err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, content, 0644)
+}
+
+// CSV retorna la tabla en formato CSV con una fila de encabezados
+func CSV(t *Table) []byte {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	w.Write(t.Columns)
+	w.WriteAll(t.Rows)
+	return b.Bytes()
+}
+
+// Markdown retorna la tabla en formato Markdown. Los saltos de línea de un valor
+// se escriben como <br>.
+func Markdown(t *Table) []byte {
+	var b bytes.Buffer
+	if t.Title != "" {
+		fmt.Fprintf(&b, "# %s\n\n", t.Title)
+	}
+	writeMarkdownRow(&b, t.Columns)
+	separator := make([]string, len(t.Columns))
+	for i := range separator {
+		separator[i] = "---"
+	}
+	writeMarkdownRow(&b, separator)
+	for _, row := range t.Rows {
+		writeMarkdownRow(&b, row)
+	}
+	return b.Bytes()
+}
+
+func writeMarkdownRow(b *bytes.Buffer, values []string) {
+	b.WriteString("|")
+	for _, v := range values {
+		v = strings.ReplaceAll(v, "|", "\\|")
+		v = strings.ReplaceAll(strings.TrimRight(v, "\n"), "\n", "<br>")
+		fmt.Fprintf(b, " %s |", v)
+	}
+	b.WriteString("\n")
+}
@@ -0,0 +1,264 @@
+package Jobs
+
+import (
+	"MIA_P1/OutPut"
+	"MIA_P1/Reports"
+	"MIA_P1/Results"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Los reportes que recorren toda una partición (inode, block, tree) o que usan
+// Graphviz pueden tardar más que el plazo de una petición HTTP. Un trabajo los
+// genera en segundo plano: la API responde de inmediato con su ID y el cliente
+// consulta su estado y su avance hasta que termina. A lo sumo Workers trabajos se
+// ejecutan a la vez y el resto espera en la cola.
+//
+// Cada trabajo tiene una clave que describe el reporte y el estado de la
+// partición. Si ya se generó un reporte con la misma clave y su archivo sigue
+// igual, el trabajo termina de inmediato con ese reporte.
+
+// Workers es la cantidad de trabajos que se ejecutan a la vez
+const Workers = 2
+
+// TTL es el tiempo que se conserva un trabajo terminado
+const TTL = 30 * time.Minute
+
+// State es el estado de un trabajo
+type State string
+
+const (
+	Queued    State = "queued"
+	Running   State = "running"
+	Done      State = "done"
+	Failed    State = "failed"
+	Cancelled State = "cancelled"
+)
+
+// Job es un reporte que se genera en segundo plano
+type Job struct {
+	ID         string          `json:"id"`
+	Name       string          `json:"name"`      // tipo de reporte: mbr, disk, tree, ...
+	Partition  string          `json:"partition"` // ID de la partición montada
+	Path       string          `json:"path"`      // ruta pedida para el reporte
+	State      State           `json:"state"`
+	Done       int             `json:"done"`  // estructuras procesadas
+	Total      int             `json:"total"` // 0 mientras no se conoce
+	Cached     bool            `json:"cached,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Code       Results.Code    `json:"code,omitempty"`
+	Lines      []OutPut.Line   `json:"lines,omitempty"`
+	Report     *Reports.Report `json:"report,omitempty"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	FinishedAt *time.Time      `json:"finishedAt,omitempty"`
+}
+
+// Finished indica si el trabajo ya no se está ejecutando ni espera en la cola
+func (j Job) Finished() bool {
+	return j.State == Done || j.State == Failed || j.State == Cancelled
+}
+
+// Task genera el reporte de un trabajo. Debe detenerse cuando ctx se cancela e
+// informar su avance con Progress.
+type Task func(ctx context.Context, out *OutPut.Output) (Reports.Report, error)
+
+type job struct {
+	Job
+	key    string
+	out    *OutPut.Output
+	cancel context.CancelFunc
+}
+
+type progressKey struct{}
+
+var (
+	mu      sync.Mutex
+	jobs    = make(map[string]*job)
+	results = make(map[string]Reports.Report) // clave → último reporte generado
+	slots   = make(chan struct{}, Workers)
+)
+
+// Start encola un trabajo con los datos de info y lo retorna. Si hay un reporte
+// vigente para key el trabajo ya está terminado, y si otro trabajo con la misma
+// clave está pendiente se retorna ese. Una clave vacía nunca se reutiliza.
+func Start(info Job, key string, task Task) Job {
+	mu.Lock()
+	defer mu.Unlock()
+	purge()
+
+	if key != "" {
+		for _, j := range jobs {
+			if j.key == key && !j.Finished() {
+				return j.snapshot()
+			}
+		}
+	}
+
+	j := &job{Job: info, key: key, out: OutPut.New()}
+	j.ID = newID()
+	j.State = Queued
+	j.CreatedAt = time.Now()
+	jobs[j.ID] = j
+
+	if report, ok := cached(key); ok {
+		j.State = Done
+		j.Cached = true
+		j.Report = &report
+		finished := j.CreatedAt
+		j.FinishedAt = &finished
+		return j.snapshot()
+	}
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), progressKey{}, j))
+	j.cancel = cancel
+	go run(ctx, j, task)
+	return j.snapshot()
+}
+
+// Get retorna el trabajo con el ID indicado
+func Get(id string) (Job, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	purge()
+	j, ok := jobs[id]
+	if !ok {
+		return Job{}, Results.Errorf(Results.NotFound, "el trabajo %s no existe o expiró", id)
+	}
+	return j.snapshot(), nil
+}
+
+// List retorna los trabajos del más reciente al más antiguo
+func List() []Job {
+	mu.Lock()
+	defer mu.Unlock()
+	purge()
+	list := []Job{}
+	for _, j := range jobs {
+		list = append(list, j.snapshot())
+	}
+	sort.Slice(list, func(i, k int) bool {
+		return list[i].CreatedAt.After(list[k].CreatedAt)
+	})
+	return list
+}
+
+// Cancel detiene un trabajo en ejecución o lo saca de la cola
+func Cancel(id string) (Job, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	j, ok := jobs[id]
+	if !ok {
+		return Job{}, Results.Errorf(Results.NotFound, "el trabajo %s no existe o expiró", id)
+	}
+	if j.Finished() {
+		return Job{}, Results.Errorf(Results.InvalidParams, "el trabajo %s ya terminó", id)
+	}
+	j.cancel()
+	return j.snapshot(), nil
+}
+
+// Progress informa el avance del trabajo que se ejecuta con ctx. Fuera de un
+// trabajo no hace nada.
+func Progress(ctx context.Context, done, total int) {
+	j, ok := ctx.Value(progressKey{}).(*job)
+	if !ok {
+		return
+	}
+	mu.Lock()
+	j.Done, j.Total = done, total
+	mu.Unlock()
+}
+
+func run(ctx context.Context, j *job, task Task) {
+	defer j.cancel()
+
+	// Esperar un lugar libre; un trabajo cancelado en la cola no llega a ejecutarse
+	select {
+	case slots <- struct{}{}:
+		defer func() { <-slots }()
+	case <-ctx.Done():
+		finish(ctx, j, Reports.Report{}, ctx.Err())
+		return
+	}
+
+	mu.Lock()
+	if ctx.Err() == nil {
+		j.State = Running
+	}
+	mu.Unlock()
+
+	report, err := task(ctx, j.out)
+	finish(ctx, j, report, err)
+}
+
+func finish(ctx context.Context, j *job, report Reports.Report, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	now := time.Now()
+	j.FinishedAt = &now
+	switch {
+	case ctx.Err() != nil:
+		j.State = Cancelled
+		j.Error = "el trabajo se canceló"
+	case err != nil:
+		j.State = Failed
+		j.Error = err.Error()
+		j.Code = Results.CodeOf(err)
+	default:
+		j.State = Done
+		j.Report = &report
+		if j.Total > 0 {
+			j.Done = j.Total
+		}
+		if j.key != "" {
+			results[j.key] = report
+		}
+	}
+}
+
+// cached retorna el reporte guardado para key si su archivo no cambió desde que
+// se generó. Se llama con mu tomado.
+func cached(key string) (Reports.Report, bool) {
+	if key == "" {
+		return Reports.Report{}, false
+	}
+	saved, ok := results[key]
+	if !ok {
+		return Reports.Report{}, false
+	}
+	// Otro reporte pudo escribirse después en la misma ruta
+	current, ok := Reports.Get(saved.ID)
+	if !ok || !current.CreatedAt.Equal(saved.CreatedAt) {
+		delete(results, key)
+		return Reports.Report{}, false
+	}
+	return current, true
+}
+
+// snapshot copia el trabajo con las líneas escritas hasta el momento. Se llama
+// con mu tomado.
+func (j *job) snapshot() Job {
+	c := j.Job
+	c.Lines = j.out.Lines()
+	return c
+}
+
+// purge elimina los trabajos terminados hace más de TTL. Se llama con mu tomado.
+func purge() {
+	now := time.Now()
+	for id, j := range jobs {
+		if j.Finished() && now.Sub(*j.FinishedAt) > TTL {
+			delete(jobs, id)
+		}
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
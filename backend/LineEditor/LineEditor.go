@@ -0,0 +1,253 @@
+package LineEditor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// En una terminal la línea se edita con las teclas habituales:
+//   - ← y → mueven el cursor; Inicio/Fin o Ctrl-A/Ctrl-E van a los extremos
+//   - ↑ y ↓ recorren el historial
+//   - Tab completa la palabra actual con las opciones del Completer
+//   - Retroceso y Supr borran; Ctrl-U y Ctrl-K borran hasta el inicio o el final
+//   - Ctrl-C descarta la línea (ReadLine retorna "") y Ctrl-D en una línea vacía
+//     termina la entrada
+//
+// Si la entrada no es una terminal (por ejemplo un pipe) las líneas se leen tal cual.
+
+// MaxHistory es la cantidad de líneas que se conservan en el historial
+const MaxHistory = 500
+
+// Editor lee líneas de la entrada estándar
+type Editor struct {
+	// Completer recibe el texto antes del cursor y retorna las palabras que pueden
+	// reemplazar a la última. Si es nil, Tab no hace nada.
+	Completer func(line string) []string
+
+	in          *os.File
+	out         io.Writer
+	reader      *bufio.Reader
+	history     []string
+	historyFile string
+}
+
+// New crea un editor sobre la entrada y salida estándar. Si historyFile no está
+// vacío, el historial se carga de ese archivo y cada línea nueva se agrega al final.
+func New(historyFile string) *Editor {
+	e := &Editor{
+		in:          os.Stdin,
+		out:         os.Stdout,
+		reader:      bufio.NewReader(os.Stdin),
+		historyFile: historyFile,
+	}
+	if historyFile != "" {
+		if data, err := os.ReadFile(historyFile); err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if line != "" {
+					e.history = append(e.history, line)
+				}
+			}
+			if len(e.history) > MaxHistory {
+				e.history = e.history[len(e.history)-MaxHistory:]
+			}
+		}
+	}
+	return e
+}
+
+// History retorna las líneas del historial, de la más antigua a la más reciente
+func (e *Editor) History() []string {
+	return append([]string(nil), e.history...)
+}
+
+// ReadLine muestra el prompt y lee una línea. Retorna io.EOF al terminar la entrada.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	restore, err := makeRaw(e.in)
+	if err != nil {
+		// No es una terminal: se lee la línea sin edición
+		fmt.Fprint(e.out, prompt)
+		line, err := e.reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	defer restore()
+	return e.edit(prompt)
+}
+
+// AddHistory agrega una línea al historial, salvo que esté vacía o repita la anterior
+func (e *Editor) AddHistory(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == line) {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > MaxHistory {
+		e.history = e.history[len(e.history)-MaxHistory:]
+	}
+	if e.historyFile == "" {
+		return
+	}
+	file, err := os.OpenFile(e.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	fmt.Fprintln(file, line)
+}
+
+// edit lee una línea en modo raw, redibujándola después de cada tecla
+func (e *Editor) edit(prompt string) (string, error) {
+	var buf []rune
+	pos := 0
+	index := len(e.history) // posición en el historial; len(history) es la línea nueva
+	draft := ""             // línea nueva guardada mientras se recorre el historial
+
+	redraw := func() {
+		fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(buf))
+		if back := len(buf) - pos; back > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", back)
+		}
+	}
+	setLine := func(line string) {
+		buf = []rune(line)
+		pos = len(buf)
+	}
+	redraw()
+
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			fmt.Fprint(e.out, "\r\n")
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(buf), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", nil
+		case 4: // Ctrl-D
+			if len(buf) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(buf) {
+				buf = append(buf[:pos], buf[pos+1:]...)
+			}
+		case '\t':
+			buf, pos = e.complete(prompt, buf, pos)
+		case 1: // Ctrl-A
+			pos = 0
+		case 5: // Ctrl-E
+			pos = len(buf)
+		case 11: // Ctrl-K
+			buf = buf[:pos]
+		case 21: // Ctrl-U
+			buf = buf[pos:]
+			pos = 0
+		case 127, 8: // Retroceso
+			if pos > 0 {
+				buf = append(buf[:pos-1], buf[pos:]...)
+				pos--
+			}
+		case 27: // Secuencias de escape de las flechas y teclas especiales
+			switch e.escape() {
+			case "[A", "OA":
+				if index > 0 {
+					if index == len(e.history) {
+						draft = string(buf)
+					}
+					index--
+					setLine(e.history[index])
+				}
+			case "[B", "OB":
+				if index < len(e.history) {
+					index++
+					if index == len(e.history) {
+						setLine(draft)
+					} else {
+						setLine(e.history[index])
+					}
+				}
+			case "[C", "OC":
+				if pos < len(buf) {
+					pos++
+				}
+			case "[D", "OD":
+				if pos > 0 {
+					pos--
+				}
+			case "[H", "OH", "[1~", "[7~":
+				pos = 0
+			case "[F", "OF", "[4~", "[8~":
+				pos = len(buf)
+			case "[3~":
+				if pos < len(buf) {
+					buf = append(buf[:pos], buf[pos+1:]...)
+				}
+			}
+		default:
+			if unicode.IsPrint(r) {
+				buf = append(buf[:pos], append([]rune{r}, buf[pos:]...)...)
+				pos++
+			}
+		}
+		redraw()
+	}
+}
+
+// complete reemplaza la palabra antes del cursor. Con un solo candidato la completa;
+// con varios avanza hasta su prefijo común y, si no hay nada que agregar, los muestra.
+func (e *Editor) complete(prompt string, buf []rune, pos int) ([]rune, int) {
+	if e.Completer == nil {
+		return buf, pos
+	}
+	before := string(buf[:pos])
+	candidates := e.Completer(before)
+	if len(candidates) == 0 {
+		return buf, pos
+	}
+
+	start := strings.LastIndexAny(before, " \t") + 1
+	word := before[start:]
+	common := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	if len(candidates) > 1 && len(common) <= len(word) {
+		fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+		return buf, pos
+	}
+
+	completed := []rune(before[:start] + common)
+	return append(completed, buf[pos:]...), len(completed)
+}
+
+// escape lee el resto de una secuencia de escape, por ejemplo "[A" o "[3~"
+func (e *Editor) escape() string {
+	first, _, err := e.reader.ReadRune()
+	if err != nil || (first != '[' && first != 'O') {
+		return ""
+	}
+	seq := []rune{first}
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			return string(seq)
+		}
+		seq = append(seq, r)
+		// Los parámetros son dígitos y ';'; cualquier otro carácter termina la secuencia
+		if !unicode.IsDigit(r) && r != ';' {
+			return string(seq)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+//go:build linux
+
+package LineEditor
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// makeRaw pone la terminal en modo raw para leer tecla por tecla y retorna la
+// función que restaura el modo anterior. Falla si el archivo no es una terminal.
+// La salida se deja sin cambios para que los \n se sigan mostrando como saltos de línea.
+func makeRaw(file *os.File) (func(), error) {
+	fd := file.Fd()
+	var old syscall.Termios
+	if err := ioctl(fd, syscall.TCGETS, &old); err != nil {
+		return nil, err
+	}
+
+	raw := old
+	raw.Iflag &^= syscall.ICRNL | syscall.IXON | syscall.BRKINT | syscall.INPCK | syscall.ISTRIP
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(fd, syscall.TCSETS, &raw); err != nil {
+		return nil, err
+	}
+	return func() { ioctl(fd, syscall.TCSETS, &old) }, nil
+}
+
+func ioctl(fd uintptr, request uintptr, termios *syscall.Termios) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(unsafe.Pointer(termios))); errno != 0 {
+		return errno
+	}
+	return nil
+}
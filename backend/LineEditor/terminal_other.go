@@ -0,0 +1,13 @@
+//go:build !linux
+
+package LineEditor
+
+import (
+	"errors"
+	"os"
+)
+
+// makeRaw solo está implementado en Linux; en otros sistemas las líneas se leen sin edición
+func makeRaw(file *os.File) (func(), error) {
+	return nil, errors.New("edición de línea no disponible en este sistema")
+}
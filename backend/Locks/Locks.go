@@ -0,0 +1,98 @@
+package Locks
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+// Modelo de bloqueo:
+//   - Cada disco tiene un RWMutex. Los comandos que modifican el MBR o el archivo
+//     completo (mkdisk, fdisk, mount, unmount, rmdisk) toman el disco en modo escritura.
+//   - Cada partición tiene un RWMutex. Los comandos que trabajan dentro de una partición
+//     toman su disco en modo lectura y luego la partición en modo lectura o escritura.
+//
+//   - Un candado global separa los dry-runs del resto: cada comando lo toma en modo
+//     lectura y un dry-run lo toma de forma exclusiva mientras trabaja sobre las copias.
+//
+// Los candados siempre se toman en el orden global → disco → partición y no son
+// reentrantes, por lo que una función que ya tiene un candado no debe volver a pedirlo.
+
+var (
+	all        sync.RWMutex
+	mu         sync.Mutex
+	disks      = make(map[string]*sync.RWMutex) // ruta del disco → candado
+	partitions = make(map[string]*sync.RWMutex) // id de partición → candado
+)
+
+func diskLock(diskPath string) *sync.RWMutex {
+	key := filepath.Clean(diskPath)
+	mu.Lock()
+	defer mu.Unlock()
+	l, ok := disks[key]
+	if !ok {
+		l = &sync.RWMutex{}
+		disks[key] = l
+	}
+	return l
+}
+
+func partitionLock(id string) *sync.RWMutex {
+	mu.Lock()
+	defer mu.Unlock()
+	l, ok := partitions[id]
+	if !ok {
+		l = &sync.RWMutex{}
+		partitions[id] = l
+	}
+	return l
+}
+
+// LockAll bloquea el candado global de forma exclusiva y retorna la función que lo libera
+func LockAll() func() {
+	all.Lock()
+	return all.Unlock
+}
+
+// RLockAll bloquea el candado global en modo lectura y retorna la función que lo libera
+func RLockAll() func() {
+	all.RLock()
+	return all.RUnlock
+}
+
+// LockDisk bloquea el disco de forma exclusiva y retorna la función que lo libera
+func LockDisk(diskPath string) func() {
+	l := diskLock(diskPath)
+	l.Lock()
+	return l.Unlock
+}
+
+// RLockDisk bloquea el disco en modo lectura y retorna la función que lo libera
+func RLockDisk(diskPath string) func() {
+	l := diskLock(diskPath)
+	l.RLock()
+	return l.RUnlock
+}
+
+// LockPartition bloquea el disco en modo lectura y la partición de forma exclusiva.
+// Retorna la función que libera ambos candados.
+func LockPartition(diskPath string, id string) func() {
+	unlockDisk := RLockDisk(diskPath)
+	l := partitionLock(id)
+	l.Lock()
+	return func() {
+		l.Unlock()
+		unlockDisk()
+	}
+}
+
+// RLockPartition bloquea el disco y la partición en modo lectura.
+// Retorna la función que libera ambos candados.
+func RLockPartition(diskPath string, id string) func() {
+	unlockDisk := RLockDisk(diskPath)
+	l := partitionLock(id)
+	l.RLock()
+	return func() {
+		l.RUnlock()
+		unlockDisk()
+	}
+}
@@ -1,26 +1,155 @@
 package OutPut
 
 import (
-	"bytes"
 	"fmt"
+	"io"
+	"strings"
+	"sync"
 )
 
-var outputBuffer bytes.Buffer
+// Level indica la severidad de una línea de salida
+type Level int
 
-func Println(a ...any) {
-	fmt.Println(a...) // Imprime en consola normal
-	fmt.Fprintln(&outputBuffer, a...)
+const (
+	Info Level = iota
+	Warning
+	Error
+)
+
+func (l Level) String() string {
+	switch l {
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	default:
+		return "info"
+	}
+}
+
+// MarshalText permite serializar el nivel como texto ("info", "warning", "error")
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// Line es una línea de salida con su severidad
+type Line struct {
+	Level Level  `json:"level"`
+	Text  string `json:"text"`
+}
+
+// Output acumula la salida de consola de una ejecución de comandos.
+// Cada petición crea su propio Output y lo pasa a los comandos que ejecuta,
+// así las ejecuciones concurrentes no mezclan su salida.
+// Un Output nil descarta todo lo que se escribe.
+type Output struct {
+	mu    sync.Mutex
+	lines []Line
+	echo  io.Writer
+}
+
+// New crea un Output vacío
+func New() *Output {
+	return &Output{}
+}
+
+// NewConsole crea un Output que además copia cada línea en w (por ejemplo os.Stdout)
+func NewConsole(w io.Writer) *Output {
+	return &Output{echo: w}
+}
+
+func (o *Output) write(level Level, text string) {
+	if o == nil {
+		return
+	}
+	text = strings.TrimSuffix(text, "\n")
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, line := range strings.Split(text, "\n") {
+		o.lines = append(o.lines, Line{Level: level, Text: line})
+		if o.echo != nil {
+			fmt.Fprintln(o.echo, line)
+		}
+	}
+}
+
+// Println escribe una línea informativa
+func (o *Output) Println(a ...any) {
+	o.write(Info, fmt.Sprintln(a...))
+}
+
+// Printf escribe una línea informativa con formato
+func (o *Output) Printf(format string, a ...any) {
+	o.write(Info, fmt.Sprintf(format, a...))
+}
+
+// Warning escribe una línea de advertencia
+func (o *Output) Warning(a ...any) {
+	o.write(Warning, fmt.Sprintln(a...))
+}
+
+// Warningf escribe una línea de advertencia con formato
+func (o *Output) Warningf(format string, a ...any) {
+	o.write(Warning, fmt.Sprintf(format, a...))
+}
+
+// Error escribe una línea de error
+func (o *Output) Error(a ...any) {
+	o.write(Error, fmt.Sprintln(a...))
+}
+
+// Errorf escribe una línea de error con formato
+func (o *Output) Errorf(format string, a ...any) {
+	o.write(Error, fmt.Sprintf(format, a...))
+}
+
+// Lines retorna una copia de las líneas escritas hasta el momento
+func (o *Output) Lines() []Line {
+	if o == nil {
+		return nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	lines := make([]Line, len(o.lines))
+	copy(lines, o.lines)
+	return lines
+}
+
+// Len retorna la cantidad de líneas escritas hasta el momento
+func (o *Output) Len() int {
+	if o == nil {
+		return 0
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.lines)
 }
 
-func Printf(format string, a ...any) {
-	fmt.Printf(format, a...) // Imprime en consola normal
-	fmt.Fprintf(&outputBuffer, format, a...)
+// Since retorna una copia de las líneas escritas a partir de la posición n
+func (o *Output) Since(n int) []Line {
+	lines := o.Lines()
+	if n < 0 || n > len(lines) {
+		n = len(lines)
+	}
+	return lines[n:]
 }
 
-func GetOutput() string {
-	return outputBuffer.String()
+// HasErrors indica si se escribió al menos una línea de error
+func (o *Output) HasErrors() bool {
+	for _, line := range o.Lines() {
+		if line.Level == Error {
+			return true
+		}
+	}
+	return false
 }
 
-func Clear() {
-	outputBuffer.Reset()
+// String retorna toda la salida como texto, una línea por renglón
+func (o *Output) String() string {
+	var sb strings.Builder
+	for _, line := range o.Lines() {
+		sb.WriteString(line.Text)
+		sb.WriteString("\n")
+	}
+	return sb.String()
 }
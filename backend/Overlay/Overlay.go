@@ -0,0 +1,586 @@
+package Overlay
+
+import (
+	"MIA_P1/Structs"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Un dry-run ejecuta los comandos sobre copias de los discos. Mientras hay un
+// overlay activo, Utilities.OpenFile y Utilities.CreateFile usan la copia en vez
+// del disco real: un disco existente se copia la primera vez que se abre, los
+// discos nuevos se crean directamente en la carpeta temporal y rmdisk solo marca
+// el disco como eliminado. Ningún .dsk real se modifica.
+//
+// Los cambios se obtienen al final comparando cada copia con su disco original.
+//
+// Un script transaccional usa el modo Snapshot: los comandos escriben en los
+// discos reales, pero antes de tocar un disco por primera vez se guarda una copia.
+// Si el script falla, Rollback devuelve cada disco a su copia y borra los creados.
+
+// Mode indica a dónde van las escrituras mientras el overlay está activo
+type Mode int
+
+const (
+	DryRun   Mode = iota // las escrituras van a las copias
+	Snapshot             // las escrituras van a los discos reales y las copias permiten deshacerlas
+)
+
+// Kind es el tipo de cambio que haría un dry-run
+type Kind string
+
+const (
+	DiskCreated        Kind = "disk_created"
+	DiskRemoved        Kind = "disk_removed"
+	PartitionCreated   Kind = "partition_created"
+	PartitionDeleted   Kind = "partition_deleted"
+	PartitionResized   Kind = "partition_resized"
+	PartitionMounted   Kind = "partition_mounted"
+	PartitionUnmounted Kind = "partition_unmounted"
+	FilesystemCreated  Kind = "filesystem_created"
+	InodesAllocated    Kind = "inodes_allocated"
+	InodesFreed        Kind = "inodes_freed"
+	BlocksAllocated    Kind = "blocks_allocated"
+	BlocksFreed        Kind = "blocks_freed"
+	FolderCreated      Kind = "folder_created"
+	FileWritten        Kind = "file_written"
+)
+
+// Change es un cambio que el dry-run habría hecho en un disco
+type Change struct {
+	Disk      string `json:"disk"`
+	Partition string `json:"partition,omitempty"`
+	Kind      Kind   `json:"kind"`
+	Path      string `json:"path,omitempty"`
+	Count     int    `json:"count,omitempty"`
+	Message   string `json:"message"`
+}
+
+// Overlay guarda las copias de los discos tocados durante un dry-run o una transacción
+type Overlay struct {
+	mode    Mode
+	dir     string
+	files   map[string]string // ruta real absoluta → ruta de la copia
+	names   map[string]string // ruta real absoluta → ruta como la pidió el comando
+	removed map[string]bool   // discos reales eliminados durante el dry-run
+	existed map[string]bool   // discos que existían antes de la transacción
+}
+
+var (
+	mu     sync.Mutex
+	active *Overlay
+)
+
+// Begin crea la carpeta temporal y activa el overlay. Solo puede haber un
+// overlay activo a la vez; quien lo llama debe tener el candado global exclusivo.
+func Begin(mode Mode) (*Overlay, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if active != nil {
+		return nil, fmt.Errorf("ya hay un dry-run o un script transaccional en curso")
+	}
+	dir, err := os.MkdirTemp("", "mia-overlay-")
+	if err != nil {
+		return nil, fmt.Errorf("error al crear la carpeta de copias: %v", err)
+	}
+	active = &Overlay{
+		mode:    mode,
+		dir:     dir,
+		files:   make(map[string]string),
+		names:   make(map[string]string),
+		removed: make(map[string]bool),
+		existed: make(map[string]bool),
+	}
+	return active, nil
+}
+
+// End desactiva el overlay y borra las copias
+func (o *Overlay) End() {
+	mu.Lock()
+	if active == o {
+		active = nil
+	}
+	mu.Unlock()
+	os.RemoveAll(o.dir)
+}
+
+// Active indica si hay un dry-run en curso
+func Active() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return active != nil && active.mode == DryRun
+}
+
+// InProgress indica si hay un dry-run o un script transaccional en curso
+func InProgress() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return active != nil
+}
+
+// Path retorna la ruta que se debe abrir para el archivo indicado. Sin overlay
+// activo es la misma ruta; en un dry-run es la copia, que se crea si hace falta,
+// y en una transacción es la ruta real después de guardar su copia.
+func Path(name string) (string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if active == nil {
+		return name, nil
+	}
+	if active.mode == Snapshot {
+		return name, active.snapshot(name)
+	}
+	return active.resolve(name)
+}
+
+// Stat es como os.Stat pero ve los discos del overlay
+func Stat(name string) (os.FileInfo, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if active == nil || active.mode == Snapshot {
+		return os.Stat(name)
+	}
+	key, copyPath := active.track(name)
+	if info, err := os.Stat(copyPath); err == nil {
+		return info, nil
+	}
+	if active.removed[key] {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return os.Stat(name)
+}
+
+// Remove es como os.Remove pero con overlay activo solo elimina la copia
+func Remove(name string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if active == nil {
+		return os.Remove(name)
+	}
+	if active.mode == Snapshot {
+		if err := active.snapshot(name); err != nil {
+			return err
+		}
+		return os.Remove(name)
+	}
+	key, copyPath := active.track(name)
+	copyErr := os.Remove(copyPath)
+	if active.removed[key] {
+		return copyErr
+	}
+	if _, err := os.Stat(name); err != nil {
+		return copyErr
+	}
+	active.removed[key] = true
+	return nil
+}
+
+// ReadDir es como os.ReadDir pero con overlay activo incluye los discos creados
+// y omite los eliminados durante el dry-run
+func ReadDir(dir string) ([]os.DirEntry, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	entries, err := os.ReadDir(dir)
+	if active == nil || active.mode == Snapshot {
+		return entries, err
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	byName := make(map[string]os.DirEntry)
+	for _, entry := range entries {
+		key, _ := active.track(filepath.Join(dir, entry.Name()))
+		if !active.removed[key] {
+			byName[entry.Name()] = entry
+		}
+	}
+	_, copyDir := active.track(dir)
+	copies, _ := os.ReadDir(copyDir)
+	for _, entry := range copies {
+		byName[entry.Name()] = entry
+	}
+
+	result := make([]os.DirEntry, 0, len(byName))
+	for _, entry := range byName {
+		result = append(result, entry)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name() < result[j].Name() })
+	return result, nil
+}
+
+// track registra el archivo y retorna su clave y la ruta de su copia. Se llama con mu tomado.
+func (o *Overlay) track(name string) (string, string) {
+	key, err := filepath.Abs(name)
+	if err != nil {
+		key = filepath.Clean(name)
+	}
+	copyPath := filepath.Join(o.dir, key)
+	if _, ok := o.names[key]; !ok {
+		o.names[key] = name
+	}
+	return key, copyPath
+}
+
+// resolve retorna la copia del archivo y la crea la primera vez. Se llama con mu tomado.
+func (o *Overlay) resolve(name string) (string, error) {
+	key, copyPath := o.track(name)
+	if path, ok := o.files[key]; ok {
+		return path, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(copyPath), os.ModePerm); err != nil {
+		return "", err
+	}
+	o.files[key] = copyPath
+	if o.removed[key] {
+		return copyPath, nil
+	}
+
+	if _, err := os.Stat(name); os.IsNotExist(err) {
+		return copyPath, nil // disco nuevo: se crea directamente en el overlay
+	}
+	if err := copyFile(copyPath, name); err != nil {
+		return "", fmt.Errorf("error al copiar %s para el dry-run: %v", name, err)
+	}
+	return copyPath, nil
+}
+
+// snapshot guarda una copia del archivo antes de que se modifique por primera vez
+// en la transacción. Se llama con mu tomado.
+func (o *Overlay) snapshot(name string) error {
+	key, copyPath := o.track(name)
+	if _, ok := o.files[key]; ok {
+		return nil
+	}
+	if _, err := os.Stat(name); os.IsNotExist(err) {
+		o.files[key] = copyPath
+		return nil // disco nuevo: Rollback lo borra
+	}
+	if err := os.MkdirAll(filepath.Dir(copyPath), os.ModePerm); err != nil {
+		return err
+	}
+	if err := copyFile(copyPath, name); err != nil {
+		return fmt.Errorf("error al guardar la copia de %s: %v", name, err)
+	}
+	o.files[key] = copyPath
+	o.existed[key] = true
+	return nil
+}
+
+// Rollback devuelve cada disco tocado en la transacción a su copia y borra los
+// discos que se crearon. Sigue con los demás discos aunque alguno falle.
+func (o *Overlay) Rollback() error {
+	mu.Lock()
+	defer mu.Unlock()
+	var firstErr error
+	for key, copyPath := range o.files {
+		name := o.names[key]
+		var err error
+		if o.existed[key] {
+			err = copyFile(name, copyPath)
+		} else if err = os.Remove(name); os.IsNotExist(err) {
+			err = nil
+		}
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("error al restaurar %s: %v", name, err)
+		}
+	}
+	return firstErr
+}
+
+// copyFile reemplaza el contenido de dst con el de src. dst se escribe en el
+// mismo archivo para que los descriptores abiertos sigan siendo válidos.
+func copyFile(dst string, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Changes compara cada disco del overlay con el original y retorna las diferencias
+func (o *Overlay) Changes() []Change {
+	mu.Lock()
+	keys := make([]string, 0, len(o.names))
+	for key := range o.names {
+		if _, ok := o.files[key]; ok || o.removed[key] {
+			keys = append(keys, key)
+		}
+	}
+	mu.Unlock()
+	sort.Strings(keys)
+
+	var changes []Change
+	for _, key := range keys {
+		disk := filepath.Base(o.names[key])
+		original, _ := os.Open(o.names[key])
+		current, err := os.Open(filepath.Join(o.dir, key))
+		switch {
+		case err != nil && original != nil:
+			changes = append(changes, Change{Disk: disk, Kind: DiskRemoved, Message: fmt.Sprintf("Se eliminaría el disco %s", disk)})
+		case err == nil && original == nil:
+			size := int64(0)
+			if info, err := current.Stat(); err == nil {
+				size = info.Size()
+			}
+			changes = append(changes, Change{Disk: disk, Kind: DiskCreated, Count: int(size), Message: fmt.Sprintf("Se crearía el disco %s de %d bytes", disk, size)})
+		}
+		if current != nil {
+			changes = append(changes, diffDisk(disk, original, current)...)
+			current.Close()
+		}
+		if original != nil {
+			original.Close()
+		}
+	}
+	return changes
+}
+
+func readAt(file *os.File, data interface{}, position int64) error {
+	if file == nil {
+		return os.ErrNotExist
+	}
+	return binary.Read(io.NewSectionReader(file, position, int64(binary.Size(data))), binary.LittleEndian, data)
+}
+
+func trim(b []byte) string {
+	return strings.Trim(string(b), "\x00")
+}
+
+// diffDisk compara las particiones del MBR y sus sistemas de archivos
+func diffDisk(disk string, original *os.File, current *os.File) []Change {
+	var before, after Structs.MRB
+	readAt(original, &before, 0) // un disco nuevo se compara con un MBR vacío
+	if err := readAt(current, &after, 0); err != nil {
+		return nil
+	}
+
+	old := make(map[string]Structs.Partition)
+	for _, p := range before.Partitions {
+		if p.Size != 0 {
+			old[strings.ToUpper(trim(p.Name[:]))] = p
+		}
+	}
+
+	var changes []Change
+	for _, p := range after.Partitions {
+		if p.Size == 0 {
+			continue
+		}
+		name := trim(p.Name[:])
+		prev, existed := old[strings.ToUpper(name)]
+		delete(old, strings.ToUpper(name))
+		id := trim(p.Id[:])
+
+		switch {
+		case !existed:
+			changes = append(changes, Change{Disk: disk, Partition: name, Kind: PartitionCreated, Count: int(p.Size),
+				Message: fmt.Sprintf("Se crearía la partición %s (tipo %s, %d bytes) en %s", name, trim(p.Type[:]), p.Size, disk)})
+		case prev.Size != p.Size:
+			changes = append(changes, Change{Disk: disk, Partition: name, Kind: PartitionResized, Count: int(p.Size - prev.Size),
+				Message: fmt.Sprintf("La partición %s pasaría de %d a %d bytes", name, prev.Size, p.Size)})
+		}
+		if prevID := trim(prev.Id[:]); id != prevID {
+			if id != "" {
+				changes = append(changes, Change{Disk: disk, Partition: name, Kind: PartitionMounted,
+					Message: fmt.Sprintf("Se montaría la partición %s con el ID %s", name, id)})
+			} else {
+				changes = append(changes, Change{Disk: disk, Partition: name, Kind: PartitionUnmounted,
+					Message: fmt.Sprintf("Se desmontaría la partición %s (ID %s)", name, prevID)})
+			}
+		}
+
+		var prevFile *os.File
+		if existed && prev.Start == p.Start {
+			prevFile = original
+		}
+		changes = append(changes, diffFilesystem(disk, name, prevFile, current, p.Start)...)
+	}
+
+	for _, p := range before.Partitions {
+		if _, ok := old[strings.ToUpper(trim(p.Name[:]))]; ok && p.Size != 0 {
+			changes = append(changes, Change{Disk: disk, Partition: trim(p.Name[:]), Kind: PartitionDeleted,
+				Message: fmt.Sprintf("Se eliminaría la partición %s de %s", trim(p.Name[:]), disk)})
+		}
+	}
+	return changes
+}
+
+// filesystem es el estado de un sistema de archivos leído de un disco
+type filesystem struct {
+	sb         Structs.Superblock
+	raw        []byte // tabla de inodos sin decodificar
+	inodes     []Structs.Inode
+	usedInodes []bool
+	usedBlocks []bool
+}
+
+var inodeSize = binary.Size(Structs.Inode{})
+
+// loadFilesystem lee la partición formateada que empieza en start. mkfs no marca en
+// los bitmaps la raíz ni users.txt, así que un inodo también cuenta como usado si
+// tiene tipo y un bloque si lo apunta un inodo usado.
+func loadFilesystem(file *os.File, start int64) *filesystem {
+	fs := &filesystem{}
+	if err := readAt(file, &fs.sb, start); err != nil || fs.sb.S_magic != 0xEF53 || fs.sb.S_inodes_count <= 0 {
+		return nil
+	}
+	bmInode := make([]byte, fs.sb.S_inodes_count)
+	bmBlock := make([]byte, fs.sb.S_blocks_count)
+	fs.raw = make([]byte, int(fs.sb.S_inodes_count)*inodeSize)
+	file.ReadAt(bmInode, int64(fs.sb.S_bm_inode_start))
+	file.ReadAt(bmBlock, int64(fs.sb.S_bm_block_start))
+	file.ReadAt(fs.raw, int64(fs.sb.S_inode_start))
+
+	fs.inodes = make([]Structs.Inode, fs.sb.S_inodes_count)
+	binary.Read(bytes.NewReader(fs.raw), binary.LittleEndian, fs.inodes)
+	fs.usedInodes = make([]bool, len(bmInode))
+	fs.usedBlocks = make([]bool, len(bmBlock))
+	for i, bit := range bmBlock {
+		fs.usedBlocks[i] = bit != 0
+	}
+	for i, bit := range bmInode {
+		fs.usedInodes[i] = bit != 0 || fs.inodes[i].I_type[0] != 0
+		if !fs.usedInodes[i] {
+			continue
+		}
+		for _, block := range fs.inodes[i].I_block[:13] {
+			if block >= 0 && int(block) < len(fs.usedBlocks) {
+				fs.usedBlocks[block] = true
+			}
+		}
+	}
+	return fs
+}
+
+func (fs *filesystem) inode(index int) []byte {
+	return fs.raw[index*inodeSize : (index+1)*inodeSize]
+}
+
+// diffFilesystem compara los inodos y bloques usados de una partición formateada
+func diffFilesystem(disk string, partition string, original *os.File, current *os.File, start int64) []Change {
+	after := loadFilesystem(current, start)
+	if after == nil {
+		return nil
+	}
+	before := loadFilesystem(original, start)
+	// mkfs escribe S_mtime, así que un cambio indica que la partición se formateó de nuevo
+	if before != nil && before.sb.S_mtime != after.sb.S_mtime {
+		before = nil
+	}
+
+	var changes []Change
+	if before == nil {
+		changes = append(changes, Change{Disk: disk, Partition: partition, Kind: FilesystemCreated,
+			Message: fmt.Sprintf("Se formatearía la partición %s con %d inodos y %d bloques", partition, after.sb.S_inodes_count, after.sb.S_blocks_count)})
+		before = &filesystem{}
+	}
+
+	allocated, freed := diffUsed(before.usedInodes, after.usedInodes)
+	if allocated > 0 {
+		changes = append(changes, Change{Disk: disk, Partition: partition, Kind: InodesAllocated, Count: allocated,
+			Message: fmt.Sprintf("Se asignarían %d inodos en %s", allocated, partition)})
+	}
+	if freed > 0 {
+		changes = append(changes, Change{Disk: disk, Partition: partition, Kind: InodesFreed, Count: freed,
+			Message: fmt.Sprintf("Se liberarían %d inodos en %s", freed, partition)})
+	}
+	allocated, freed = diffUsed(before.usedBlocks, after.usedBlocks)
+	if allocated > 0 {
+		changes = append(changes, Change{Disk: disk, Partition: partition, Kind: BlocksAllocated, Count: allocated,
+			Message: fmt.Sprintf("Se asignarían %d bloques en %s", allocated, partition)})
+	}
+	if freed > 0 {
+		changes = append(changes, Change{Disk: disk, Partition: partition, Kind: BlocksFreed, Count: freed,
+			Message: fmt.Sprintf("Se liberarían %d bloques en %s", freed, partition)})
+	}
+
+	paths := after.paths(current)
+	for i, used := range after.usedInodes {
+		if !used {
+			continue
+		}
+		isNew := i >= len(before.usedInodes) || !before.usedInodes[i]
+		if !isNew && bytes.Equal(before.inode(i), after.inode(i)) {
+			continue
+		}
+		inode := after.inodes[i]
+		path, ok := paths[int32(i)]
+		if !ok {
+			path = fmt.Sprintf("(inodo %d)", i)
+		}
+		switch {
+		case inode.I_type[0] == '1':
+			changes = append(changes, Change{Disk: disk, Partition: partition, Kind: FileWritten, Path: path, Count: int(inode.I_size),
+				Message: fmt.Sprintf("Se escribiría el archivo %s (%d bytes) en %s", path, inode.I_size, partition)})
+		case inode.I_type[0] == '0' && isNew:
+			changes = append(changes, Change{Disk: disk, Partition: partition, Kind: FolderCreated, Path: path,
+				Message: fmt.Sprintf("Se crearía la carpeta %s en %s", path, partition)})
+		}
+	}
+	return changes
+}
+
+func diffUsed(before []bool, after []bool) (allocated int, freed int) {
+	for i := range after {
+		was := i < len(before) && before[i]
+		if after[i] && !was {
+			allocated++
+		} else if !after[i] && was {
+			freed++
+		}
+	}
+	return allocated, freed
+}
+
+// paths recorre las carpetas desde la raíz y retorna la ruta de cada inodo
+func (fs *filesystem) paths(file *os.File) map[int32]string {
+	paths := map[int32]string{0: "/"}
+	pending := []int32{0}
+	for len(pending) > 0 {
+		index := pending[0]
+		pending = pending[1:]
+		inode := fs.inodes[index]
+		if inode.I_type[0] != '0' {
+			continue
+		}
+		for _, block := range inode.I_block[:12] {
+			if block < 0 || block >= fs.sb.S_blocks_count {
+				continue
+			}
+			var folder Structs.Folderblock
+			if err := readAt(file, &folder, int64(fs.sb.S_block_start)+int64(block)*int64(fs.sb.S_block_size)); err != nil {
+				continue
+			}
+			for _, content := range folder.B_content {
+				name := trim(content.B_name[:])
+				child := content.B_inodo
+				if name == "" || name == "." || name == ".." || child < 0 || child >= fs.sb.S_inodes_count {
+					continue
+				}
+				if _, seen := paths[child]; seen {
+					continue
+				}
+				paths[child] = strings.TrimSuffix(paths[index], "/") + "/" + name
+				pending = append(pending, child)
+			}
+		}
+	}
+	return paths
+}
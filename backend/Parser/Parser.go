@@ -0,0 +1,330 @@
+package Parser
+
+import (
+	"MIA_P1/Results"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Kind es el tipo de valor que acepta un parámetro
+type Kind int
+
+const (
+	String Kind = iota
+	Int
+	Bool
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Int:
+		return "int"
+	case Bool:
+		return "bool"
+	default:
+		return "string"
+	}
+}
+
+// Flag describe un parámetro de un comando
+type Flag struct {
+	Name       string
+	Kind       Kind
+	Required   bool
+	Default    string
+	Prefix     bool     // acepta nombres numerados como file1, file2, ...
+	Positional bool     // también se puede escribir sin nombre, como en "man mkdisk"
+	Values     []string // valores permitidos, sin distinguir mayúsculas
+	Help       string
+}
+
+// Schema describe los parámetros que acepta un comando
+type Schema struct {
+	Command     string
+	Flags       []Flag
+	Confirmable bool // el comando puede pedir confirmación y acepta -confirm y -force
+}
+
+// confirmFlags son los parámetros que aceptan los comandos que piden confirmación
+var confirmFlags = []Flag{
+	{Name: "confirm", Kind: Bool, Help: "Confirma la operación sin preguntar"},
+	{Name: "force", Kind: Bool, Help: "Igual que -confirm"},
+}
+
+// AllFlags retorna los parámetros del comando, incluyendo -confirm y -force si aplica
+func (s *Schema) AllFlags() []Flag {
+	if !s.Confirmable {
+		return s.Flags
+	}
+	return append(append([]Flag{}, s.Flags...), confirmFlags...)
+}
+
+// SplitCommand separa el nombre del comando (en minúsculas) de sus parámetros.
+// Los parámetros se retornan sin modificar para conservar los espacios entre comillas.
+func SplitCommand(input string) (string, string) {
+	input = strings.TrimSpace(input)
+	i := strings.IndexFunc(input, unicode.IsSpace)
+	if i < 0 {
+		return strings.ToLower(input), ""
+	}
+	return strings.ToLower(input[:i]), strings.TrimSpace(input[i:])
+}
+
+// Token es un parámetro leído de la línea de comando
+type Token struct {
+	Name     string // nombre en minúsculas, sin el guion; vacío si se escribió sin nombre
+	Value    string
+	HasValue bool
+	Pos      int // número de argumento, empezando en 1
+}
+
+// Tokenize separa los parámetros de un comando en la forma -nombre=valor o -nombre.
+// Los valores pueden ir entre comillas dobles para incluir espacios y un # fuera
+// de comillas inicia un comentario que se ignora. Una palabra sin guion se retorna
+// como un token sin nombre; Parse decide si el comando la acepta.
+func Tokenize(params string) ([]Token, error) {
+	var tokens []Token
+	runes := []rune(params)
+	i := 0
+	for {
+		for i < len(runes) && unicode.IsSpace(runes[i]) {
+			i++
+		}
+		if i >= len(runes) || runes[i] == '#' {
+			return tokens, nil
+		}
+
+		pos := len(tokens) + 1
+		if runes[i] != '-' {
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) {
+				i++
+			}
+			value := strings.Trim(string(runes[start:i]), `"`)
+			tokens = append(tokens, Token{Value: value, HasValue: true, Pos: pos})
+			continue
+		}
+		i++
+
+		start := i
+		for i < len(runes) && runes[i] != '=' && !unicode.IsSpace(runes[i]) {
+			i++
+		}
+		name := strings.ToLower(string(runes[start:i]))
+		if name == "" {
+			return nil, Results.Errorf(Results.InvalidParams, "argumento %d: falta el nombre del parámetro", pos)
+		}
+
+		token := Token{Name: name, Pos: pos}
+		if i < len(runes) && runes[i] == '=' {
+			i++
+			token.HasValue = true
+			if i < len(runes) && runes[i] == '"' {
+				i++
+				start = i
+				for i < len(runes) && runes[i] != '"' {
+					i++
+				}
+				if i >= len(runes) {
+					return nil, Results.Errorf(Results.InvalidParams, "argumento %d: falta cerrar las comillas de -%s", pos, name)
+				}
+				token.Value = string(runes[start:i])
+				i++
+				if i < len(runes) && !unicode.IsSpace(runes[i]) {
+					return nil, Results.Errorf(Results.InvalidParams, "argumento %d: texto inesperado después de las comillas de -%s", pos, name)
+				}
+			} else {
+				start = i
+				for i < len(runes) && !unicode.IsSpace(runes[i]) {
+					i++
+				}
+				token.Value = string(runes[start:i])
+			}
+		}
+		tokens = append(tokens, token)
+	}
+}
+
+// Args son los parámetros de un comando ya validados contra su esquema
+type Args struct {
+	values map[string]string
+	set    map[string]bool
+	schema *Schema
+}
+
+// Parse lee los parámetros de un comando y los valida contra su esquema.
+// Reporta en un solo error todos los parámetros desconocidos, duplicados,
+// con valor inválido y los obligatorios que faltan.
+func Parse(schema *Schema, params string) (*Args, error) {
+	tokens, err := Tokenize(params)
+	if err != nil {
+		return nil, Results.Errorf(Results.InvalidParams, "parámetros inválidos para %s: %v", schema.Command, err)
+	}
+
+	args := &Args{values: make(map[string]string), set: make(map[string]bool), schema: schema}
+	seen := make(map[string]int)
+	var problems []string
+
+	for _, token := range tokens {
+		if token.Name == "" {
+			positional := schema.positional()
+			if positional == nil {
+				problems = append(problems, fmt.Sprintf("argumento %d: se esperaba -parametro=valor y se encontró %q", token.Pos, token.Value))
+				continue
+			}
+			token.Name = positional.Name
+		}
+		flag := schema.lookup(token.Name)
+		if flag == nil {
+			problems = append(problems, fmt.Sprintf("argumento %d: parámetro desconocido -%s", token.Pos, token.Name))
+			continue
+		}
+		if prev, ok := seen[token.Name]; ok {
+			problems = append(problems, fmt.Sprintf("argumento %d: parámetro -%s duplicado (ya aparece en el argumento %d)", token.Pos, token.Name, prev))
+			continue
+		}
+		seen[token.Name] = token.Pos
+
+		value := token.Value
+		switch flag.Kind {
+		case Bool:
+			if !token.HasValue {
+				value = "true"
+			} else if b, err := strconv.ParseBool(value); err != nil {
+				problems = append(problems, fmt.Sprintf("argumento %d: -%s debe ser true o false", token.Pos, token.Name))
+				continue
+			} else {
+				value = strconv.FormatBool(b)
+			}
+		case Int:
+			if _, err := strconv.Atoi(value); err != nil || !token.HasValue {
+				problems = append(problems, fmt.Sprintf("argumento %d: -%s debe ser un número entero", token.Pos, token.Name))
+				continue
+			}
+		default:
+			if !token.HasValue || value == "" {
+				problems = append(problems, fmt.Sprintf("argumento %d: -%s requiere un valor", token.Pos, token.Name))
+				continue
+			}
+			if len(flag.Values) > 0 && !flag.allows(value) {
+				problems = append(problems, fmt.Sprintf("argumento %d: -%s=%s no es válido, debe ser uno de: %s", token.Pos, token.Name, value, strings.Join(flag.Values, ", ")))
+				continue
+			}
+		}
+		args.values[token.Name] = value
+		args.set[token.Name] = true
+	}
+
+	for _, flag := range schema.Flags {
+		if !flag.Required {
+			continue
+		}
+		if flag.Prefix && len(args.Prefixed(flag.Name)) > 0 {
+			continue
+		}
+		if _, ok := seen[flag.Name]; ok && !flag.Prefix {
+			continue // ya se reportó su valor inválido
+		}
+		if flag.Prefix {
+			problems = append(problems, fmt.Sprintf("falta el parámetro obligatorio -%s1", flag.Name))
+		} else {
+			problems = append(problems, fmt.Sprintf("falta el parámetro obligatorio -%s", flag.Name))
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, Results.Errorf(Results.InvalidParams, "parámetros inválidos para %s: %s", schema.Command, strings.Join(problems, "; "))
+	}
+	return args, nil
+}
+
+// lookup busca el parámetro por nombre, incluyendo los nombres numerados
+func (s *Schema) lookup(name string) *Flag {
+	flags := s.AllFlags()
+	for i := range flags {
+		flag := &flags[i]
+		if flag.Name == name {
+			return flag
+		}
+		if flag.Prefix && strings.HasPrefix(name, flag.Name) {
+			if _, err := strconv.Atoi(strings.TrimPrefix(name, flag.Name)); err == nil {
+				return flag
+			}
+		}
+	}
+	return nil
+}
+
+// positional retorna el parámetro que se puede escribir sin nombre, si hay uno
+func (s *Schema) positional() *Flag {
+	for i := range s.Flags {
+		if s.Flags[i].Positional {
+			return &s.Flags[i]
+		}
+	}
+	return nil
+}
+
+// allows indica si el valor está entre los permitidos del parámetro
+func (f *Flag) allows(value string) bool {
+	for _, v := range f.Values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Has indica si el parámetro fue escrito en la línea de comando
+func (a *Args) Has(name string) bool {
+	return a.set[name]
+}
+
+// String retorna el valor del parámetro o su valor por defecto
+func (a *Args) String(name string) string {
+	if v, ok := a.values[name]; ok {
+		return v
+	}
+	if flag := a.schema.lookup(name); flag != nil {
+		return flag.Default
+	}
+	return ""
+}
+
+// Int retorna el valor entero del parámetro o su valor por defecto
+func (a *Args) Int(name string) int {
+	n, _ := strconv.Atoi(a.String(name))
+	return n
+}
+
+// Bool retorna el valor booleano del parámetro o su valor por defecto
+func (a *Args) Bool(name string) bool {
+	b, _ := strconv.ParseBool(a.String(name))
+	return b
+}
+
+// Confirmed indica si el usuario ya confirmó la operación con -confirm o -force
+func (a *Args) Confirmed() bool {
+	return a.Bool("confirm") || a.Bool("force")
+}
+
+// Confirm marca la operación como confirmada, igual que si se hubiera escrito -confirm
+func (a *Args) Confirm() {
+	a.values["confirm"] = "true"
+	a.set["confirm"] = true
+}
+
+// Prefixed retorna los parámetros numerados de un prefijo (file1, file2, ...)
+func (a *Args) Prefixed(prefix string) map[string]string {
+	values := make(map[string]string)
+	for name, value := range a.values {
+		if strings.HasPrefix(name, prefix) {
+			if _, err := strconv.Atoi(strings.TrimPrefix(name, prefix)); err == nil {
+				values[name] = value
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,365 @@
+package Preprocessor
+
+import (
+	"MIA_P1/Parser"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Un script .sdaa se prepara antes de ejecutarlo:
+//   - set NOMBRE=valor define una variable y ${NOMBRE} se reemplaza en las líneas
+//     siguientes, incluidas las de los archivos incluidos.
+//   - include -path=archivo.sdaa inserta otro script. La ruta es relativa al archivo
+//     que lo incluye (o a la carpeta actual en el script principal).
+//   - on-error -action=stop|continue indica qué hacer cuando un comando falla. Aplica
+//     al resto del archivo y a los que incluye, hasta el siguiente on-error.
+//   - if-exists ... / else / end ejecutan un bloque solo si existe un disco
+//     (-driveletter), una partición (-driveletter y -name) o un ID montado (-id).
+//
+// set, include y on-error se resuelven al leer el script, sin importar los if-exists.
+// Los if-exists se evalúan al ejecutar: cada uno guarda el índice al que se salta
+// cuando la condición es falsa, así un script pausado se reanuda por índice.
+//
+// Los include y los execute que forman un ciclo se reportan antes de ejecutar nada.
+
+// Kind es el tipo de un paso del script
+type Kind int
+
+const (
+	Command Kind = iota
+	IfExists
+	Else
+	End
+	Comment // se conserva para mostrarlo cuando el script corre con execute
+)
+
+// OnError indica qué hacer cuando un comando falla
+type OnError string
+
+const (
+	Continue OnError = "continue"
+	Stop     OnError = "stop"
+)
+
+// Step es una línea del script ya preparada
+type Step struct {
+	Kind    Kind
+	Text    string // línea con las variables reemplazadas
+	File    string // archivo de origen; vacío para el script principal
+	Line    int    // número de línea en su archivo, desde 1
+	OnError OnError
+	Jump    int // IfExists: índice de su else o end; Else: índice de su end
+}
+
+// Params retorna el nombre y los parámetros del paso
+func (s Step) Params() (string, string) {
+	return Parser.SplitCommand(s.Text)
+}
+
+// Condition retorna los parámetros de un if-exists, ya validados al preparar el script
+func (s Step) Condition() (*Parser.Args, error) {
+	_, params := s.Params()
+	return Parser.Parse(ifExistsSchema, params)
+}
+
+// Error es un problema al preparar el script
+type Error struct {
+	File    string
+	Line    int
+	Message string
+	cycle   bool
+}
+
+func (e *Error) Error() string {
+	if e.File != "" {
+		return fmt.Sprintf("%s, línea %d: %s", e.File, e.Line, e.Message)
+	}
+	return fmt.Sprintf("línea %d: %s", e.Line, e.Message)
+}
+
+// Errors son todos los problemas encontrados al preparar un script
+type Errors []*Error
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+var (
+	includeSchema = &Parser.Schema{Command: "include", Flags: []Parser.Flag{
+		{Name: "path", Required: true, Help: "Ruta del script a incluir, relativa al script actual"},
+	}}
+	onErrorSchema = &Parser.Schema{Command: "on-error", Flags: []Parser.Flag{
+		{Name: "action", Required: true, Values: []string{"continue", "stop"}, Help: "Qué hacer cuando un comando falla"},
+	}}
+	ifExistsSchema = &Parser.Schema{Command: "if-exists", Flags: []Parser.Flag{
+		{Name: "driveletter", Help: "Letra del disco"},
+		{Name: "name", Help: "Nombre de la partición, junto con -driveletter"},
+		{Name: "id", Help: "ID de una partición montada"},
+	}}
+
+	variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	reference    = regexp.MustCompile(`\$\{([^}]*)\}`)
+)
+
+// Expand prepara un script escrito directamente (por ejemplo desde la API)
+func Expand(script string) ([]Step, error) {
+	e := &expander{vars: make(map[string]string)}
+	e.expand(script, "", ".", Continue)
+	return e.result()
+}
+
+// ExpandFile lee y prepara un archivo .sdaa
+func ExpandFile(path string) ([]Step, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	e := &expander{vars: make(map[string]string), chain: []string{absolute(path)}}
+	e.expand(string(content), path, filepath.Dir(path), Continue)
+	return e.result()
+}
+
+// expander acumula los pasos y errores de un script y de los que incluye
+type expander struct {
+	vars  map[string]string
+	steps []Step
+	errs  Errors
+	chain []string // archivos abiertos por include o execute, para detectar ciclos
+}
+
+func (e *expander) result() ([]Step, error) {
+	if len(e.errs) > 0 {
+		return e.steps, e.errs
+	}
+	return e.steps, nil
+}
+
+func (e *expander) fail(file string, line int, format string, a ...any) {
+	e.errs = append(e.errs, &Error{File: file, Line: line, Message: fmt.Sprintf(format, a...)})
+}
+
+// block es un if-exists abierto en el archivo actual
+type block struct {
+	ifIndex   int
+	elseIndex int // -1 si no tiene else
+	line      int
+}
+
+func (e *expander) expand(content string, file string, dir string, onError OnError) {
+	var open []block
+	for n, raw := range strings.Split(content, "\n") {
+		number := n + 1
+		line := strings.TrimSpace(raw)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			e.steps = append(e.steps, Step{Kind: Comment, Text: line, File: file, Line: number, OnError: onError})
+			continue
+		}
+		line, ok := e.substitute(file, number, line)
+		if !ok {
+			continue
+		}
+		step := Step{Kind: Command, Text: line, File: file, Line: number, OnError: onError}
+
+		command, params := Parser.SplitCommand(line)
+		switch command {
+		case "set":
+			e.set(file, number, params)
+		case "on-error":
+			args, err := Parser.Parse(onErrorSchema, params)
+			if err != nil {
+				e.fail(file, number, "%v", err)
+				continue
+			}
+			onError = OnError(strings.ToLower(args.String("action")))
+		case "include":
+			args, err := Parser.Parse(includeSchema, params)
+			if err != nil {
+				e.fail(file, number, "%v", err)
+				continue
+			}
+			path := args.String("path")
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+			e.include(file, number, path, onError)
+		case "if-exists":
+			args, err := Parser.Parse(ifExistsSchema, params)
+			if err != nil {
+				e.fail(file, number, "%v", err)
+				continue
+			}
+			if !args.Has("driveletter") && !args.Has("id") {
+				e.fail(file, number, "if-exists necesita -driveletter o -id")
+				continue
+			}
+			if args.Has("name") && !args.Has("driveletter") {
+				e.fail(file, number, "if-exists -name necesita -driveletter")
+				continue
+			}
+			step.Kind = IfExists
+			open = append(open, block{ifIndex: len(e.steps), elseIndex: -1, line: number})
+			e.steps = append(e.steps, step)
+		case "else":
+			if len(open) == 0 {
+				e.fail(file, number, "else sin if-exists")
+				continue
+			}
+			top := &open[len(open)-1]
+			if top.elseIndex >= 0 {
+				e.fail(file, number, "el if-exists de la línea %d ya tiene else", top.line)
+				continue
+			}
+			top.elseIndex = len(e.steps)
+			e.steps[top.ifIndex].Jump = len(e.steps)
+			step.Kind = Else
+			e.steps = append(e.steps, step)
+		case "end":
+			if len(open) == 0 {
+				e.fail(file, number, "end sin if-exists")
+				continue
+			}
+			top := open[len(open)-1]
+			open = open[:len(open)-1]
+			if top.elseIndex >= 0 {
+				e.steps[top.elseIndex].Jump = len(e.steps)
+			} else {
+				e.steps[top.ifIndex].Jump = len(e.steps)
+			}
+			step.Kind = End
+			e.steps = append(e.steps, step)
+		case "execute":
+			e.checkExecute(file, number, params)
+			e.steps = append(e.steps, step)
+		default:
+			e.steps = append(e.steps, step)
+		}
+	}
+	for _, b := range open {
+		e.fail(file, b.line, "if-exists sin end")
+	}
+}
+
+// substitute reemplaza las referencias ${NOMBRE} por el valor de la variable
+func (e *expander) substitute(file string, line int, text string) (string, bool) {
+	ok := true
+	result := reference.ReplaceAllStringFunc(text, func(ref string) string {
+		name := ref[2 : len(ref)-1]
+		value, defined := e.vars[name]
+		if !defined {
+			e.fail(file, line, "la variable ${%s} no está definida", name)
+			ok = false
+		}
+		return value
+	})
+	return result, ok
+}
+
+// set define una variable con la forma NOMBRE=valor. El valor puede ir entre comillas.
+func (e *expander) set(file string, line int, params string) {
+	name, value, found := strings.Cut(params, "=")
+	name = strings.TrimSpace(name)
+	if !found || !variableName.MatchString(name) {
+		e.fail(file, line, "se esperaba set NOMBRE=valor")
+		return
+	}
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		value = value[1 : len(value)-1]
+	}
+	e.vars[name] = value
+}
+
+// include inserta los pasos de otro archivo con las variables y el on-error actuales
+func (e *expander) include(file string, line int, path string, onError OnError) {
+	if cycle := e.cycle(path); cycle != "" {
+		e.errs = append(e.errs, &Error{File: file, Line: line, Message: "include forma un ciclo: " + cycle, cycle: true})
+		return
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		e.fail(file, line, "no se pudo leer %s: %v", path, err)
+		return
+	}
+	e.chain = append(e.chain, absolute(path))
+	e.expand(string(content), path, filepath.Dir(path), onError)
+	e.chain = e.chain[:len(e.chain)-1]
+}
+
+// checkExecute busca ciclos en el script que llamará execute. El script se lee
+// igual que en fn_execute, con la ruta relativa a la carpeta actual, y sus propios
+// errores se reportan cuando se ejecute.
+func (e *expander) checkExecute(file string, line int, params string) {
+	tokens, err := Parser.Tokenize(params)
+	if err != nil {
+		return
+	}
+	path := ""
+	for _, token := range tokens {
+		if token.Name == "path" {
+			path = strings.ReplaceAll(token.Value, "\\", "/")
+		}
+	}
+	if path == "" {
+		return
+	}
+	if cycle := e.cycle(path); cycle != "" {
+		e.errs = append(e.errs, &Error{File: file, Line: line, Message: "execute forma un ciclo: " + cycle, cycle: true})
+		return
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	nested := &expander{vars: make(map[string]string), chain: append(append([]string{}, e.chain...), absolute(path))}
+	nested.expand(string(content), path, filepath.Dir(path), Continue)
+	for _, err := range nested.errs {
+		if err.cycle {
+			e.errs = append(e.errs, err)
+		}
+	}
+}
+
+// cycle retorna la cadena de archivos si path ya está abierto, o "" si no hay ciclo
+func (e *expander) cycle(path string) string {
+	abs := absolute(path)
+	for i, open := range e.chain {
+		if open == abs {
+			names := make([]string, 0, len(e.chain)-i+1)
+			for _, p := range e.chain[i:] {
+				names = append(names, filepath.Base(p))
+			}
+			return strings.Join(append(names, filepath.Base(abs)), " → ")
+		}
+	}
+	return ""
+}
+
+func absolute(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	return abs
+}
+
+// Commands cuenta los comandos desde el índice start, sin contar las directivas
+func Commands(steps []Step, start int) int {
+	count := 0
+	for i := start; i < len(steps); i++ {
+		if steps[i].Kind == Command {
+			count++
+		}
+	}
+	return count
+}
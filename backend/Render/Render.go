@@ -0,0 +1,281 @@
+package Render
+
+import (
+	"MIA_P1/Results"
+	"MIA_P1/Utilities"
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Los reportes gráficos se describen con tablas y grafos de tablas, y se dibujan
+// según la extensión de la ruta de salida:
+//   - .svg (o sin extensión) con el renderizador integrado, sin dependencias
+//   - .dot escribe el código Graphviz sin ejecutarlo
+//   - .png, .jpg, .jpeg, .gif y .pdf con Graphviz, si el comando dot está instalado
+//
+// Graphviz recibe el código por la entrada estándar, así que no quedan archivos
+// .dot junto al reporte.
+
+// Cell es una celda de una tabla. El texto puede tener varias líneas separadas por \n.
+type Cell struct {
+	Text  string
+	Bold  bool
+	Color string // color de fondo, por ejemplo "#E6F3FF"; vacío usa el de la tabla
+	Span  int    // columnas que ocupa; 0 equivale a 1 y All a todas las de la tabla
+	Left  bool   // alinea el texto a la izquierda en lugar de centrarlo
+}
+
+// All hace que una celda ocupe todas las columnas de la tabla
+const All = -1
+
+// Table es una tabla con filas de celdas
+type Table struct {
+	Color string // color de fondo de las celdas
+	Rows  [][]Cell
+}
+
+// Row agrega una fila a la tabla
+func (t *Table) Row(cells ...Cell) {
+	t.Rows = append(t.Rows, cells)
+}
+
+// Header agrega una fila con una sola celda en negrita que ocupa todas las columnas
+func (t *Table) Header(text string, color string) {
+	t.Row(Cell{Text: text, Bold: true, Color: color, Span: All})
+}
+
+// Field agrega una fila nombre-valor
+func (t *Table) Field(name string, value interface{}) {
+	t.Row(Cell{Text: name, Bold: true}, Cell{Text: fmt.Sprint(value)})
+}
+
+// columns retorna la cantidad de columnas de la tabla
+func (t *Table) columns() int {
+	columns := 1
+	for _, row := range t.Rows {
+		n := 0
+		for _, cell := range row {
+			n += max(cell.Span, 1)
+		}
+		columns = max(columns, n)
+	}
+	return columns
+}
+
+// span retorna las columnas que ocupa la celda en una tabla de columns columnas
+func (c Cell) span(columns int) int {
+	if c.Span == All {
+		return columns
+	}
+	return max(c.Span, 1)
+}
+
+// Direction es el sentido en que se dibujan las aristas de un grafo
+type Direction int
+
+const (
+	TopDown   Direction = iota // de arriba hacia abajo
+	LeftRight                  // de izquierda a derecha
+)
+
+// Node es un nodo de un grafo, dibujado como una tabla
+type Node struct {
+	ID    string
+	Table *Table
+}
+
+// Edge une dos nodos por su ID
+type Edge struct {
+	From, To string
+}
+
+// Graph es un grafo de tablas. Un reporte con una sola tabla es un grafo de un nodo.
+type Graph struct {
+	Title     string
+	Direction Direction
+	Nodes     []*Node
+	Edges     []Edge
+}
+
+// Add agrega un nodo con la tabla y lo retorna
+func (g *Graph) Add(id string, table *Table) *Node {
+	node := &Node{ID: id, Table: table}
+	g.Nodes = append(g.Nodes, node)
+	return node
+}
+
+// Connect agrega una arista entre dos nodos
+func (g *Graph) Connect(from, to string) {
+	g.Edges = append(g.Edges, Edge{From: from, To: to})
+}
+
+// Single crea un grafo con una sola tabla
+func Single(title string, table *Table) *Graph {
+	g := &Graph{Title: title}
+	g.Add("tabla", table)
+	return g
+}
+
+// Formatos que Graphviz puede generar
+var graphvizFormats = map[string]bool{"png": true, "jpg": true, "jpeg": true, "gif": true, "pdf": true}
+
+// Path retorna la ruta de salida de un reporte: sin extensión se genera un .svg
+func Path(path string) string {
+	if filepath.Ext(path) == "" {
+		return path + ".svg"
+	}
+	return path
+}
+
+// Check valida que se pueda generar un reporte en path según su extensión
+func Check(path string) error {
+	format := strings.ToLower(strings.TrimPrefix(filepath.Ext(Path(path)), "."))
+	switch {
+	case format == "svg", format == "dot":
+		return nil
+	case graphvizFormats[format]:
+		if _, err := exec.LookPath("dot"); err != nil {
+			return Results.Errorf(Results.InvalidParams, "el formato .%s necesita Graphviz (dot) y no está instalado; use una ruta .svg", format)
+		}
+		return nil
+	default:
+		return Results.Errorf(Results.InvalidParams, "formato de reporte no soportado: .%s (use .svg, .dot, .png, .jpg, .gif o .pdf)", format)
+	}
+}
+
+// Write dibuja el grafo en path con el formato de su extensión y retorna la ruta
+// del archivo generado
+func Write(g *Graph, path string) (string, error) {
+	return WriteContext(context.Background(), g, path)
+}
+
+// WriteContext es como Write, pero detiene Graphviz si ctx se cancela
+func WriteContext(ctx context.Context, g *Graph, path string) (string, error) {
+	path = Path(path)
+	if err := Check(path); err != nil {
+		return "", err
+	}
+	if err := Utilities.CreateParentDirs(path); err != nil {
+		return "", err
+	}
+
+	format := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	switch format {
+	case "svg":
+		return path, os.WriteFile(path, SVG(g), 0644)
+	case "dot":
+		return path, os.WriteFile(path, []byte(Dot(g)), 0644)
+	}
+
+	cmd := exec.CommandContext(ctx, "dot", "-T"+format, "-Gdpi=300", "-o", path)
+	cmd.Stdin = strings.NewReader(Dot(g))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("error al ejecutar Graphviz: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return path, nil
+}
+
+// Dot retorna el código Graphviz del grafo, con cada tabla como etiqueta HTML
+func Dot(g *Graph) string {
+	var b strings.Builder
+	b.WriteString("digraph G {\n")
+	if g.Direction == LeftRight {
+		b.WriteString("    rankdir=LR;\n")
+	} else {
+		b.WriteString("    rankdir=TB;\n")
+	}
+	b.WriteString("    graph [bgcolor=\"#ffffff\", labelloc=\"t\"];\n")
+	b.WriteString("    node [fontname=\"Arial\", shape=plaintext, fontsize=10];\n")
+	b.WriteString("    edge [color=\"#5A5A5A\", arrowhead=normal];\n")
+	if g.Title != "" {
+		fmt.Fprintf(&b, "    label=<<B>%s</B>>;\n", dotText(g.Title))
+	}
+
+	for i, node := range g.Nodes {
+		table := node.Table
+		fmt.Fprintf(&b, "    n%d [label=<\n", i)
+		b.WriteString("        <TABLE BORDER=\"1\" CELLBORDER=\"1\" CELLSPACING=\"0\" CELLPADDING=\"4\"")
+		if table.Color != "" {
+			fmt.Fprintf(&b, " BGCOLOR=\"%s\"", table.Color)
+		}
+		b.WriteString(">\n")
+		columns := table.columns()
+		for _, row := range table.Rows {
+			b.WriteString("            <TR>")
+			for _, cell := range row {
+				b.WriteString("<TD")
+				if span := cell.span(columns); span > 1 {
+					fmt.Fprintf(&b, " COLSPAN=\"%d\"", span)
+				}
+				if cell.Color != "" {
+					fmt.Fprintf(&b, " BGCOLOR=\"%s\"", cell.Color)
+				}
+				if cell.Left {
+					b.WriteString(" ALIGN=\"LEFT\" BALIGN=\"LEFT\"")
+				}
+				b.WriteString(">")
+				text := dotText(cell.Text)
+				if text == "" {
+					text = " "
+				}
+				if cell.Bold {
+					text = "<B>" + text + "</B>"
+				}
+				b.WriteString(text)
+				b.WriteString("</TD>")
+			}
+			b.WriteString("</TR>\n")
+		}
+		b.WriteString("        </TABLE>\n    >];\n")
+	}
+
+	index := nodeIndex(g)
+	for _, edge := range g.Edges {
+		from, okFrom := index[edge.From]
+		to, okTo := index[edge.To]
+		if okFrom && okTo {
+			fmt.Fprintf(&b, "    n%d -> n%d;\n", from, to)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// dotText escapa el texto para una etiqueta HTML de Graphviz
+func dotText(text string) string {
+	return strings.ReplaceAll(html.EscapeString(clean(text)), "\n", "<BR/>")
+}
+
+// clean quita los caracteres de control (salvo los saltos de línea) y los bytes que
+// no son UTF-8, que aparecen al leer nombres de los discos y no son válidos en XML
+func clean(text string) string {
+	text = strings.ToValidUTF8(text, "")
+	return strings.Map(func(r rune) rune {
+		if r < 32 && r != '\n' {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// nodeIndex retorna la posición de cada nodo por su ID
+func nodeIndex(g *Graph) map[string]int {
+	index := make(map[string]int, len(g.Nodes))
+	for i, node := range g.Nodes {
+		if _, exists := index[node.ID]; !exists {
+			index[node.ID] = i
+		}
+	}
+	return index
+}
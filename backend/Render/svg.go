@@ -0,0 +1,340 @@
+package Render
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+// El renderizador SVG estima el ancho del texto con un ancho fijo por carácter, así
+// que no necesita fuentes instaladas. Los grafos se dibujan por niveles: cada nodo
+// queda en el nivel siguiente al de su padre y centrado sobre sus hijos.
+
+const (
+	fontSize    = 12
+	charWidth   = 7.2 // ancho promedio de un carácter de Arial a 12px
+	lineHeight  = 16
+	cellPadX    = 6
+	cellPadY    = 4
+	margin      = 20
+	titleHeight = 30
+	rankGap     = 50 // distancia entre niveles
+	siblingGap  = 20 // distancia entre nodos del mismo nivel
+)
+
+// box es la posición y el tamaño de un nodo en el dibujo
+type box struct {
+	x, y, w, h float64
+}
+
+// tableLayout son los anchos de columna y altos de fila de una tabla
+type tableLayout struct {
+	columns int
+	widths  []float64
+	heights []float64
+}
+
+func (l tableLayout) size() (float64, float64) {
+	w, h := 0.0, 0.0
+	for _, width := range l.widths {
+		w += width
+	}
+	for _, height := range l.heights {
+		h += height
+	}
+	return w, h
+}
+
+// textWidth estima el ancho de la línea más larga del texto
+func textWidth(text string, bold bool) float64 {
+	width := 0.0
+	for _, line := range strings.Split(text, "\n") {
+		w := float64(utf8.RuneCountInString(line)) * charWidth
+		if bold {
+			w *= 1.1
+		}
+		width = max(width, w)
+	}
+	return width
+}
+
+func lineCount(text string) int {
+	return strings.Count(text, "\n") + 1
+}
+
+// layoutTable calcula los anchos de columna y altos de fila. Las celdas que ocupan
+// varias columnas reparten lo que les falte entre ellas.
+func layoutTable(t *Table) tableLayout {
+	l := tableLayout{columns: t.columns()}
+	l.widths = make([]float64, l.columns)
+	l.heights = make([]float64, len(t.Rows))
+
+	for pass := 0; pass < 2; pass++ {
+		for r, row := range t.Rows {
+			col := 0
+			for _, cell := range row {
+				span := min(cell.span(l.columns), l.columns-col)
+				if span <= 0 {
+					break
+				}
+				need := textWidth(clean(cell.Text), cell.Bold) + 2*cellPadX
+				if pass == 0 && span == 1 {
+					l.widths[col] = max(l.widths[col], need)
+				}
+				if pass == 1 && span > 1 {
+					have := 0.0
+					for _, w := range l.widths[col : col+span] {
+						have += w
+					}
+					if have < need {
+						for i := col; i < col+span; i++ {
+							l.widths[i] += (need - have) / float64(span)
+						}
+					}
+				}
+				l.heights[r] = max(l.heights[r], float64(lineCount(cell.Text)*lineHeight+2*cellPadY))
+				col += span
+			}
+		}
+	}
+	return l
+}
+
+// SVG dibuja el grafo como un documento SVG
+func SVG(g *Graph) []byte {
+	layouts := make([]tableLayout, len(g.Nodes))
+	boxes := make([]box, len(g.Nodes))
+	for i, node := range g.Nodes {
+		layouts[i] = layoutTable(node.Table)
+		boxes[i].w, boxes[i].h = layouts[i].size()
+	}
+
+	top := float64(margin)
+	if g.Title != "" {
+		top += titleHeight
+	}
+	placeNodes(g, boxes, margin, top)
+
+	width := 2*margin + textWidth(g.Title, true)
+	height := top + margin
+	for _, b := range boxes {
+		width = max(width, b.x+b.w+margin)
+		height = max(height, b.y+b.h+margin)
+	}
+
+	var s strings.Builder
+	fmt.Fprintf(&s, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f" font-family="Arial, Helvetica, sans-serif" font-size="%d">`+"\n", width, height, width, height, fontSize)
+	s.WriteString(`<defs><marker id="flecha" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto"><path d="M0,0 L10,5 L0,10 z" fill="#5A5A5A"/></marker></defs>` + "\n")
+	fmt.Fprintf(&s, `<rect width="%.0f" height="%.0f" fill="#ffffff"/>`+"\n", width, height)
+	if g.Title != "" {
+		fmt.Fprintf(&s, `<text x="%.1f" y="%d" text-anchor="middle" font-size="14" font-weight="bold">%s</text>`+"\n", width/2, margin+14, html.EscapeString(clean(g.Title)))
+	}
+
+	index := nodeIndex(g)
+	for _, edge := range g.Edges {
+		from, okFrom := index[edge.From]
+		to, okTo := index[edge.To]
+		if okFrom && okTo && from != to {
+			writeEdge(&s, g.Direction, boxes[from], boxes[to])
+		}
+	}
+	for i, node := range g.Nodes {
+		writeTable(&s, node.Table, layouts[i], boxes[i])
+	}
+	s.WriteString("</svg>\n")
+	return []byte(s.String())
+}
+
+// placeNodes asigna la posición de cada nodo. Los nodos sin aristas de entrada son
+// raíces; cada nodo cuelga del primer padre que lo alcanza, y los que solo son
+// alcanzables por un ciclo se tratan como raíces.
+func placeNodes(g *Graph, boxes []box, left, top float64) {
+	index := nodeIndex(g)
+	children := make([][]int, len(g.Nodes))
+	hasParent := make([]bool, len(g.Nodes))
+	for _, edge := range g.Edges {
+		from, okFrom := index[edge.From]
+		to, okTo := index[edge.To]
+		if okFrom && okTo && from != to {
+			children[from] = append(children[from], to)
+			hasParent[to] = true
+		}
+	}
+
+	// Árbol de cobertura en profundidad
+	depth := make([]int, len(g.Nodes))
+	tree := make([][]int, len(g.Nodes))
+	visited := make([]bool, len(g.Nodes))
+	var roots []int
+	var visit func(n, d int)
+	visit = func(n, d int) {
+		visited[n] = true
+		depth[n] = d
+		for _, c := range children[n] {
+			if !visited[c] {
+				tree[n] = append(tree[n], c)
+				visit(c, d+1)
+			}
+		}
+	}
+	for n := range g.Nodes {
+		if !hasParent[n] && !visited[n] {
+			roots = append(roots, n)
+			visit(n, 0)
+		}
+	}
+	for n := range g.Nodes {
+		if !visited[n] {
+			roots = append(roots, n)
+			visit(n, 0)
+		}
+	}
+
+	// primary es el tamaño en el sentido de las aristas y secondary el perpendicular
+	primary := func(b box) float64 {
+		if g.Direction == LeftRight {
+			return b.w
+		}
+		return b.h
+	}
+	secondary := func(b box) float64 {
+		if g.Direction == LeftRight {
+			return b.h
+		}
+		return b.w
+	}
+
+	var rankSize []float64
+	for n, d := range depth {
+		for len(rankSize) <= d {
+			rankSize = append(rankSize, 0)
+		}
+		rankSize[d] = max(rankSize[d], primary(boxes[n]))
+	}
+	rankPos := make([]float64, len(rankSize))
+	for d := 1; d < len(rankSize); d++ {
+		rankPos[d] = rankPos[d-1] + rankSize[d-1] + rankGap
+	}
+
+	// extent es el espacio que ocupa un subárbol en el sentido perpendicular
+	extent := make([]float64, len(g.Nodes))
+	var measure func(n int) float64
+	measure = func(n int) float64 {
+		sum := 0.0
+		for i, c := range tree[n] {
+			if i > 0 {
+				sum += siblingGap
+			}
+			sum += measure(c)
+		}
+		extent[n] = max(secondary(boxes[n]), sum)
+		return extent[n]
+	}
+	var place func(n int, start float64)
+	place = func(n int, start float64) {
+		center := start + extent[n]/2
+		p := rankPos[depth[n]]
+		s := center - secondary(boxes[n])/2
+		if g.Direction == LeftRight {
+			boxes[n].x, boxes[n].y = left+p, top+s
+		} else {
+			boxes[n].x, boxes[n].y = left+s, top+p
+		}
+
+		sum := 0.0
+		for i, c := range tree[n] {
+			if i > 0 {
+				sum += siblingGap
+			}
+			sum += extent[c]
+		}
+		next := center - sum/2
+		for _, c := range tree[n] {
+			place(c, next)
+			next += extent[c] + siblingGap
+		}
+	}
+
+	start := 0.0
+	for _, r := range roots {
+		measure(r)
+		place(r, start)
+		start += extent[r] + siblingGap
+	}
+}
+
+// writeEdge dibuja una arista curva del borde de un nodo al borde del otro
+func writeEdge(s *strings.Builder, direction Direction, from, to box) {
+	var x1, y1, x2, y2, c1x, c1y, c2x, c2y float64
+	if direction == LeftRight {
+		x1, y1 = from.x+from.w, from.y+from.h/2
+		x2, y2 = to.x, to.y+to.h/2
+		mid := (x1 + x2) / 2
+		c1x, c1y, c2x, c2y = mid, y1, mid, y2
+	} else {
+		x1, y1 = from.x+from.w/2, from.y+from.h
+		x2, y2 = to.x+to.w/2, to.y
+		mid := (y1 + y2) / 2
+		c1x, c1y, c2x, c2y = x1, mid, x2, mid
+	}
+	fmt.Fprintf(s, `<path d="M%.1f,%.1f C%.1f,%.1f %.1f,%.1f %.1f,%.1f" fill="none" stroke="#5A5A5A" stroke-width="1.2" marker-end="url(#flecha)"/>`+"\n",
+		x1, y1, c1x, c1y, c2x, c2y, x2, y2)
+}
+
+// writeTable dibuja las celdas de una tabla en la posición de b
+func writeTable(s *strings.Builder, t *Table, l tableLayout, b box) {
+	background := t.Color
+	if background == "" {
+		background = "#ffffff"
+	}
+	y := b.y
+	for r, row := range t.Rows {
+		x := b.x
+		col := 0
+		for _, cell := range row {
+			span := min(cell.span(l.columns), l.columns-col)
+			if span <= 0 {
+				break
+			}
+			w := 0.0
+			for _, width := range l.widths[col : col+span] {
+				w += width
+			}
+			h := l.heights[r]
+			color := cell.Color
+			if color == "" {
+				color = background
+			}
+			fmt.Fprintf(s, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s" stroke="#333333"/>`+"\n", x, y, w, h, html.EscapeString(color))
+			writeText(s, cell, x, y, w, h)
+			x += w
+			col += span
+		}
+		y += l.heights[r]
+	}
+	fmt.Fprintf(s, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="none" stroke="#333333" stroke-width="1.5"/>`+"\n", b.x, b.y, b.w, b.h)
+}
+
+// writeText dibuja el texto de una celda, centrado en vertical
+func writeText(s *strings.Builder, cell Cell, x, y, w, h float64) {
+	text := clean(cell.Text)
+	if text == "" {
+		return
+	}
+	lines := strings.Split(text, "\n")
+	anchor, tx := "middle", x+w/2
+	if cell.Left {
+		anchor, tx = "start", x+cellPadX
+	}
+	weight := ""
+	if cell.Bold {
+		weight = ` font-weight="bold"`
+	}
+	first := y + (h-float64(len(lines)*lineHeight))/2 + fontSize
+	fmt.Fprintf(s, `<text text-anchor="%s"%s>`, anchor, weight)
+	for i, line := range lines {
+		fmt.Fprintf(s, `<tspan x="%.1f" y="%.1f">%s</tspan>`, tx, first+float64(i*lineHeight), html.EscapeString(line))
+	}
+	s.WriteString("</text>\n")
+}
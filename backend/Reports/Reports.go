@@ -0,0 +1,262 @@
+package Reports
+
+import (
+	"MIA_P1/Results"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Los reportes se guardan dentro de una sola carpeta (REPORT_DIR, por defecto
+// ./reportes). La ruta -path de rep siempre se interpreta dentro de esa carpeta,
+// aunque sea absoluta o tenga "..", así que un cliente no puede escribir en otras
+// rutas del servidor. Cada reporte generado recibe un ID con el que la API lo
+// lista y lo descarga; el índice se guarda en la misma carpeta para conservarlo
+// entre reinicios.
+
+// Report es un reporte generado
+type Report struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`      // tipo de reporte: mbr, disk, tree, ...
+	Partition   string    `json:"partition"` // ID de la partición montada
+	Path        string    `json:"path"`      // ruta dentro de la carpeta de reportes
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// indexFile es el archivo del índice dentro de la carpeta de reportes
+const indexFile = ".index.json"
+
+// Tipos de contenido por extensión; el resto se descarga como binario
+var contentTypes = map[string]string{
+	".svg":  "image/svg+xml",
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".pdf":  "application/pdf",
+	".dot":  "text/vnd.graphviz; charset=utf-8",
+	".txt":  "text/plain; charset=utf-8",
+	".csv":  "text/csv; charset=utf-8",
+	".md":   "text/markdown; charset=utf-8",
+}
+
+var (
+	mu      sync.Mutex
+	loaded  bool
+	reports = map[string]*Report{}
+)
+
+// Dir retorna la carpeta donde se guardan los reportes
+func Dir() string {
+	if dir := os.Getenv("REPORT_DIR"); dir != "" {
+		return dir
+	}
+	return "./reportes"
+}
+
+// Resolve retorna la ruta real de un reporte dentro de la carpeta de reportes y
+// crea sus carpetas padre
+func Resolve(path string) (string, error) {
+	rel := strings.TrimPrefix(filepath.Clean("/"+filepath.ToSlash(path)), "/")
+	if rel == "" || rel == indexFile {
+		return "", Results.Errorf(Results.InvalidParams, "ruta de reporte inválida: %q", path)
+	}
+	full := filepath.Join(Dir(), filepath.FromSlash(rel))
+	if err := os.MkdirAll(Dir(), os.ModePerm); err != nil {
+		return "", Results.Errorf(Results.IOError, "error al crear la carpeta de reportes: %v", err)
+	}
+
+	// Un enlace simbólico dentro de la carpeta podría apuntar afuera. Se revisa la
+	// carpeta existente más cercana antes de crear las que faltan, para no crear
+	// carpetas fuera de la de reportes.
+	root, err := filepath.EvalSymlinks(Dir())
+	if err != nil {
+		return "", Results.Errorf(Results.IOError, "error al leer la carpeta de reportes: %v", err)
+	}
+	outside := Results.Errorf(Results.PermissionDenied, "la ruta %s está fuera de la carpeta de reportes", path)
+	existing := filepath.Dir(full)
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		existing = filepath.Dir(existing)
+	}
+	if !inside(root, existing) {
+		return "", outside
+	}
+	if err := os.MkdirAll(filepath.Dir(full), os.ModePerm); err != nil {
+		return "", Results.Errorf(Results.IOError, "error al crear las carpetas del reporte: %v", err)
+	}
+
+	if !inside(root, filepath.Dir(full)) {
+		return "", outside
+	}
+	if info, err := os.Lstat(full); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return "", outside
+	}
+	return full, nil
+}
+
+// inside indica si dir, con sus enlaces simbólicos resueltos, está dentro de root
+func inside(root string, dir string) bool {
+	dir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	return dir == root || strings.HasPrefix(dir, root+string(filepath.Separator))
+}
+
+// Add registra el reporte generado en file, una ruta retornada por Resolve. Si ya
+// había un reporte en la misma ruta se reemplaza y conserva su ID.
+func Add(name, partition, file string) (Report, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return Report{}, Results.Errorf(Results.IOError, "no se encontró el reporte generado: %v", err)
+	}
+	rel, err := filepath.Rel(Dir(), file)
+	if err != nil {
+		return Report{}, Results.Errorf(Results.IOError, "%v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	load()
+	report := &Report{
+		ID:          newID(),
+		Name:        name,
+		Partition:   partition,
+		Path:        filepath.ToSlash(rel),
+		ContentType: contentType(file),
+		Size:        info.Size(),
+		CreatedAt:   time.Now(),
+	}
+	for id, r := range reports {
+		if r.Path == report.Path {
+			report.ID = id
+		}
+	}
+	reports[report.ID] = report
+	return *report, save()
+}
+
+// List retorna los reportes del más reciente al más antiguo. Los que se borraron
+// de la carpeta se quitan del índice.
+func List() []Report {
+	mu.Lock()
+	defer mu.Unlock()
+	load()
+	list := []Report{}
+	for id, r := range reports {
+		if _, err := os.Stat(filepath.Join(Dir(), filepath.FromSlash(r.Path))); err != nil {
+			delete(reports, id)
+			continue
+		}
+		list = append(list, *r)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+	return list
+}
+
+// Get retorna un reporte del índice si su archivo todavía existe
+func Get(id string) (Report, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	load()
+	r, ok := reports[id]
+	if !ok {
+		return Report{}, false
+	}
+	if _, err := os.Stat(filepath.Join(Dir(), filepath.FromSlash(r.Path))); err != nil {
+		return Report{}, false
+	}
+	return *r, true
+}
+
+// Read retorna un reporte y su contenido
+func Read(id string) (Report, []byte, error) {
+	mu.Lock()
+	load()
+	r, ok := reports[id]
+	var report Report
+	if ok {
+		report = *r
+	}
+	mu.Unlock()
+	if !ok {
+		return Report{}, nil, Results.Errorf(Results.NotFound, "no existe el reporte %s", id)
+	}
+	content, err := os.ReadFile(filepath.Join(Dir(), filepath.FromSlash(report.Path)))
+	if os.IsNotExist(err) {
+		return Report{}, nil, Results.Errorf(Results.NotFound, "el archivo del reporte %s ya no existe", id)
+	}
+	if err != nil {
+		return Report{}, nil, Results.Errorf(Results.IOError, "error al leer el reporte %s: %v", id, err)
+	}
+	return report, content, nil
+}
+
+// load lee el índice la primera vez que se usa. Se llama con mu tomado.
+func load() {
+	if loaded {
+		return
+	}
+	loaded = true
+	data, err := os.ReadFile(filepath.Join(Dir(), indexFile))
+	if err != nil {
+		return
+	}
+	var list []*Report
+	if json.Unmarshal(data, &list) != nil {
+		return
+	}
+	for _, r := range list {
+		reports[r.ID] = r
+	}
+}
+
+// save escribe el índice. Se llama con mu tomado.
+func save() error {
+	list := make([]*Report, 0, len(reports))
+	for _, r := range reports {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Se escribe en un temporal y se renombra para no dejar un índice a medias
+	tmp := filepath.Join(Dir(), indexFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return Results.Errorf(Results.IOError, "error al guardar el índice de reportes: %v", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(Dir(), indexFile)); err != nil {
+		return Results.Errorf(Results.IOError, "error al guardar el índice de reportes: %v", err)
+	}
+	return nil
+}
+
+func contentType(file string) string {
+	if t, ok := contentTypes[strings.ToLower(filepath.Ext(file))]; ok {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,82 @@
+package Results
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Code identifica el tipo de error de un comando
+type Code string
+
+const (
+	InvalidParams        Code = "INVALID_PARAMS"
+	NotFound             Code = "NOT_FOUND"
+	AlreadyExists        Code = "ALREADY_EXISTS"
+	NotLoggedIn          Code = "NOT_LOGGED_IN"
+	PermissionDenied     Code = "PERMISSION_DENIED"
+	NoSpace              Code = "NO_SPACE"
+	IOError              Code = "IO_ERROR"
+	UnknownCommand       Code = "UNKNOWN_COMMAND"
+	ConfirmationRequired Code = "CONFIRMATION_REQUIRED"
+	Failed               Code = "COMMAND_FAILED"
+)
+
+// Estados posibles de un resultado
+const (
+	StatusOK      = "ok"
+	StatusError   = "error"
+	StatusConfirm = "confirm"
+)
+
+// Error es un error de comando con su código
+type Error struct {
+	Code    Code
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Errorf crea un error de comando con el código indicado
+func Errorf(code Code, format string, a ...any) error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+// CodeOf retorna el código de un error. Los errores sin código se reportan como Failed.
+func CodeOf(err error) Code {
+	var cmdErr *Error
+	if errors.As(err, &cmdErr) {
+		return cmdErr.Code
+	}
+	return Failed
+}
+
+// Result es el resultado de ejecutar un comando
+type Result struct {
+	Command string      `json:"command,omitempty"`
+	Status  string      `json:"status"`
+	Code    Code        `json:"code,omitempty"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// OK crea un resultado exitoso con datos opcionales
+func OK(message string, data interface{}) Result {
+	return Result{Status: StatusOK, Message: message, Data: data}
+}
+
+// Fail crea un resultado fallido a partir de un error
+func Fail(err error) Result {
+	return Result{Status: StatusError, Code: CodeOf(err), Message: err.Error()}
+}
+
+// Confirm crea un resultado que pide confirmación al usuario antes de continuar
+func Confirm(message string) Result {
+	return Result{Status: StatusConfirm, Code: ConfirmationRequired, Message: message}
+}
+
+// Failed indica si el resultado corresponde a un error
+func (r Result) Failed() bool {
+	return r.Status == StatusError
+}
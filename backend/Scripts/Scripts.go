@@ -0,0 +1,243 @@
+package Scripts
+
+import (
+	"MIA_P1/Analyzer"
+	"MIA_P1/OutPut"
+	"MIA_P1/Preprocessor"
+	"MIA_P1/Results"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Los scripts pausados (por un pause o por un comando que pide confirmación) se
+// guardan en el servidor como sesiones. El cliente solo conoce el ID de la sesión
+// y no puede cambiar las líneas que faltan por ejecutar.
+
+// TTL es el tiempo que una sesión pausada se conserva sin actividad
+const TTL = 30 * time.Minute
+
+// State indica por qué se detuvo un script
+type State string
+
+const (
+	StatePaused  State = "paused"
+	StateConfirm State = "confirm"
+)
+
+// Session es un script detenido que se puede reanudar
+type Session struct {
+	ID      string
+	Steps   []Preprocessor.Step
+	Next    int    // índice del siguiente paso a ejecutar
+	File    string // archivo incluido donde se detuvo; vacío para el script principal
+	Line    int    // número de línea (desde 1) donde se detuvo
+	State   State
+	Message string
+	Expires time.Time
+}
+
+// Result es el resultado de ejecutar un tramo de un script
+type Result struct {
+	Analyzer.ScriptResult
+	SessionID string    // vacío si el script terminó
+	Remaining int       // comandos pendientes
+	Expires   time.Time // vencimiento de la sesión
+}
+
+// Hooks permite seguir un script mientras se ejecuta. Los campos nil se ignoran.
+type Hooks struct {
+	OnStart func(id string)           // se llama con el ID antes de ejecutar la primera línea
+	OnLine  func(Analyzer.LineResult) // se llama después de cada comando
+}
+
+var (
+	mu       sync.Mutex
+	sessions = make(map[string]*Session)
+	running  = make(map[string]context.CancelFunc) // scripts en ejecución por ID
+)
+
+// Start ejecuta un script nuevo y guarda una sesión si se detiene. Retorna un
+// error sin ejecutar nada si el script no se puede preparar.
+func Start(ctx context.Context, out *OutPut.Output, script string, hooks Hooks) (Result, error) {
+	steps, err := Analyzer.PrepareScript(script)
+	if err != nil {
+		return Result{}, err
+	}
+	return run(ctx, out, newID(), steps, 0, false, hooks), nil
+}
+
+// Continue reanuda una sesión. Si la sesión espera confirmación, el comando
+// pendiente se omite y el script sigue con la línea siguiente.
+func Continue(ctx context.Context, out *OutPut.Output, id string, hooks Hooks) (Result, error) {
+	s, err := take(id)
+	if err != nil {
+		return Result{}, err
+	}
+	next := s.Next
+	if s.State == StateConfirm {
+		out.Printf("Se omitió el comando de la línea %d\n", s.Line)
+		next++
+	}
+	return run(ctx, out, s.ID, s.Steps, next, false, hooks), nil
+}
+
+// Confirm ejecuta el comando que espera confirmación y continúa el script
+func Confirm(ctx context.Context, out *OutPut.Output, id string, hooks Hooks) (Result, error) {
+	s, err := take(id)
+	if err != nil {
+		return Result{}, err
+	}
+	if s.State != StateConfirm {
+		save(s)
+		return Result{}, Results.Errorf(Results.InvalidParams, "la sesión %s no espera confirmación", id)
+	}
+	return run(ctx, out, s.ID, s.Steps, s.Next, true, hooks), nil
+}
+
+// Cancel detiene un script en ejecución antes de su siguiente línea, o descarta
+// una sesión pausada sin ejecutar las líneas pendientes
+func Cancel(id string) error {
+	mu.Lock()
+	cancel, ok := running[id]
+	mu.Unlock()
+	if ok {
+		cancel()
+		return nil
+	}
+	_, err := take(id)
+	return err
+}
+
+// DryRun simula un script completo sin modificar los discos (ver Analyzer.DryRunScript).
+// No deja sesión porque nunca se detiene, pero se puede cancelar con Cancel.
+func DryRun(ctx context.Context, out *OutPut.Output, script string, hooks Hooks) (Result, error) {
+	steps, err := Analyzer.PrepareScript(script)
+	if err != nil {
+		return Result{}, err
+	}
+	id := newID()
+	ctx, done := track(ctx, id)
+	defer done()
+
+	if hooks.OnStart != nil {
+		hooks.OnStart(id)
+	}
+	return Result{ScriptResult: Analyzer.DryRunScript(ctx, out, steps, hooks.OnLine)}, nil
+}
+
+// Transaction ejecuta un script completo como una transacción (ver
+// Analyzer.ExecuteTransaction). No deja sesión porque nunca se detiene a
+// preguntar, pero se puede cancelar con Cancel, lo que deshace sus cambios.
+func Transaction(ctx context.Context, out *OutPut.Output, script string, hooks Hooks) (Result, error) {
+	steps, err := Analyzer.PrepareScript(script)
+	if err != nil {
+		return Result{}, err
+	}
+	id := newID()
+	ctx, done := track(ctx, id)
+	defer done()
+
+	if hooks.OnStart != nil {
+		hooks.OnStart(id)
+	}
+	return Result{ScriptResult: Analyzer.ExecuteTransaction(ctx, out, steps, hooks.OnLine)}, nil
+}
+
+// Get retorna una copia de la sesión con el ID indicado
+func Get(id string) (Session, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	purge()
+	s, ok := sessions[id]
+	if !ok {
+		return Session{}, false
+	}
+	return *s, true
+}
+
+// track registra el script como en ejecución para que Cancel lo pueda detener.
+// La función retornada lo quita del registro.
+func track(ctx context.Context, id string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	mu.Lock()
+	running[id] = cancel
+	mu.Unlock()
+	return ctx, func() {
+		mu.Lock()
+		delete(running, id)
+		mu.Unlock()
+		cancel()
+	}
+}
+
+func run(ctx context.Context, out *OutPut.Output, id string, steps []Preprocessor.Step, start int, confirmed bool, hooks Hooks) Result {
+	ctx, done := track(ctx, id)
+	defer done()
+
+	if hooks.OnStart != nil {
+		hooks.OnStart(id)
+	}
+	res := Result{ScriptResult: Analyzer.ExecuteLines(ctx, out, steps, start, confirmed, hooks.OnLine)}
+	if !res.Paused && !res.Confirm {
+		return res
+	}
+
+	s := &Session{
+		ID:      id,
+		Steps:   steps,
+		Next:    res.Next,
+		File:    res.File,
+		Line:    res.Line,
+		State:   StatePaused,
+		Message: res.ConfirmMessage,
+	}
+	if res.Confirm {
+		s.State = StateConfirm
+	}
+	save(s)
+
+	res.SessionID = s.ID
+	res.Remaining = Preprocessor.Commands(steps, res.Next)
+	res.Expires = s.Expires
+	return res
+}
+
+// take saca la sesión del mapa para que solo una petición pueda reanudarla
+func take(id string) (*Session, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	purge()
+	s, ok := sessions[id]
+	if !ok {
+		return nil, Results.Errorf(Results.NotFound, "la sesión de script %s no existe o expiró", id)
+	}
+	delete(sessions, id)
+	return s, nil
+}
+
+func save(s *Session) {
+	mu.Lock()
+	defer mu.Unlock()
+	purge()
+	s.Expires = time.Now().Add(TTL)
+	sessions[s.ID] = s
+}
+
+// purge elimina las sesiones vencidas. Se llama con mu tomado.
+func purge() {
+	now := time.Now()
+	for id, s := range sessions {
+		if now.After(s.Expires) {
+			delete(sessions, id)
+		}
+	}
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -2,7 +2,6 @@ package Structs
 
 import (
 	"MIA_P1/OutPut"
-	"fmt"
 )
 
 //  =============================================================
@@ -15,11 +14,11 @@ type MRB struct {
 	Partitions   [4]Partition
 }
 
-func PrintMBR(data MRB) {
-	OutPut.Println(fmt.Sprintf("CreationDate: %s, fit: %s, size: %d",
-		string(data.CreationDate[:]), string(data.Fit[:]), data.MbrSize))
+func PrintMBR(out *OutPut.Output, data MRB) {
+	out.Printf("CreationDate: %s, fit: %s, size: %d",
+		string(data.CreationDate[:]), string(data.Fit[:]), data.MbrSize)
 	for i := 0; i < 4; i++ {
-		PrintPartition(data.Partitions[i])
+		PrintPartition(out, data.Partitions[i])
 	}
 }
 
@@ -36,9 +35,20 @@ type Partition struct {
 	Id          [4]byte
 }
 
-func PrintPartition(data Partition) {
-	OutPut.Println(fmt.Sprintf("Name: %s, type: %s, start: %d, size: %d, status: %s, id: %s", string(data.Name[:]),
-		string(data.Type[:]), data.Start, data.Size, string(data.Status[:]), string(data.Id[:])))
+func PrintPartition(out *OutPut.Output, data Partition) {
+	out.Printf("Name: %s, type: %s, start: %d, size: %d, status: %s, id: %s", string(data.Name[:]),
+		string(data.Type[:]), data.Start, data.Size, string(data.Status[:]), string(data.Id[:]))
+}
+
+//  =============================================================
+
+type EBR struct {
+	Mount [1]byte
+	Fit   [1]byte
+	Start int64 // byte donde empieza la partición lógica, después del EBR
+	Size  int64 // 0 si el EBR no tiene partición lógica
+	Next  int64 // posición del siguiente EBR; -1 o 0 si es el último
+	Name  [16]byte
 }
 
 //  =============================================================
@@ -1,98 +1,187 @@
 package Tree
 
 import (
-	"MIA_P1/Structs"
+	"MIA_P1/Cache"
 	"MIA_P1/DiskManagement"
+	"MIA_P1/Jobs"
+	"MIA_P1/Locks"
+	"MIA_P1/Render"
+	"MIA_P1/Results"
+	"MIA_P1/Structs"
 	"MIA_P1/Utilities"
+	"context"
 	"encoding/binary"
 	"fmt"
 	"os"
-	"os/exec"
 	"strings"
 )
 
-func TreeReport(id string, outputPath string) error {
+// Options limita la parte del árbol que se reporta
+type Options struct {
+	Root     string // carpeta o archivo desde donde se dibuja; vacío es "/"
+	Depth    int    // niveles debajo de Root que se incluyen; 0 no tiene límite
+	NoBlocks bool   // oculta los bloques de cada inodo y deja solo la estructura
+}
+
+// TreeNode es un inodo del árbol del sistema de archivos con los inodos de sus
+// entradas. Un inodo que ya apareció en otra parte del árbol se repite sin hijos
+// y con Repeated en true. Hidden cuenta las entradas de una carpeta que quedaron
+// fuera por el límite de profundidad.
+type TreeNode struct {
+	Name string `json:"name"`
+	DiskManagement.InodeData
+	Repeated bool        `json:"repeated,omitempty"`
+	Hidden   int         `json:"hidden,omitempty"`
+	Children []*TreeNode `json:"children,omitempty"`
+}
+
+// TreeReportData recorre el árbol de inodos de la partición desde opts.Root
+func TreeReportData(ctx context.Context, id string, opts Options) (*TreeNode, error) {
 	// 1. Obtener la ruta del disco a partir del ID
 	partitionPath := DiskManagement.GetPartitionPathByID(id)
 	if partitionPath == "" {
-		return fmt.Errorf("no se encontró la ruta para el id: %s", id)
+		return nil, fmt.Errorf("no se encontró la ruta para el id: %s", id)
 	}
+	defer Locks.RLockPartition(partitionPath, id)()
+	return ReadTree(ctx, id, partitionPath, opts)
+}
 
-	// 2. Abrir archivo del disco
+// ReadTree es TreeReportData para quien ya tiene la partición bloqueada en modo
+// lectura y necesita leer algo más del disco bajo el mismo candado
+func ReadTree(ctx context.Context, id string, partitionPath string, opts Options) (*TreeNode, error) {
+	if opts.Depth < 0 {
+		return nil, Results.Errorf(Results.InvalidParams, "-depth debe ser mayor o igual a 0")
+	}
+
+	// 2. Bajar a disco los cambios cacheados y abrir archivo del disco
+	if err := Cache.Flush(id); err != nil {
+		return nil, fmt.Errorf("error al guardar la partición: %v", err)
+	}
 	file, err := Utilities.OpenFile(partitionPath)
 	if err != nil {
-		return fmt.Errorf("no se pudo abrir el disco: %v", err)
+		return nil, fmt.Errorf("no se pudo abrir el disco: %v", err)
 	}
 	defer file.Close()
 
 	// 3. Obtener el inicio de la partición y leer el Superblock
 	partitionStart := DiskManagement.GetPartitionStartByID(id)
 	if partitionStart < 0 {
-		return fmt.Errorf("no se encontró la partición con el id %s", id)
+		return nil, fmt.Errorf("no se encontró la partición con el id %s", id)
 	}
 
 	var sb Structs.Superblock
 	if err := Utilities.ReadObject(file, &sb, int64(partitionStart)); err != nil {
-		return fmt.Errorf("error al leer el Superblock: %v", err)
+		return nil, fmt.Errorf("error al leer el Superblock: %v", err)
 	}
 
-	// 4. Construir archivo DOT
-	var buffer strings.Builder
-	buffer.WriteString("digraph ext2_tree {\n")
-	buffer.WriteString("    rankdir=LR;\n")
-	buffer.WriteString("    node [fontname=\"Arial\", shape=plain, style=\"filled\", fontsize=10];\n")
-	buffer.WriteString("    edge [color=\"#5A5A5A\", arrowhead=normal];\n")
-	buffer.WriteString("    graph [bgcolor=\"#ffffff\", pencolor=\"#333333\", penwidth=2.0, style=\"rounded\"];\n\n")
+	// 4. Buscar el inodo de la ruta pedida
+	rootIndex, label, err := findInode(opts.Root, file, sb)
+	if err != nil {
+		return nil, err
+	}
 
-	// 5. Recorrer el árbol desde el inodo raíz
-	visited := make(map[int]bool)
-	if err := traverseInodeTree(0, "root", file, sb, &buffer, visited); err != nil {
-		return fmt.Errorf("error al recorrer el árbol de inodos: %v", err)
+	// 5. Recorrer el árbol desde ese inodo; sin límite la profundidad restante es -1
+	remaining := -1
+	if opts.Depth > 0 {
+		remaining = opts.Depth
 	}
+	root, err := traverseInodeTree(ctx, rootIndex, label, file, sb, make(map[int]bool), remaining)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error al recorrer el árbol de inodos: %v", err)
+	}
+	if opts.NoBlocks {
+		hideBlocks(root)
+	}
+	return root, nil
+}
 
-	buffer.WriteString("}\n")
+// hideBlocks marca como sin usar los apuntadores de cada inodo del árbol
+func hideBlocks(node *TreeNode) {
+	for j := range node.Blocks {
+		node.Blocks[j] = -1
+	}
+	for _, child := range node.Children {
+		hideBlocks(child)
+	}
+}
 
-	// 6. Guardar archivo .dot
-	dotFile := outputPath + ".dot"
-	if err := os.WriteFile(dotFile, []byte(buffer.String()), 0644); err != nil {
-		return fmt.Errorf("error al guardar archivo DOT: %v", err)
+func TreeReport(ctx context.Context, id string, outputPath string, opts Options) error {
+	root, err := TreeReportData(ctx, id, opts)
+	if err != nil {
+		return err
 	}
 
-	// 7. Generar imagen con Graphviz
-	cmd := exec.Command("dot", "-Tpng", "-Gdpi=300", dotFile, "-o", outputPath+".png")
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("error al generar imagen PNG: %v", err)
+	// Generar el reporte en el formato de la ruta de salida
+	graph := &Render.Graph{Direction: Render.LeftRight}
+	addTreeNode(graph, root, opts)
+	if _, err := Render.WriteContext(ctx, graph, outputPath); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-
-func traverseInodeTree(inodeIndex int, label string, file *os.File, sb Structs.Superblock, buffer *strings.Builder, visited map[int]bool) error {
-	if visited[inodeIndex] {
-		// Ya fue procesado este inodo, evitar bucle
-		return nil
+// findInode retorna el índice del inodo de path y el nombre con que se muestra
+func findInode(path string, file *os.File, sb Structs.Superblock) (int, string, error) {
+	index, label := 0, "root"
+	for _, name := range strings.Split(path, "/") {
+		if name == "" {
+			continue
+		}
+		inode, _ := GetInodeFromIndex(index, file, sb)
+		if inode == nil || inode.I_type[0] != '0' || inode.I_block[0] == -1 {
+			return 0, "", Results.Errorf(Results.NotFound, "no se encontró la ruta %s", path)
+		}
+		folder, err := ReadFolderBlock(file, sb, inode.I_block[0])
+		if err != nil {
+			return 0, "", fmt.Errorf("error al leer folderblock del inodo %d: %v", index, err)
+		}
+		found := false
+		for _, entry := range folder.B_content {
+			if strings.Trim(string(entry.B_name[:]), "\x00") == name {
+				index, label, found = int(entry.B_inodo), name, true
+				break
+			}
+		}
+		if !found {
+			return 0, "", Results.Errorf(Results.NotFound, "no se encontró la ruta %s", path)
+		}
 	}
-	visited[inodeIndex] = true
+	return index, label, nil
+}
 
+// traverseInodeTree lee el inodo y sus entradas. remaining es la cantidad de
+// niveles que faltan por incluir debajo del inodo, o -1 sin límite.
+func traverseInodeTree(ctx context.Context, inodeIndex int, label string, file *os.File, sb Structs.Superblock, visited map[int]bool, remaining int) (*TreeNode, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	// 1. Leer el inodo desde disco
 	inode, _ := GetInodeFromIndex(inodeIndex, file, sb)
 	if inode == nil {
-		return fmt.Errorf("no se pudo leer el inodo %d", inodeIndex)
+		return nil, fmt.Errorf("no se pudo leer el inodo %d", inodeIndex)
 	}
+	node := &TreeNode{Name: label, InodeData: DiskManagement.NewInodeData(inodeIndex, *inode)}
+	if visited[inodeIndex] {
+		// Ya fue procesado este inodo, evitar bucle
+		node.Repeated = true
+		return node, nil
+	}
+	visited[inodeIndex] = true
+	// El avance se mide contra los inodos en uso según el superbloque
+	Jobs.Progress(ctx, len(visited), max(int(sb.S_inodes_count-sb.S_free_inodes_count), len(visited)))
 
-	// 2. Crear nodo en DOT con información del inodo
-	nodeName := fmt.Sprintf("inode%d", inodeIndex)
-	addInodeNode(buffer, nodeName, inodeIndex, *inode, label)
-
-	// 3. Verificar si es carpeta (I_type[0] == '0')
+	// 2. Verificar si es carpeta (I_type[0] == '0')
 	if inode.I_type[0] == '0' {
 		// Leer el FolderBlock principal (asumiendo que I_block[0] es el bloque de carpeta)
 		folderBlockIndex := inode.I_block[0]
 		if folderBlockIndex != -1 {
 			folder, err := ReadFolderBlock(file, sb, folderBlockIndex)
 			if err != nil {
-				return fmt.Errorf("error al leer folderblock del inodo %d: %v", inodeIndex, err)
+				return nil, fmt.Errorf("error al leer folderblock del inodo %d: %v", inodeIndex, err)
 			}
 			// Recorrer entradas (omitir "." y "..")
 			for _, entry := range folder.B_content {
@@ -100,59 +189,71 @@ func traverseInodeTree(inodeIndex int, label string, file *os.File, sb Structs.S
 				if name == "" || name == "." || name == ".." {
 					continue
 				}
-				childIndex := int(entry.B_inodo)
-				// Agregar arista (inode -> child)
-				childNodeName := fmt.Sprintf("inode%d", childIndex)
-				buffer.WriteString(fmt.Sprintf("    %s -> %s;\n", nodeName, childNodeName))
+				// Se alcanzó el límite de profundidad: solo se cuentan las entradas
+				if remaining == 0 {
+					node.Hidden++
+					continue
+				}
 				// Recursión
-				if err := traverseInodeTree(childIndex, name, file, sb, buffer, visited); err != nil {
-					return err
+				child, err := traverseInodeTree(ctx, int(entry.B_inodo), name, file, sb, visited, remaining-1)
+				if err != nil {
+					return nil, err
 				}
+				node.Children = append(node.Children, child)
 			}
 		}
 	}
 
-	return nil
+	return node, nil
+}
+
+// addTreeNode agrega al grafo el nodo y sus hijos; un inodo repetido solo agrega
+// la arista hacia el nodo que ya existe
+func addTreeNode(graph *Render.Graph, node *TreeNode, opts Options) {
+	nodeName := fmt.Sprintf("inode%d", node.Index)
+	graph.Add(nodeName, inodeTable(node, opts))
+	for _, child := range node.Children {
+		graph.Connect(nodeName, fmt.Sprintf("inode%d", child.Index))
+		if !child.Repeated {
+			addTreeNode(graph, child, opts)
+		}
+	}
 }
 
-func addInodeNode(buffer *strings.Builder, nodeName string, inodeIndex int, inode Structs.Inode, label string) {
+func inodeTable(node *TreeNode, opts Options) *Render.Table {
 	// Construir cadena de bloques asignados
-	blocksStr := ""
-	for j, b := range inode.I_block {
+	var blocks []string
+	for j, b := range node.Blocks {
 		if b != -1 {
-			blocksStr += fmt.Sprintf("B%d=%d\\n", j, b)
+			blocks = append(blocks, fmt.Sprintf("B%d=%d", j, b))
 		}
 	}
-	atime := strings.Trim(string(inode.I_atime[:]), "\x00")
-	ctime := strings.Trim(string(inode.I_ctime[:]), "\x00")
-	mtime := strings.Trim(string(inode.I_mtime[:]), "\x00")
-	perm := strings.Trim(string(inode.I_perm[:]), "\x00")
 
-	// Tipo de inodo: '0' -> directorio, '1' -> archivo
+	// Tipo de inodo
 	var inodeType string
-	if inode.I_type[0] == '0' {
+	if node.Type == "directorio" {
 		inodeType = "Directorio"
 	} else {
 		inodeType = "Archivo"
 	}
 
-	buffer.WriteString(fmt.Sprintf("    %s [\n", nodeName))
-	buffer.WriteString("        fillcolor=\"#F9F3C2\",\n")
-	buffer.WriteString("        label=<\n")
-	buffer.WriteString("            <TABLE BORDER=\"0\" CELLBORDER=\"1\" CELLSPACING=\"0\" CELLPADDING=\"4\" BGCOLOR=\"#FFFEEB\">\n")
-	buffer.WriteString(fmt.Sprintf("                <TR><TD COLSPAN=\"2\" BGCOLOR=\"#F7DF72\"><B>%s (Inodo %d)</B></TD></TR>\n", label, inodeIndex))
-	buffer.WriteString(fmt.Sprintf("                <TR><TD><B>Tipo</B></TD><TD>%s</TD></TR>\n", inodeType))
-	buffer.WriteString(fmt.Sprintf("                <TR><TD><B>UID</B></TD><TD>%d</TD></TR>\n", inode.I_uid))
-	buffer.WriteString(fmt.Sprintf("                <TR><TD><B>GID</B></TD><TD>%d</TD></TR>\n", inode.I_gid))
-	buffer.WriteString(fmt.Sprintf("                <TR><TD><B>Tamaño</B></TD><TD>%d bytes</TD></TR>\n", inode.I_size))
-	buffer.WriteString(fmt.Sprintf("                <TR><TD><B>Permisos</B></TD><TD>%s</TD></TR>\n", perm))
-	buffer.WriteString(fmt.Sprintf("                <TR><TD><B>ATime</B></TD><TD>%s</TD></TR>\n", atime))
-	buffer.WriteString(fmt.Sprintf("                <TR><TD><B>CTime</B></TD><TD>%s</TD></TR>\n", ctime))
-	buffer.WriteString(fmt.Sprintf("                <TR><TD><B>MTime</B></TD><TD>%s</TD></TR>\n", mtime))
-	buffer.WriteString(fmt.Sprintf("                <TR><TD><B>Bloques</B></TD><TD>%s</TD></TR>\n", blocksStr))
-	buffer.WriteString("            </TABLE>\n")
-	buffer.WriteString("        >\n")
-	buffer.WriteString("    ];\n")
+	table := &Render.Table{Color: "#FFFEEB"}
+	table.Header(fmt.Sprintf("%s (Inodo %d)", node.Name, node.Index), "#F7DF72")
+	table.Field("Tipo", inodeType)
+	table.Field("UID", node.UID)
+	table.Field("GID", node.GID)
+	table.Field("Tamaño", fmt.Sprintf("%d bytes", node.Size))
+	table.Field("Permisos", node.Perm)
+	table.Field("ATime", node.ATime)
+	table.Field("CTime", node.CTime)
+	table.Field("MTime", node.MTime)
+	if !opts.NoBlocks {
+		table.Field("Bloques", strings.Join(blocks, "\n"))
+	}
+	if node.Hidden > 0 {
+		table.Field("Sin mostrar", fmt.Sprintf("%d entradas", node.Hidden))
+	}
+	return table
 }
 
 func GetInodeFromIndex(index int, file *os.File, sb Structs.Superblock) (*Structs.Inode, int64) {
@@ -0,0 +1,369 @@
+package Usage
+
+import (
+	"MIA_P1/Cache"
+	"MIA_P1/DiskManagement"
+	"MIA_P1/Locks"
+	"MIA_P1/Render"
+	"MIA_P1/Results"
+	"MIA_P1/Structs"
+	"MIA_P1/Tree"
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// El reporte usage resume cómo se ocupa una partición para planificar su
+// capacidad. Los inodos y bloques usados se cuentan en los bitmaps (no en los
+// contadores del superbloque, que el reporte sb ya muestra) y los tamaños salen
+// del recorrido del árbol de inodos.
+//
+// La fragmentación de un archivo se mide con sus bloques de datos, los directos
+// de I_block[0:12] y los de la tabla de punteros de I_block[12]: cada vez que un
+// bloque no es el siguiente del anterior empieza un fragmento nuevo. El
+// puntaje va de 0 (todos los bloques seguidos) a 1 (ningún par de bloques seguido).
+
+// DefaultTop es la cantidad de archivos y carpetas que se listan si no se indica -top
+const DefaultTop = 10
+
+// UsageData son los datos del reporte usage
+type UsageData struct {
+	Partition      string      `json:"partition"`
+	BlockSize      int32       `json:"blockSize"`
+	InodesCount    int         `json:"inodesCount"`
+	UsedInodes     int         `json:"usedInodes"`
+	FreeInodes     int         `json:"freeInodes"`
+	BlocksCount    int         `json:"blocksCount"`
+	UsedBlocks     int         `json:"usedBlocks"`
+	FreeBlocks     int         `json:"freeBlocks"`
+	FreeRuns       int         `json:"freeRuns"`       // tramos de bloques libres seguidos
+	LargestFreeRun Run         `json:"largestFreeRun"` // el tramo libre más grande
+	Fragmentation  float64     `json:"fragmentation"`  // promedio de los archivos con más de un bloque
+	Files          []FileUsage `json:"files"`          // todos los archivos, por ruta
+	LargestFiles   []FileUsage `json:"largestFiles"`
+	LargestDirs    []DirUsage  `json:"largestDirs"`
+	Histogram      []Bucket    `json:"histogram"`
+}
+
+// Run es un tramo de bloques seguidos
+type Run struct {
+	Start  int `json:"start"`
+	Length int `json:"length"`
+}
+
+// FileUsage es el tamaño y la fragmentación de un archivo
+type FileUsage struct {
+	Path          string  `json:"path"`
+	Inode         int     `json:"inode"`
+	Size          int32   `json:"size"`
+	Blocks        int     `json:"blocks"`
+	Fragments     int     `json:"fragments"`
+	Fragmentation float64 `json:"fragmentation"`
+}
+
+// DirUsage es el tamaño de una carpeta: la suma de los archivos que contiene,
+// incluidos los de sus subcarpetas
+type DirUsage struct {
+	Path  string `json:"path"`
+	Inode int    `json:"inode"`
+	Size  int64  `json:"size"`
+	Files int    `json:"files"`
+}
+
+// Bucket cuenta los archivos con tamaño entre Min y Max bytes. Max -1 no tiene límite.
+type Bucket struct {
+	Label string `json:"label"`
+	Min   int32  `json:"min"`
+	Max   int32  `json:"max"`
+	Count int    `json:"count"`
+}
+
+// Rangos del histograma de tamaños
+var buckets = []Bucket{
+	{Label: "0 B", Min: 0, Max: 0},
+	{Label: "1 B - 64 B", Min: 1, Max: 64},
+	{Label: "65 B - 1 KB", Min: 65, Max: 1 << 10},
+	{Label: "1 KB - 16 KB", Min: 1<<10 + 1, Max: 16 << 10},
+	{Label: "16 KB - 256 KB", Min: 16<<10 + 1, Max: 256 << 10},
+	{Label: "más de 256 KB", Min: 256<<10 + 1, Max: -1},
+}
+
+// UsageReportData calcula el uso de la partición. top es la cantidad de archivos
+// y carpetas más grandes que se listan.
+func UsageReportData(ctx context.Context, id string, top int) (UsageData, error) {
+	if top <= 0 {
+		return UsageData{}, Results.Errorf(Results.InvalidParams, "-top debe ser mayor que 0")
+	}
+	sb, err := DiskManagement.SuperBlockReportData(id)
+	if err != nil {
+		return UsageData{}, err
+	}
+	inodes, err := DiskManagement.BmInodeReportData(id)
+	if err != nil {
+		return UsageData{}, err
+	}
+	blocks, err := DiskManagement.BmBlockReportData(id)
+	if err != nil {
+		return UsageData{}, err
+	}
+	root, err := Tree.TreeReportData(ctx, id, Tree.Options{})
+	if err != nil {
+		return UsageData{}, err
+	}
+
+	data := UsageData{
+		Partition:   id,
+		BlockSize:   sb.BlockSize,
+		InodesCount: len(inodes.Bits),
+		UsedInodes:  strings.Count(inodes.Bits, "1"),
+		BlocksCount: len(blocks.Bits),
+		UsedBlocks:  strings.Count(blocks.Bits, "1"),
+		Files:       []FileUsage{},
+		Histogram:   append([]Bucket(nil), buckets...),
+	}
+	data.FreeInodes = data.InodesCount - data.UsedInodes
+	data.FreeBlocks = data.BlocksCount - data.UsedBlocks
+	data.FreeRuns, data.LargestFreeRun = freeRuns(blocks.Bits)
+
+	// Los bloques indirectos de cada archivo se leen de su tabla de punteros
+	partitionPath := DiskManagement.GetPartitionPathByID(id)
+	partitionStart := DiskManagement.GetPartitionStartByID(id)
+	if partitionPath == "" || partitionStart < 0 {
+		return UsageData{}, fmt.Errorf("no se encontró la partición para el id: %s", id)
+	}
+	defer Locks.RLockPartition(partitionPath, id)()
+	part, err := Cache.Open(id, partitionPath, partitionStart)
+	if err != nil {
+		return UsageData{}, fmt.Errorf("error abriendo el disco: %v", err)
+	}
+
+	var dirs []DirUsage
+	walk(part, root, "/", &data, &dirs)
+
+	// Promedio de fragmentación de los archivos que pueden estar fragmentados
+	fragmentable := 0
+	for _, f := range data.Files {
+		if f.Blocks > 1 {
+			data.Fragmentation += f.Fragmentation
+			fragmentable++
+		}
+	}
+	if fragmentable > 0 {
+		data.Fragmentation /= float64(fragmentable)
+	}
+
+	data.LargestFiles = append([]FileUsage{}, data.Files...)
+	sort.SliceStable(data.LargestFiles, func(i, j int) bool {
+		return data.LargestFiles[i].Size > data.LargestFiles[j].Size
+	})
+	data.LargestFiles = data.LargestFiles[:min(top, len(data.LargestFiles))]
+
+	sort.SliceStable(dirs, func(i, j int) bool {
+		return dirs[i].Size > dirs[j].Size
+	})
+	data.LargestDirs = append([]DirUsage{}, dirs[:min(top, len(dirs))]...)
+	return data, nil
+}
+
+// walk agrega los archivos debajo de node a data y las carpetas a dirs, y retorna
+// el tamaño y la cantidad de archivos de node. Los inodos repetidos ya se contaron.
+func walk(part *Cache.Partition, node *Tree.TreeNode, nodePath string, data *UsageData, dirs *[]DirUsage) (int64, int) {
+	if node.Repeated {
+		return 0, 0
+	}
+	if node.Type != "directorio" {
+		data.Files = append(data.Files, fileUsage(node, nodePath, dataBlocks(part, node)))
+		for i := range data.Histogram {
+			b := &data.Histogram[i]
+			if node.Size >= b.Min && (b.Max < 0 || node.Size <= b.Max) {
+				b.Count++
+				break
+			}
+		}
+		return int64(node.Size), 1
+	}
+
+	dir := DirUsage{Path: nodePath, Inode: node.Index}
+	for _, child := range node.Children {
+		size, files := walk(part, child, path.Join(nodePath, child.Name), data, dirs)
+		dir.Size += size
+		dir.Files += files
+	}
+	*dirs = append(*dirs, dir)
+	return dir.Size, dir.Files
+}
+
+// dataBlocks retorna los bloques de datos de un archivo en orden: los directos y
+// los de la tabla de punteros de I_block[12], que no cuenta como bloque de datos
+func dataBlocks(part *Cache.Partition, node *Tree.TreeNode) []int32 {
+	var blocks []int32
+	for _, block := range node.Blocks[:12] {
+		if block != -1 {
+			blocks = append(blocks, block)
+		}
+	}
+	if node.Blocks[12] == -1 {
+		return blocks
+	}
+	var pointers Structs.Pointerblock
+	if err := part.ReadBlock(node.Blocks[12], &pointers); err != nil {
+		return blocks
+	}
+	for _, block := range pointers.B_pointers {
+		if block > 0 {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// fileUsage calcula la fragmentación de un archivo a partir de sus bloques de datos
+func fileUsage(node *Tree.TreeNode, nodePath string, blocks []int32) FileUsage {
+	f := FileUsage{Path: nodePath, Inode: node.Index, Size: node.Size}
+	previous := int32(-1)
+	for _, block := range blocks {
+		if f.Blocks == 0 || block != previous+1 {
+			f.Fragments++
+		}
+		f.Blocks++
+		previous = block
+	}
+	if f.Blocks > 1 {
+		f.Fragmentation = float64(f.Fragments-1) / float64(f.Blocks-1)
+	}
+	return f
+}
+
+// freeRuns cuenta los tramos de ceros seguidos del bitmap y retorna el más largo
+func freeRuns(bits string) (int, Run) {
+	count := 0
+	var largest, current Run
+	for i := 0; i <= len(bits); i++ {
+		if i < len(bits) && bits[i] == '0' {
+			if current.Length == 0 {
+				current.Start = i
+			}
+			current.Length++
+			continue
+		}
+		if current.Length > 0 {
+			count++
+			if current.Length > largest.Length {
+				largest = current
+			}
+		}
+		current = Run{}
+	}
+	return count, largest
+}
+
+// UsageReport dibuja el reporte usage en outputPath
+func UsageReport(ctx context.Context, id string, outputPath string, top int) error {
+	data, err := UsageReportData(ctx, id, top)
+	if err != nil {
+		return err
+	}
+
+	graph := &Render.Graph{Title: "REPORTE DE USO - PARTICIÓN " + data.Partition, Direction: Render.LeftRight}
+
+	summary := &Render.Table{}
+	summary.Header("Resumen", "#B3D9FF")
+	summary.Field("Inodos usados", fmt.Sprintf("%d de %d (%s)", data.UsedInodes, data.InodesCount, percent(data.UsedInodes, data.InodesCount)))
+	summary.Field("Inodos libres", data.FreeInodes)
+	summary.Field("Bloques usados", fmt.Sprintf("%d de %d (%s)", data.UsedBlocks, data.BlocksCount, percent(data.UsedBlocks, data.BlocksCount)))
+	summary.Field("Bloques libres", fmt.Sprintf("%d (%s)", data.FreeBlocks, formatSize(int64(data.FreeBlocks)*int64(data.BlockSize))))
+	summary.Field("Tramos libres", data.FreeRuns)
+	if data.LargestFreeRun.Length > 0 {
+		summary.Field("Mayor tramo libre", fmt.Sprintf("%d bloques desde el %d", data.LargestFreeRun.Length, data.LargestFreeRun.Start))
+	} else {
+		summary.Field("Mayor tramo libre", "sin bloques libres")
+	}
+	summary.Field("Fragmentación promedio", fmt.Sprintf("%.0f%%", data.Fragmentation*100))
+	graph.Add("resumen", summary)
+
+	histogram := &Render.Table{}
+	histogram.Header("Tamaños de archivo", "#B3D9FF")
+	largest := 0
+	for _, b := range data.Histogram {
+		largest = max(largest, b.Count)
+	}
+	for _, b := range data.Histogram {
+		bar := ""
+		if largest > 0 {
+			bar = strings.Repeat("█", b.Count*20/largest)
+		}
+		histogram.Row(Render.Cell{Text: b.Label, Bold: true}, Render.Cell{Text: fmt.Sprint(b.Count)}, Render.Cell{Text: bar, Left: true})
+	}
+	graph.Add("histograma", histogram)
+
+	files := &Render.Table{}
+	files.Header(fmt.Sprintf("Archivos más grandes (%d)", len(data.LargestFiles)), "#B3D9FF")
+	files.Row(headerCells("RUTA", "TAMAÑO", "BLOQUES", "FRAGMENTOS")...)
+	for _, f := range data.LargestFiles {
+		files.Row(Render.Cell{Text: f.Path, Left: true}, Render.Cell{Text: formatSize(int64(f.Size))}, Render.Cell{Text: fmt.Sprint(f.Blocks)}, Render.Cell{Text: fmt.Sprint(f.Fragments)})
+	}
+	graph.Add("archivos", files)
+
+	dirs := &Render.Table{}
+	dirs.Header(fmt.Sprintf("Carpetas más grandes (%d)", len(data.LargestDirs)), "#B3D9FF")
+	dirs.Row(headerCells("RUTA", "TAMAÑO", "ARCHIVOS")...)
+	for _, d := range data.LargestDirs {
+		dirs.Row(Render.Cell{Text: d.Path, Left: true}, Render.Cell{Text: formatSize(d.Size)}, Render.Cell{Text: fmt.Sprint(d.Files)})
+	}
+	graph.Add("carpetas", dirs)
+
+	// Los archivos más fragmentados, con el mismo límite que los más grandes
+	fragmented := []FileUsage{}
+	for _, f := range data.Files {
+		if f.Fragments > 1 {
+			fragmented = append(fragmented, f)
+		}
+	}
+	sort.SliceStable(fragmented, func(i, j int) bool {
+		return fragmented[i].Fragmentation > fragmented[j].Fragmentation
+	})
+	fragmented = fragmented[:min(top, len(fragmented))]
+	fragmentation := &Render.Table{}
+	fragmentation.Header("Archivos fragmentados", "#B3D9FF")
+	if len(fragmented) == 0 {
+		fragmentation.Row(Render.Cell{Text: "Ningún archivo está fragmentado", Span: Render.All})
+	} else {
+		fragmentation.Row(headerCells("RUTA", "FRAGMENTOS", "PUNTAJE")...)
+	}
+	for _, f := range fragmented {
+		fragmentation.Row(Render.Cell{Text: f.Path, Left: true}, Render.Cell{Text: fmt.Sprintf("%d de %d bloques", f.Fragments, f.Blocks)}, Render.Cell{Text: fmt.Sprintf("%.2f", f.Fragmentation)})
+	}
+	graph.Add("fragmentacion", fragmentation)
+
+	_, err = Render.WriteContext(ctx, graph, outputPath)
+	return err
+}
+
+func headerCells(titles ...string) []Render.Cell {
+	cells := make([]Render.Cell, len(titles))
+	for i, title := range titles {
+		cells[i] = Render.Cell{Text: title, Bold: true, Color: "#E6F3FF"}
+	}
+	return cells
+}
+
+func percent(part, total int) string {
+	if total == 0 {
+		return "0%"
+	}
+	return fmt.Sprintf("%.1f%%", float64(part)*100/float64(total))
+}
+
+// formatSize muestra una cantidad de bytes en la unidad más cercana
+func formatSize(bytes int64) string {
+	switch {
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+	case bytes >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(bytes)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", bytes)
+	}
+}
@@ -1,27 +1,61 @@
 package UserManager
 
 import (
+	"MIA_P1/Cache"
 	"MIA_P1/DiskManagement"
+	"MIA_P1/Export"
+	"MIA_P1/Locks"
 	"MIA_P1/OutPut"
+	"MIA_P1/Render"
+	"MIA_P1/Results"
 	"MIA_P1/Structs"
 	"MIA_P1/Utilities"
 	"MIA_P1/stores"
 	"encoding/binary"
 	"fmt"
+	"log"
 	"os"
-	"os/exec"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
-var currentUser struct {
+type userSession struct {
 	loggedIn  bool
 	user      string
 	partition string // ID de la partición
 }
 
+var (
+	currentUser userSession
+	sessionMu   sync.RWMutex
+)
+
+// session retorna una copia de la sesión actual
+func session() userSession {
+	sessionMu.RLock()
+	defer sessionMu.RUnlock()
+	return currentUser
+}
+
+// CurrentUser retorna el usuario de la sesión actual y si hay una sesión iniciada
+func CurrentUser() (string, bool) {
+	current := session()
+	return current.user, current.loggedIn
+}
+
+// SaveSession guarda la sesión actual y retorna la función que la restaura
+func SaveSession() func() {
+	saved := session()
+	return func() {
+		sessionMu.Lock()
+		currentUser = saved
+		sessionMu.Unlock()
+	}
+}
+
 func normalizePath(path string) string {
 	// Si la ruta contiene backslashes (\), los convertimos a /
 	path = strings.ReplaceAll(path, "\\", "/")
@@ -45,35 +79,18 @@ func SearchPath(path string, createParents bool, currentPartition *DiskManagemen
 		return 0
 	}
 	components := strings.Split(path, "/")[1:]
-	// Abrir el disco.
-	file, err := Utilities.OpenFile(currentPartition.Path)
+	// Obtener la caché de la partición (superbloque y bitmaps ya en memoria).
+	part, err := partitionCache(currentPartition)
 	if err != nil {
 		return -1
 	}
-	defer file.Close()
-	// Obtener MBR y Superblock.
-	var TempMBR Structs.MRB
-	if err := Utilities.ReadObject(file, &TempMBR, 0); err != nil {
-		return -1
-	}
-	var partIndex int = -1
-	for i := 0; i < 4; i++ {
-		if strings.Contains(string(TempMBR.Partitions[i].Id[:]), currentPartition.ID) {
-			partIndex = i
-			break
-		}
-	}
-	if partIndex == -1 {
-		return -1
-	}
-	var sb Structs.Superblock
-	if err := Utilities.ReadObject(file, &sb, int64(TempMBR.Partitions[partIndex].Start)); err != nil {
-		return -1
-	}
 	currentIndex := 0
 	for _, comp := range components {
-		inode, _ := GetInodeFromPathByIndex(currentIndex, file, sb)
-		folder, err := ReadFolderBlock(file, sb, inode.I_block[0])
+		inode := GetInodeFromPathByIndex(currentIndex, part)
+		if inode == nil {
+			return -1
+		}
+		folder, err := ReadFolderBlock(part, inode.I_block[0])
 		if err != nil {
 			return -1
 		}
@@ -88,7 +105,7 @@ func SearchPath(path string, createParents bool, currentPartition *DiskManagemen
 		if !found {
 			if createParents {
 				// Crear la carpeta faltante.
-				newInode, _, newIndex, err := allocateInode(file, sb, currentUser.user, "default", "664", true)
+				newInode, newIndex, err := allocateInode(part, session().user, "default", "664", true)
 				if err != nil {
 					return -1
 				}
@@ -97,7 +114,7 @@ func SearchPath(path string, createParents bool, currentPartition *DiskManagemen
 					return -1
 				}
 				// Agregar la entrada en el FolderBlock del directorio actual.
-				if err := AddEntryToFolderByIndex(currentIndex, file, sb, comp, newIndex); err != nil {
+				if err := AddEntryToFolderByIndex(currentIndex, part, comp, newIndex); err != nil {
 					return -1
 				}
 				currentIndex = newIndex
@@ -109,8 +126,13 @@ func SearchPath(path string, createParents bool, currentPartition *DiskManagemen
 	return currentIndex
 }
 
-func EntryExistsInFolder(folderInode Structs.Inode, file *os.File, sb Structs.Superblock, name string) bool {
-	folder, err := ReadFolderBlock(file, sb, folderInode.I_block[0])
+// partitionCache retorna la caché de la partición montada indicada
+func partitionCache(mp *DiskManagement.MountedPartition) (*Cache.Partition, error) {
+	return Cache.Open(mp.ID, mp.Path, mp.Start)
+}
+
+func EntryExistsInFolder(folderInode Structs.Inode, part *Cache.Partition, name string) bool {
+	folder, err := ReadFolderBlock(part, folderInode.I_block[0])
 	if err != nil {
 		return false
 	}
@@ -124,8 +146,8 @@ func EntryExistsInFolder(folderInode Structs.Inode, file *os.File, sb Structs.Su
 
 // MultiBlockUpdateFile actualiza el contenido completo de un archivo distribuyéndolo en bloques.
 // Soporta 12 apuntadores directos y un indirecto simple (I_block[12]).
-// inodeOffset: offset en disco del inodo.
-func MultiBlockUpdateFile(inode *Structs.Inode, fullData string, file *os.File, sb Structs.Superblock, inodeOffset int64) error {
+// inodeIndex: índice del inodo en la tabla de inodos.
+func MultiBlockUpdateFile(inode *Structs.Inode, fullData string, part *Cache.Partition, inodeIndex int) error {
 	blockSize := binary.Size(Structs.Fileblock{})
 	// Calcula el número de bloques requeridos.
 	requiredBlocks := (len(fullData) + blockSize - 1) / blockSize
@@ -140,7 +162,7 @@ func MultiBlockUpdateFile(inode *Structs.Inode, fullData string, file *os.File,
 		}
 		chunk := fullData[start:end]
 		if inode.I_block[i] == -1 {
-			blk, err := allocateBlock(file, sb)
+			blk, err := allocateBlock(part)
 			if err != nil {
 				return fmt.Errorf("no se pudo asignar bloque directo para chunk %d: %v", i, err)
 			}
@@ -148,9 +170,7 @@ func MultiBlockUpdateFile(inode *Structs.Inode, fullData string, file *os.File,
 		}
 		var block Structs.Fileblock
 		copy(block.B_content[:], chunk)
-		blockOffset := int64(sb.S_block_start) + int64(inode.I_block[i])*int64(blockSize)
-		fmt.Printf("MultiBlockUpdateFile: Escribiendo bloque directo %d en offset %d, bloque ID %d\n", i, blockOffset, inode.I_block[i])
-		if err := Utilities.WriteObject(file, block, blockOffset); err != nil {
+		if err := part.WriteBlock(inode.I_block[i], block); err != nil {
 			return fmt.Errorf("error al escribir bloque directo %d: %v", i, err)
 		}
 	}
@@ -161,14 +181,13 @@ func MultiBlockUpdateFile(inode *Structs.Inode, fullData string, file *os.File,
 		for i := requiredBlocks; i < directLimit; i++ {
 			if inode.I_block[i] != -1 {
 				var emptyBlock Structs.Fileblock
-				blockOffset := int64(sb.S_block_start) + int64(inode.I_block[i])*int64(blockSize)
-				Utilities.WriteObject(file, emptyBlock, blockOffset)
-				freeBlockInBitmap(file, sb, inode.I_block[i])
+				part.WriteBlock(inode.I_block[i], emptyBlock)
+				freeBlockInBitmap(part, inode.I_block[i])
 				inode.I_block[i] = -1
 			}
 		}
 		inode.I_size = int32(len(fullData))
-		return Utilities.WriteObject(file, *inode, inodeOffset)
+		return part.WriteInode(int32(inodeIndex), *inode)
 	}
 
 	// Usar apuntador indirecto en I_block[12].
@@ -179,24 +198,25 @@ func MultiBlockUpdateFile(inode *Structs.Inode, fullData string, file *os.File,
 		return fmt.Errorf("archivo excede la capacidad soportada (requiere %d bloques, máximo %d)", requiredBlocks, directLimit+maxIndirect)
 	}
 	// Asignar bloque para tabla de punteros indirectos, si no está asignado.
+	// Una tabla nueva empieza con todos sus punteros en -1: el bloque libre está en
+	// ceros y un puntero 0 apuntaría al bloque de la carpeta raíz.
+	var ptrBlock Structs.Pointerblock
 	if inode.I_block[directLimit] == -1 {
-		blk, err := allocateBlock(file, sb)
+		blk, err := allocateBlock(part)
 		if err != nil {
 			return fmt.Errorf("no se pudo asignar el bloque indirecto: %v", err)
 		}
 		inode.I_block[directLimit] = blk
-	}
-	indirectBlockIndex := inode.I_block[directLimit]
-	// Leer la tabla de punteros indirectos.
-	var ptrBlock Structs.Pointerblock
-	ptrBlockSize := binary.Size(Structs.Pointerblock{})
-	ptrBlockOffset := int64(sb.S_block_start) + int64(indirectBlockIndex)*int64(ptrBlockSize)
-	if err := Utilities.ReadObject(file, &ptrBlock, ptrBlockOffset); err != nil {
+		for j := 0; j < len(ptrBlock.B_pointers); j++ {
+			ptrBlock.B_pointers[j] = -1
+		}
+	} else if err := part.ReadBlock(inode.I_block[directLimit], &ptrBlock); err != nil {
 		// Si no se puede leer, inicializar con -1.
 		for j := 0; j < len(ptrBlock.B_pointers); j++ {
 			ptrBlock.B_pointers[j] = -1
 		}
 	}
+	indirectBlockIndex := inode.I_block[directLimit]
 
 	// Escribir en bloques indirectos.
 	for j := 0; j < remaining; j++ {
@@ -208,7 +228,7 @@ func MultiBlockUpdateFile(inode *Structs.Inode, fullData string, file *os.File,
 		}
 		chunk := fullData[start:end]
 		if ptrBlock.B_pointers[j] == -1 {
-			blk, err := allocateBlock(file, sb)
+			blk, err := allocateBlock(part)
 			if err != nil {
 				return fmt.Errorf("no se pudo asignar bloque indirecto para chunk %d: %v", j, err)
 			}
@@ -216,53 +236,48 @@ func MultiBlockUpdateFile(inode *Structs.Inode, fullData string, file *os.File,
 		}
 		var block Structs.Fileblock
 		copy(block.B_content[:], chunk)
-		blockOffset := int64(sb.S_block_start) + int64(ptrBlock.B_pointers[j])*int64(blockSize)
-		fmt.Printf("MultiBlockUpdateFile: Escribiendo bloque indirecto %d en offset %d, bloque ID %d\n", j, blockOffset, ptrBlock.B_pointers[j])
-		if err := Utilities.WriteObject(file, block, blockOffset); err != nil {
+		if err := part.WriteBlock(ptrBlock.B_pointers[j], block); err != nil {
 			return fmt.Errorf("error al escribir bloque indirecto %d: %v", j, err)
 		}
 	}
-	// Escribir la tabla de punteros indirectos en disco.
-	if err := Utilities.WriteObject(file, ptrBlock, ptrBlockOffset); err != nil {
-		return fmt.Errorf("error al escribir la tabla de punteros indirectos: %v", err)
-	}
 
 	// Liberar bloques indirectos sobrantes.
 	for j := remaining; j < len(ptrBlock.B_pointers); j++ {
 		if ptrBlock.B_pointers[j] != -1 {
 			var emptyBlock Structs.Fileblock
-			blockOffset := int64(sb.S_block_start) + int64(ptrBlock.B_pointers[j])*int64(blockSize)
-			Utilities.WriteObject(file, emptyBlock, blockOffset)
-			freeBlockInBitmap(file, sb, ptrBlock.B_pointers[j])
+			part.WriteBlock(ptrBlock.B_pointers[j], emptyBlock)
+			freeBlockInBitmap(part, ptrBlock.B_pointers[j])
 			ptrBlock.B_pointers[j] = -1
 		}
 	}
+	// Escribir la tabla de punteros indirectos.
+	if err := part.WriteBlock(indirectBlockIndex, ptrBlock); err != nil {
+		return fmt.Errorf("error al escribir la tabla de punteros indirectos: %v", err)
+	}
 	inode.I_size = int32(len(fullData))
-	return Utilities.WriteObject(file, *inode, inodeOffset)
+	return part.WriteInode(int32(inodeIndex), *inode)
 }
 
 // AddEntryToFolderByIndex agrega una entrada en el FolderBlock de la carpeta cuyo inodo está en parentIndex.
 // Se utiliza el nuevo inodo (newIndex) para la nueva entrada.
-func AddEntryToFolderByIndex(parentIndex int, file *os.File, sb Structs.Superblock, entryName string, newIndex int) error {
-	parentInode, _ := GetInodeFromPathByIndex(parentIndex, file, sb)
+func AddEntryToFolderByIndex(parentIndex int, part *Cache.Partition, entryName string, newIndex int) error {
+	parentInode := GetInodeFromPathByIndex(parentIndex, part)
 	if parentInode == nil {
 		return fmt.Errorf("no se encontró la carpeta padre (inodo %d)", parentIndex)
 	}
 	// Asegurarse de que el FolderBlock esté asignado.
 	if parentInode.I_block[0] == -1 {
-		blk, err := allocateBlock(file, sb)
+		blk, err := allocateBlock(part)
 		if err != nil {
 			return fmt.Errorf("error al asignar bloque para el FolderBlock: %v", err)
 		}
 		parentInode.I_block[0] = blk
 		// Actualizar el inodo padre.
-		inodeSize := binary.Size(Structs.Inode{})
-		offset := int64(sb.S_inode_start) + int64(parentIndex)*int64(inodeSize)
-		if err := Utilities.WriteObject(file, *parentInode, offset); err != nil {
+		if err := part.WriteInode(int32(parentIndex), *parentInode); err != nil {
 			return fmt.Errorf("error al actualizar el inodo padre: %v", err)
 		}
 	}
-	folder, err := ReadFolderBlock(file, sb, parentInode.I_block[0])
+	folder, err := ReadFolderBlock(part, parentInode.I_block[0])
 	if err != nil {
 		return fmt.Errorf("error al leer el FolderBlock: %v", err)
 	}
@@ -271,12 +286,9 @@ func AddEntryToFolderByIndex(parentIndex int, file *os.File, sb Structs.Superblo
 		if strings.Trim(string(folder.B_content[i].B_name[:]), "\x00") == "" {
 			copy(folder.B_content[i].B_name[:], entryName)
 			folder.B_content[i].B_inodo = int32(newIndex)
-			blockSize := binary.Size(Structs.Folderblock{})
-			offset := int64(sb.S_block_start) + int64(parentInode.I_block[0])*int64(blockSize)
-			if err := Utilities.WriteObject(file, folder, offset); err != nil {
+			if err := part.WriteBlock(parentInode.I_block[0], folder); err != nil {
 				return fmt.Errorf("error al escribir el FolderBlock actualizado: %v", err)
 			}
-			fmt.Printf("AddEntryToFolderByIndex: Se agregó la entrada '%s' con inodo %d\n", entryName, newIndex)
 			return nil
 		}
 	}
@@ -284,36 +296,31 @@ func AddEntryToFolderByIndex(parentIndex int, file *os.File, sb Structs.Superblo
 }
 
 // ReadFolderBlock lee un FolderBlock dado el índice de bloque.
-func ReadFolderBlock(file *os.File, sb Structs.Superblock, blockIndex int32) (*Structs.Folderblock, error) {
-	blockSize := binary.Size(Structs.Folderblock{})
-	offset := int64(sb.S_block_start) + int64(blockIndex)*int64(blockSize)
+func ReadFolderBlock(part *Cache.Partition, blockIndex int32) (*Structs.Folderblock, error) {
 	var folder Structs.Folderblock
-	if err := Utilities.ReadObject(file, &folder, offset); err != nil {
+	if err := part.ReadBlock(blockIndex, &folder); err != nil {
 		return nil, err
 	}
 	return &folder, nil
 }
 
-// GetInodeFromPathByIndex retorna el inodo y su offset dado un índice.
-func GetInodeFromPathByIndex(index int, file *os.File, sb Structs.Superblock) (*Structs.Inode, int64) {
-	inodeSize := binary.Size(Structs.Inode{})
-	offset := int64(sb.S_inode_start) + int64(index)*int64(inodeSize)
-	var inode Structs.Inode
-	if err := Utilities.ReadObject(file, &inode, offset); err != nil {
-		return nil, 0
+// GetInodeFromPathByIndex retorna el inodo dado un índice.
+func GetInodeFromPathByIndex(index int, part *Cache.Partition) *Structs.Inode {
+	inode, err := part.ReadInode(int32(index))
+	if err != nil {
+		return nil
 	}
-	return &inode, offset
+	return inode
 }
 
 // allocateInode recorre la tabla de inodos y asigna el primer inodo libre.
-// Retorna un puntero al inodo, su offset en disco, el índice asignado y error.
-func allocateInode(file *os.File, sb Structs.Superblock, owner, group, perm string, isDirectory bool) (*Structs.Inode, int64, int, error) {
-	inodeSize := binary.Size(Structs.Inode{})
+// Retorna un puntero al inodo, el índice asignado y error.
+func allocateInode(part *Cache.Partition, owner, group, perm string, isDirectory bool) (*Structs.Inode, int, error) {
+	sb := part.Superblock()
 	for i := 0; i < int(sb.S_inodes_count); i++ {
-		offset := int64(sb.S_inode_start) + int64(i)*int64(inodeSize)
-		var inode Structs.Inode
-		if err := Utilities.ReadObject(file, &inode, offset); err != nil {
-			return nil, 0, -1, err
+		inode, err := part.ReadInode(int32(i))
+		if err != nil {
+			return nil, -1, err
 		}
 		// Un inodo libre se identifica con I_type[0] == 0.
 		if inode.I_type[0] == 0 {
@@ -340,14 +347,14 @@ func allocateInode(file *os.File, sb Structs.Superblock, owner, group, perm stri
 				copy(inode.I_perm[:], "664")
 			}
 			// Escribir el inodo actualizado.
-			if err := Utilities.WriteObject(file, inode, offset); err != nil {
-				return nil, 0, -1, err
+			if err := part.WriteInode(int32(i), *inode); err != nil {
+				return nil, -1, err
 			}
-			fmt.Printf("allocateInode: Inodo asignado en índice %d, offset %d, I_block: %v\n", i, offset, inode.I_block)
-			return &inode, offset, i, nil
+			part.SetInodeUsed(int32(i), true)
+			return inode, i, nil
 		}
 	}
-	return nil, 0, -1, fmt.Errorf("no hay inodos libres")
+	return nil, -1, fmt.Errorf("no hay inodos libres")
 }
 
 func InitializeFolder(newFolderInode *Structs.Inode, parentInode *Structs.Inode, newIndex, parentIndex int) error {
@@ -360,46 +367,34 @@ func InitializeFolder(newFolderInode *Structs.Inode, parentInode *Structs.Inode,
 	folder.B_content[1].B_inodo = int32(parentIndex)
 	// Las demás entradas se dejan vacías.
 	// La escritura se realizará luego mediante MultiBlockUpdateFile.
-	fmt.Printf("InitializeFolder: '.' = %d, '..' = %d\n", newIndex, parentIndex)
 	return nil
 }
 
-func allocateBlock(file *os.File, sb Structs.Superblock) (int32, error) {
-	blockCount := sb.S_blocks_count
-	bmOffset := int64(sb.S_bm_block_start)
-	for i := int32(0); i < blockCount; i++ {
-		var bit byte
-		if err := Utilities.ReadObject(file, &bit, bmOffset+int64(i)); err != nil {
-			return -1, err
-		}
-		if bit == 0 {
-			if err := Utilities.WriteObject(file, byte(1), bmOffset+int64(i)); err != nil {
-				return -1, err
-			}
-			return i, nil
-		}
-	}
-	return -1, fmt.Errorf("no se encontró bloque libre")
+// allocateBlock asigna el primer bloque libre usando el bitmap cacheado.
+func allocateBlock(part *Cache.Partition) (int32, error) {
+	return part.AllocateBlock()
 }
 
 // freeBlockInBitmap marca un bloque como libre en el bitmap.
-func freeBlockInBitmap(file *os.File, sb Structs.Superblock, blockIndex int32) error {
-	bmOffset := int64(sb.S_bm_block_start) + int64(blockIndex)
-	return Utilities.WriteObject(file, byte(0), bmOffset)
+func freeBlockInBitmap(part *Cache.Partition, blockIndex int32) error {
+	part.FreeBlock(blockIndex)
+	return nil
 }
 
-func GetInodeFromPath(path string, file *os.File, sb Structs.Superblock) (*Structs.Inode, int64) {
+func GetInodeFromPath(path string, part *Cache.Partition) (*Structs.Inode, int) {
 	if path == "/" {
-		return GetInodeFromPathByIndex(0, file, sb)
+		return GetInodeFromPathByIndex(0, part), 0
 	}
 	components := strings.Split(path, "/")[1:]
 	currentIndex := 0
-	var _ int64
 	for _, comp := range components {
-		inode, _ := GetInodeFromPathByIndex(currentIndex, file, sb)
-		folder, err := ReadFolderBlock(file, sb, inode.I_block[0])
+		inode := GetInodeFromPathByIndex(currentIndex, part)
+		if inode == nil {
+			return nil, -1
+		}
+		folder, err := ReadFolderBlock(part, inode.I_block[0])
 		if err != nil {
-			return nil, 0
+			return nil, -1
 		}
 		found := false
 		for _, entry := range folder.B_content {
@@ -410,10 +405,10 @@ func GetInodeFromPath(path string, file *os.File, sb Structs.Superblock) (*Struc
 			}
 		}
 		if !found {
-			return nil, 0
+			return nil, -1
 		}
 	}
-	return GetInodeFromPathByIndex(currentIndex, file, sb)
+	return GetInodeFromPathByIndex(currentIndex, part), currentIndex
 }
 
 func hasWritePermission(inode Structs.Inode, currentUser string) bool {
@@ -462,51 +457,46 @@ func GetCurrentSessionPartition() *DiskManagement.MountedPartition {
 	return nil
 }
 
-func Login(user string, pass string, id string) error {
-	OutPut.Println("======Start LOGIN======")
-	fmt.Printf("User: %s, Pass: %s, ID: %s\n", user, pass, id)
+func Login(out *OutPut.Output, user string, pass string, id string) error {
+	out.Println("======Start LOGIN======")
+	out.Printf("User: %s, Pass: %s, ID: %s\n", user, pass, id)
 	id = strings.ToUpper(id)
 	if user == "" {
-		return fmt.Errorf("user cannot be empty")
+		return Results.Errorf(Results.InvalidParams, "user cannot be empty")
 	}
 	if pass == "" {
-		return fmt.Errorf("password cannot be empty")
+		return Results.Errorf(Results.InvalidParams, "password cannot be empty")
 	}
 	if id == "" {
-		return fmt.Errorf("id cannot be empty")
+		return Results.Errorf(Results.InvalidParams, "id cannot be empty")
 	}
 
-	if currentUser.loggedIn {
-		return fmt.Errorf("another user is already logged in")
+	if session().loggedIn {
+		return Results.Errorf(Results.AlreadyExists, "another user is already logged in")
 	}
 
 	partition, diskPath, err := stores.GetMountedPartition(id)
 	if err != nil {
-		return fmt.Errorf("error finding partition: %v", err)
+		return Results.Errorf(Results.NotFound, "error finding partition: %v", err)
 	}
+	defer Locks.RLockPartition(diskPath, id)()
 
-	file, err := Utilities.OpenFile(diskPath)
+	part, err := Cache.Open(id, diskPath, partition.Start)
 	if err != nil {
-		return fmt.Errorf("error opening file: %v", err)
-	}
-	defer file.Close()
-
-	var superblock Structs.Superblock
-	if err := Utilities.ReadObject(file, &superblock, int64(partition.Start)); err != nil {
-		return fmt.Errorf("error reading superblock: %v", err)
+		return Results.Errorf(Results.IOError, "error opening partition: %v", err)
 	}
 
 	// Find users.txt inode
-	var rootInode Structs.Inode
-	if err := Utilities.ReadObject(file, &rootInode, int64(superblock.S_inode_start)); err != nil {
-		return fmt.Errorf("error reading root inode: %v", err)
+	rootInode, err := part.ReadInode(0)
+	if err != nil {
+		return Results.Errorf(Results.IOError, "error reading root inode: %v", err)
 	}
 
 	var usersInodeIndex int32 = -1
 	for i := 0; i < 15; i++ {
 		if rootInode.I_block[i] != -1 {
 			var block Structs.Folderblock
-			if err := Utilities.ReadObject(file, &block, int64(superblock.S_block_start+rootInode.I_block[i]*superblock.S_block_size)); err != nil {
+			if err := part.ReadBlock(rootInode.I_block[i], &block); err != nil {
 				continue
 			}
 			for _, entry := range block.B_content {
@@ -521,80 +511,81 @@ func Login(user string, pass string, id string) error {
 		}
 	}
 	if usersInodeIndex == -1 {
-		return fmt.Errorf("users.txt not found")
+		return Results.Errorf(Results.NotFound, "users.txt not found")
 	}
 
-	var usersInode Structs.Inode
-	if err := Utilities.ReadObject(file, &usersInode, int64(superblock.S_inode_start+usersInodeIndex*superblock.S_inode_size)); err != nil {
-		return fmt.Errorf("error reading users.txt inode: %v", err)
+	usersInode, err := part.ReadInode(usersInodeIndex)
+	if err != nil {
+		return Results.Errorf(Results.IOError, "error reading users.txt inode: %v", err)
 	}
 
 	var usersBlock Structs.Fileblock
-	if err := Utilities.ReadObject(file, &usersBlock, int64(superblock.S_block_start+usersInode.I_block[0]*superblock.S_block_size)); err != nil {
-		return fmt.Errorf("error reading users.txt block: %v", err)
+	if err := part.ReadBlock(usersInode.I_block[0], &usersBlock); err != nil {
+		return Results.Errorf(Results.IOError, "error reading users.txt block: %v", err)
 	}
 
 	content := strings.Trim(string(usersBlock.B_content[:]), "\x00")
-	fmt.Printf("Users.txt content: %q\n", content)
 	lines := strings.Split(content, "\n")
 	for _, line := range lines {
 		fields := strings.Split(line, ",")
 		if len(fields) == 5 && fields[1] == "U" && strings.EqualFold(fields[3], user) && fields[4] == pass {
-			currentUser.loggedIn = true
-			currentUser.user = user
-			currentUser.partition = id
-			mounted := DiskManagement.GetMountedPartitions() //codigo ya corregido
-			for disk := range mounted {
-				for i := range mounted[disk] {
-					if mounted[disk][i].ID == id {
-						mounted[disk][i].LoggedIn = true
-						break
-					}
-				}
-			} //fin
+			// Otra petición pudo iniciar sesión mientras se leía users.txt
+			sessionMu.Lock()
+			if currentUser.loggedIn {
+				sessionMu.Unlock()
+				return Results.Errorf(Results.AlreadyExists, "another user is already logged in")
+			}
+			currentUser = userSession{loggedIn: true, user: user, partition: id}
+			sessionMu.Unlock()
+			DiskManagement.SetLoggedIn(id, true)
 
-			OutPut.Println("Login successful")
-			OutPut.Println("======End LOGIN======")
+			out.Println("Login successful")
+			out.Println("======End LOGIN======")
 			return nil
 		}
 	}
 
-	return fmt.Errorf("invalid user or password")
+	return Results.Errorf(Results.PermissionDenied, "invalid user or password")
 }
 
-func Logout() error {
-	OutPut.Println("======Start LOGOUT======")
+func Logout(out *OutPut.Output) error {
+	out.Println("======Start LOGOUT======")
 
-	if !currentUser.loggedIn {
-		OutPut.Println("Error: No hay sesión activa")
-		OutPut.Println("======End LOGOUT======")
-		return fmt.Errorf("no hay sesión activa")
+	// La sesión se cierra antes de bajar la caché para no retener sessionMu
+	// mientras se espera el candado de la partición
+	sessionMu.Lock()
+	ended := currentUser
+	currentUser = userSession{}
+	sessionMu.Unlock()
+
+	if !ended.loggedIn {
+		out.Println("======End LOGOUT======")
+		return Results.Errorf(Results.NotLoggedIn, "no hay sesión activa")
 	}
 
 	// Buscar y actualizar la partición con sesión iniciada
-	for disk := range DiskManagement.GetMountedPartitions() {
-		for i := range DiskManagement.GetMountedPartitions()[disk] {
-			if DiskManagement.GetMountedPartitions()[disk][i].ID == currentUser.partition {
-				DiskManagement.GetMountedPartitions()[disk][i].LoggedIn = false
-				break
-			}
+	DiskManagement.SetLoggedIn(ended.partition, false)
+
+	// Bajar a disco los cambios pendientes de la partición
+	if diskPath := DiskManagement.GetPartitionPathByID(ended.partition); diskPath != "" {
+		unlock := Locks.LockPartition(diskPath, ended.partition)
+		if err := Cache.Flush(ended.partition); err != nil {
+			out.Error("Error al guardar la partición:", err)
 		}
+		unlock()
 	}
 
-	fmt.Printf("Sesión finalizada para el usuario %s en la partición %s\n", currentUser.user, currentUser.partition)
-	currentUser.loggedIn = false
-	currentUser.user = ""
-	currentUser.partition = ""
+	out.Printf("Sesión finalizada para el usuario %s en la partición %s\n", ended.user, ended.partition)
 
-	OutPut.Println("======End LOGOUT======")
+	out.Println("======End LOGOUT======")
 	return nil
 }
 
-func InitSearch(path string, file *os.File, sb Structs.Superblock) int32 {
+func InitSearch(path string, part *Cache.Partition) int32 {
 	// Simplified search for /users.txt in the root directory
-	var rootInode Structs.Inode
-	if err := Utilities.ReadObject(file, &rootInode, int64(sb.S_inode_start)); err != nil {
-		fmt.Printf("Error reading root inode: %v\n", err)
+	rootInode, err := part.ReadInode(0)
+	if err != nil {
+		log.Printf("Error reading root inode: %v", err)
 		return -1
 	}
 
@@ -603,8 +594,8 @@ func InitSearch(path string, file *os.File, sb Structs.Superblock) int32 {
 			continue
 		}
 		var block Structs.Fileblock
-		if err := Utilities.ReadObject(file, &block, int64(sb.S_block_start+rootInode.I_block[i]*sb.S_block_size)); err != nil {
-			fmt.Printf("Error reading block %d: %v\n", i, err)
+		if err := part.ReadBlock(rootInode.I_block[i], &block); err != nil {
+			log.Printf("Error reading block %d: %v", i, err)
 			continue
 		}
 		content := strings.Trim(string(block.B_content[:]), "\x00")
@@ -622,15 +613,29 @@ func InitSearch(path string, file *os.File, sb Structs.Superblock) int32 {
 	return -1
 }
 
-func GetInodeFileData(inode Structs.Inode, file *os.File, sb Structs.Superblock) string {
+func GetInodeFileData(inode Structs.Inode, part *Cache.Partition) string {
 	var data strings.Builder
-	for i := 0; i < 15; i++ {
-		if inode.I_block[i] == -1 {
+	blocks := append([]int32{}, inode.I_block[:12]...)
+	// I_block[12] es la tabla de punteros indirectos que escribe MultiBlockUpdateFile.
+	if inode.I_block[12] != -1 {
+		var ptrBlock Structs.Pointerblock
+		if err := part.ReadBlock(inode.I_block[12], &ptrBlock); err != nil {
+			log.Printf("Error reading block %d: %v", inode.I_block[12], err)
+		} else {
+			for _, ptr := range ptrBlock.B_pointers {
+				if ptr > 0 {
+					blocks = append(blocks, ptr)
+				}
+			}
+		}
+	}
+	for _, blk := range blocks {
+		if blk == -1 {
 			continue
 		}
 		var block Structs.Fileblock
-		if err := Utilities.ReadObject(file, &block, int64(sb.S_block_start+inode.I_block[i]*sb.S_block_size)); err != nil {
-			fmt.Printf("Error reading block %d: %v\n", inode.I_block[i], err)
+		if err := part.ReadBlock(blk, &block); err != nil {
+			log.Printf("Error reading block %d: %v", blk, err)
 			continue
 		}
 		content := strings.Trim(string(block.B_content[:]), "\x00")
@@ -639,79 +644,71 @@ func GetInodeFileData(inode Structs.Inode, file *os.File, sb Structs.Superblock)
 	return data.String()
 }
 
-func MultiBlockUpdate(inode *Structs.Inode, content string, file *os.File, sb Structs.Superblock, inodeOffset int64, partitionStart int64) error {
+func MultiBlockUpdate(inode *Structs.Inode, content string, part *Cache.Partition, inodeIndex int32) error {
 	// Update the first block of users.txt
 	if inode.I_block[0] == -1 {
+		sb := part.Superblock()
 		inode.I_block[0] = sb.S_first_blo
 		sb.S_first_blo++
 		sb.S_free_blocks_count--
+		part.MarkSuperblockDirty()
 	}
 	var block Structs.Fileblock
 	copy(block.B_content[:], content)
-	if err := Utilities.WriteObject(file, block, int64(sb.S_block_start+inode.I_block[0]*sb.S_block_size)); err != nil {
+	if err := part.WriteBlock(inode.I_block[0], block); err != nil {
 		return fmt.Errorf("error writing block: %v", err)
 	}
 
 	// Update inode
 	inode.I_size = int32(len(content))
 	copy(inode.I_mtime[:], time.Now().Format("2006-01-02 15:04:05"))
-	if err := Utilities.WriteObject(file, *inode, inodeOffset); err != nil {
+	if err := part.WriteInode(inodeIndex, *inode); err != nil {
 		return fmt.Errorf("error writing inode: %v", err)
 	}
-
-	// Update superblock
-	if err := Utilities.WriteObject(file, sb, partitionStart); err != nil {
-		return fmt.Errorf("error writing superblock: %v", err)
-	}
 	return nil
 }
 
-func Mkgrp(name string) error {
-	OutPut.Println("======Start MKGRP======")
-	fmt.Printf("Group Name: %s\n", name)
+func Mkgrp(out *OutPut.Output, name string) error {
+	out.Println("======Start MKGRP======")
+	out.Printf("Group Name: %s\n", name)
 
-	if !currentUser.loggedIn {
-		return fmt.Errorf("necesita iniciar sesión")
+	current := session()
+	if !current.loggedIn {
+		return Results.Errorf(Results.NotLoggedIn, "necesita iniciar sesión")
 	}
-	if currentUser.user != "root" {
-		return fmt.Errorf("solo el usuario root puede ejecutar mkgrp")
+	if current.user != "root" {
+		return Results.Errorf(Results.PermissionDenied, "solo el usuario root puede ejecutar mkgrp")
 	}
 
-	partition, diskPath, err := stores.GetMountedPartition(currentUser.partition)
+	partition, diskPath, err := stores.GetMountedPartition(current.partition)
 	if err != nil {
-		return fmt.Errorf("error finding partition %s: %v", currentUser.partition, err)
+		return Results.Errorf(Results.NotFound, "error finding partition %s: %v", current.partition, err)
 	}
+	defer Locks.LockPartition(diskPath, current.partition)()
 
-	file, err := Utilities.OpenFile(diskPath)
+	part, err := Cache.Open(current.partition, diskPath, partition.Start)
 	if err != nil {
-		return fmt.Errorf("error opening file %s: %v", diskPath, err)
-	}
-	defer file.Close()
-
-	var sb Structs.Superblock
-	if err := Utilities.ReadObject(file, &sb, int64(partition.Start)); err != nil {
-		return fmt.Errorf("error reading superblock: %v", err)
+		return Results.Errorf(Results.IOError, "error opening file: %v", err)
 	}
 
-	indexInode := InitSearch("/users.txt", file, sb)
+	indexInode := InitSearch("/users.txt", part)
 	if indexInode < 0 {
-		return fmt.Errorf("no se encontró el archivo users.txt")
+		return Results.Errorf(Results.NotFound, "no se encontró el archivo users.txt")
 	}
 
-	inodeOffset := int64(sb.S_inode_start) + int64(indexInode)*int64(binary.Size(Structs.Inode{}))
-	var usersInode Structs.Inode
-	if err := Utilities.ReadObject(file, &usersInode, inodeOffset); err != nil {
-		return fmt.Errorf("error reading users.txt inode: %v", err)
+	usersInode, err := part.ReadInode(indexInode)
+	if err != nil {
+		return Results.Errorf(Results.IOError, "error reading users.txt inode: %v", err)
 	}
 
-	data := GetInodeFileData(usersInode, file, sb)
+	data := GetInodeFileData(*usersInode, part)
 	trimmedData := strings.TrimRight(data, "\n")
 	lines := strings.Split(trimmedData, "\n")
 
 	for _, line := range lines {
 		tokens := strings.Split(line, ",")
 		if len(tokens) >= 3 && strings.TrimSpace(tokens[1]) == "G" && strings.TrimSpace(tokens[2]) == name {
-			return fmt.Errorf("el grupo %s ya existe", name)
+			return Results.Errorf(Results.AlreadyExists, "el grupo %s ya existe", name)
 		}
 	}
 
@@ -729,12 +726,12 @@ func Mkgrp(name string) error {
 	newRecord := fmt.Sprintf("%d,G,%s\n", newGroupID, name)
 	newContent := trimmedData + "\n" + newRecord
 
-	if err := MultiBlockUpdate(&usersInode, newContent, file, sb, inodeOffset, int64(partition.Start)); err != nil {
-		return fmt.Errorf("error updating users.txt: %v", err)
+	if err := MultiBlockUpdate(usersInode, newContent, part, indexInode); err != nil {
+		return Results.Errorf(Results.IOError, "error updating users.txt: %v", err)
 	}
 
-	fmt.Printf("Grupo %s creado exitosamente con ID %d\n", name, newGroupID)
-	OutPut.Println("======End MKGRP======")
+	out.Printf("Grupo %s creado exitosamente con ID %d\n", name, newGroupID)
+	out.Println("======End MKGRP======")
 	return nil
 }
 
@@ -752,7 +749,7 @@ func initInode(inode *Structs.Inode, date string) {
 	}
 }
 
-func CreateRootAndUsersFile(newSuperblock Structs.Superblock, date string, file *os.File) error {
+func CreateRootAndUsersFile(newSuperblock Structs.Superblock, date string, file *os.File, partitionStart int64) error {
 	var Inode0, Inode1 Structs.Inode
 	initInode(&Inode0, date)
 	initInode(&Inode1, date)
@@ -797,7 +794,8 @@ func CreateRootAndUsersFile(newSuperblock Structs.Superblock, date string, file
 	newSuperblock.S_first_blo = 2 // Next available block
 	newSuperblock.S_free_inodes_count--
 	newSuperblock.S_free_blocks_count--
-	if err := Utilities.WriteObject(file, newSuperblock, int64(newSuperblock.S_bm_inode_start-newSuperblock.S_inode_size)); err != nil {
+	// El superbloque vive al inicio de la partición; la caché lo lee desde ahí
+	if err := Utilities.WriteObject(file, newSuperblock, partitionStart); err != nil {
 		return err
 	}
 
@@ -805,45 +803,40 @@ func CreateRootAndUsersFile(newSuperblock Structs.Superblock, date string, file
 }
 
 // getCurrentSessionPartition retorna la primera partición montada que tenga sesión activa.
-func Rmgrp(name string) error {
-	OutPut.Println("======Start RMGRP======")
-	fmt.Printf("Group Name: %s\n", name)
+func Rmgrp(out *OutPut.Output, name string) error {
+	out.Println("======Start RMGRP======")
+	out.Printf("Group Name: %s\n", name)
 
-	if !currentUser.loggedIn {
-		return fmt.Errorf("necesita iniciar sesión")
+	current := session()
+	if !current.loggedIn {
+		return Results.Errorf(Results.NotLoggedIn, "necesita iniciar sesión")
 	}
-	if currentUser.user != "root" {
-		return fmt.Errorf("solo el usuario root puede ejecutar rmgrp")
+	if current.user != "root" {
+		return Results.Errorf(Results.PermissionDenied, "solo el usuario root puede ejecutar rmgrp")
 	}
 
-	partition, diskPath, err := stores.GetMountedPartition(currentUser.partition)
+	partition, diskPath, err := stores.GetMountedPartition(current.partition)
 	if err != nil {
-		return fmt.Errorf("error encontrando la partición: %v", err)
+		return Results.Errorf(Results.NotFound, "error encontrando la partición: %v", err)
 	}
+	defer Locks.LockPartition(diskPath, current.partition)()
 
-	file, err := Utilities.OpenFile(diskPath)
+	part, err := Cache.Open(current.partition, diskPath, partition.Start)
 	if err != nil {
-		return fmt.Errorf("error abriendo el archivo: %v", err)
-	}
-	defer file.Close()
-
-	var sb Structs.Superblock
-	if err := Utilities.ReadObject(file, &sb, int64(partition.Start)); err != nil {
-		return fmt.Errorf("error leyendo el Superblock: %v", err)
+		return Results.Errorf(Results.IOError, "error abriendo el archivo: %v", err)
 	}
 
-	indexInode := InitSearch("/users.txt", file, sb)
+	indexInode := InitSearch("/users.txt", part)
 	if indexInode < 0 {
-		return fmt.Errorf("no se encontró el archivo users.txt")
+		return Results.Errorf(Results.NotFound, "no se encontró el archivo users.txt")
 	}
-	inodeOffset := int64(sb.S_inode_start) + int64(indexInode)*int64(binary.Size(Structs.Inode{}))
 
-	var usersInode Structs.Inode
-	if err := Utilities.ReadObject(file, &usersInode, inodeOffset); err != nil {
-		return fmt.Errorf("error leyendo el inodo de users.txt: %v", err)
+	usersInode, err := part.ReadInode(indexInode)
+	if err != nil {
+		return Results.Errorf(Results.IOError, "error leyendo el inodo de users.txt: %v", err)
 	}
 
-	data := GetInodeFileData(usersInode, file, sb)
+	data := GetInodeFileData(*usersInode, part)
 	lines := strings.Split(data, "\n")
 	found := false
 
@@ -851,7 +844,7 @@ func Rmgrp(name string) error {
 		tokens := strings.Split(strings.TrimSpace(line), ",")
 		if len(tokens) >= 3 && strings.TrimSpace(tokens[1]) == "G" && strings.TrimSpace(tokens[2]) == name {
 			if strings.TrimSpace(tokens[0]) == "0" {
-				return fmt.Errorf("el grupo ya fue eliminado")
+				return Results.Errorf(Results.AlreadyExists, "el grupo ya fue eliminado")
 			}
 			tokens[0] = "0"
 			lines[i] = strings.Join(tokens, ",")
@@ -861,63 +854,58 @@ func Rmgrp(name string) error {
 	}
 
 	if !found {
-		return fmt.Errorf("el grupo no existe")
+		return Results.Errorf(Results.NotFound, "el grupo no existe")
 	}
 
 	newContent := strings.Join(lines, "\n")
-	if err := MultiBlockUpdate(&usersInode, newContent, file, sb, inodeOffset, int64(partition.Start)); err != nil {
+	if err := MultiBlockUpdate(usersInode, newContent, part, indexInode); err != nil {
 
-		return fmt.Errorf("error actualizando users.txt: %v", err)
+		return Results.Errorf(Results.IOError, "error actualizando users.txt: %v", err)
 	}
 
-	OutPut.Println("Grupo eliminado exitosamente")
-	OutPut.Println("======End RMGRP======")
+	out.Println("Grupo eliminado exitosamente")
+	out.Println("======End RMGRP======")
 	return nil
 }
 
-func Mkusr(user, pass, grp string) error {
-	OutPut.Println("======Start MKUSR======")
-	fmt.Printf("User: %s, Pass: %s, Group: %s\n", user, pass, grp)
+func Mkusr(out *OutPut.Output, user, pass, grp string) error {
+	out.Println("======Start MKUSR======")
+	out.Printf("User: %s, Pass: %s, Group: %s\n", user, pass, grp)
 
-	if !currentUser.loggedIn {
-		return fmt.Errorf("necesita iniciar sesión")
+	current := session()
+	if !current.loggedIn {
+		return Results.Errorf(Results.NotLoggedIn, "necesita iniciar sesión")
 	}
-	if currentUser.user != "root" {
-		return fmt.Errorf("solo el usuario root puede ejecutar mkusr")
+	if current.user != "root" {
+		return Results.Errorf(Results.PermissionDenied, "solo el usuario root puede ejecutar mkusr")
 	}
 
 	if len(user) > 10 || len(pass) > 10 || len(grp) > 10 {
-		return fmt.Errorf("user, pass o group exceden el máximo de 10 caracteres")
+		return Results.Errorf(Results.InvalidParams, "user, pass o group exceden el máximo de 10 caracteres")
 	}
 
-	partition, diskPath, err := stores.GetMountedPartition(currentUser.partition)
+	partition, diskPath, err := stores.GetMountedPartition(current.partition)
 	if err != nil {
-		return fmt.Errorf("error encontrando la partición: %v", err)
+		return Results.Errorf(Results.NotFound, "error encontrando la partición: %v", err)
 	}
+	defer Locks.LockPartition(diskPath, current.partition)()
 
-	file, err := Utilities.OpenFile(diskPath)
+	part, err := Cache.Open(current.partition, diskPath, partition.Start)
 	if err != nil {
-		return fmt.Errorf("error abriendo el archivo: %v", err)
+		return Results.Errorf(Results.IOError, "error abriendo el archivo: %v", err)
 	}
-	defer file.Close()
 
-	var sb Structs.Superblock
-	if err := Utilities.ReadObject(file, &sb, int64(partition.Start)); err != nil {
-		return fmt.Errorf("error leyendo el Superblock: %v", err)
-	}
-
-	indexInode := InitSearch("/users.txt", file, sb)
+	indexInode := InitSearch("/users.txt", part)
 	if indexInode < 0 {
-		return fmt.Errorf("no se encontró el archivo users.txt")
+		return Results.Errorf(Results.NotFound, "no se encontró el archivo users.txt")
 	}
-	inodeOffset := int64(sb.S_inode_start) + int64(indexInode)*int64(binary.Size(Structs.Inode{}))
 
-	var usersInode Structs.Inode
-	if err := Utilities.ReadObject(file, &usersInode, inodeOffset); err != nil {
-		return fmt.Errorf("error leyendo el inodo de users.txt: %v", err)
+	usersInode, err := part.ReadInode(indexInode)
+	if err != nil {
+		return Results.Errorf(Results.IOError, "error leyendo el inodo de users.txt: %v", err)
 	}
 
-	data := GetInodeFileData(usersInode, file, sb)
+	data := GetInodeFileData(*usersInode, part)
 	lines := strings.Split(strings.TrimRight(data, "\n"), "\n")
 
 	// Validar si ya existe el usuario
@@ -925,7 +913,7 @@ func Mkusr(user, pass, grp string) error {
 		tokens := strings.Split(strings.TrimSpace(line), ",")
 		if len(tokens) >= 5 && strings.TrimSpace(tokens[1]) == "U" {
 			if strings.TrimSpace(tokens[3]) == user {
-				return fmt.Errorf("el usuario ya existe")
+				return Results.Errorf(Results.AlreadyExists, "el usuario ya existe")
 			}
 		}
 	}
@@ -942,7 +930,7 @@ func Mkusr(user, pass, grp string) error {
 		}
 	}
 	if !groupExists {
-		return fmt.Errorf("el grupo no existe o está eliminado")
+		return Results.Errorf(Results.NotFound, "el grupo no existe o está eliminado")
 	}
 
 	// Calcular nuevo ID de usuario
@@ -960,58 +948,53 @@ func Mkusr(user, pass, grp string) error {
 	newRecord := fmt.Sprintf("%d,U,%s,%s,%s\n", newUserID, grp, user, pass)
 	newContent := strings.Join(lines, "\n") + "\n" + newRecord
 
-	if err := MultiBlockUpdate(&usersInode, newContent, file, sb, inodeOffset, int64(partition.Start)); err != nil {
-		return fmt.Errorf("error actualizando users.txt: %v", err)
+	if err := MultiBlockUpdate(usersInode, newContent, part, indexInode); err != nil {
+		return Results.Errorf(Results.IOError, "error actualizando users.txt: %v", err)
 	}
 
-	OutPut.Println("Usuario creado exitosamente.")
-	OutPut.Println("======End MKUSR======")
+	out.Println("Usuario creado exitosamente.")
+	out.Println("======End MKUSR======")
 	return nil
 }
 
-func Rmusr(username string) error {
-	OutPut.Println("======Start RMUSR======")
-	fmt.Printf("Usuario a eliminar: %s\n", username)
+func Rmusr(out *OutPut.Output, username string) error {
+	out.Println("======Start RMUSR======")
+	out.Printf("Usuario a eliminar: %s\n", username)
 
-	if !currentUser.loggedIn {
-		return fmt.Errorf("necesita iniciar sesión")
+	current := session()
+	if !current.loggedIn {
+		return Results.Errorf(Results.NotLoggedIn, "necesita iniciar sesión")
 	}
-	if currentUser.user != "root" {
-		return fmt.Errorf("solo el usuario root puede ejecutar rmusr")
+	if current.user != "root" {
+		return Results.Errorf(Results.PermissionDenied, "solo el usuario root puede ejecutar rmusr")
 	}
 
 	if len(username) > 10 {
-		return fmt.Errorf("el nombre de usuario excede los 10 caracteres")
+		return Results.Errorf(Results.InvalidParams, "el nombre de usuario excede los 10 caracteres")
 	}
 
-	partition, diskPath, err := stores.GetMountedPartition(currentUser.partition)
+	partition, diskPath, err := stores.GetMountedPartition(current.partition)
 	if err != nil {
-		return fmt.Errorf("error encontrando la partición: %v", err)
+		return Results.Errorf(Results.NotFound, "error encontrando la partición: %v", err)
 	}
+	defer Locks.LockPartition(diskPath, current.partition)()
 
-	file, err := Utilities.OpenFile(diskPath)
+	part, err := Cache.Open(current.partition, diskPath, partition.Start)
 	if err != nil {
-		return fmt.Errorf("error abriendo el archivo del disco: %v", err)
-	}
-	defer file.Close()
-
-	var sb Structs.Superblock
-	if err := Utilities.ReadObject(file, &sb, int64(partition.Start)); err != nil {
-		return fmt.Errorf("error leyendo el Superblock: %v", err)
+		return Results.Errorf(Results.IOError, "error abriendo el archivo del disco: %v", err)
 	}
 
-	indexInode := InitSearch("/users.txt", file, sb)
+	indexInode := InitSearch("/users.txt", part)
 	if indexInode < 0 {
-		return fmt.Errorf("no se encontró el archivo users.txt")
+		return Results.Errorf(Results.NotFound, "no se encontró el archivo users.txt")
 	}
-	inodeOffset := int64(sb.S_inode_start) + int64(indexInode)*int64(binary.Size(Structs.Inode{}))
 
-	var usersInode Structs.Inode
-	if err := Utilities.ReadObject(file, &usersInode, inodeOffset); err != nil {
-		return fmt.Errorf("error leyendo el inodo de users.txt: %v", err)
+	usersInode, err := part.ReadInode(indexInode)
+	if err != nil {
+		return Results.Errorf(Results.IOError, "error leyendo el inodo de users.txt: %v", err)
 	}
 
-	data := GetInodeFileData(usersInode, file, sb)
+	data := GetInodeFileData(*usersInode, part)
 	lines := strings.Split(data, "\n")
 	found := false
 
@@ -1019,7 +1002,7 @@ func Rmusr(username string) error {
 		tokens := strings.Split(strings.TrimSpace(line), ",")
 		if len(tokens) >= 5 && strings.TrimSpace(tokens[1]) == "U" && strings.TrimSpace(tokens[3]) == username {
 			if strings.TrimSpace(tokens[0]) == "0" {
-				return fmt.Errorf("el usuario ya está eliminado")
+				return Results.Errorf(Results.AlreadyExists, "el usuario ya está eliminado")
 			}
 			tokens[0] = "0"
 			lines[i] = strings.Join(tokens, ",")
@@ -1029,25 +1012,25 @@ func Rmusr(username string) error {
 	}
 
 	if !found {
-		return fmt.Errorf("el usuario no existe")
+		return Results.Errorf(Results.NotFound, "el usuario no existe")
 	}
 
 	newContent := strings.Join(lines, "\n")
-	if err := MultiBlockUpdate(&usersInode, newContent, file, sb, inodeOffset, int64(partition.Start)); err != nil {
-		return fmt.Errorf("error actualizando users.txt: %v", err)
+	if err := MultiBlockUpdate(usersInode, newContent, part, indexInode); err != nil {
+		return Results.Errorf(Results.IOError, "error actualizando users.txt: %v", err)
 	}
 
-	OutPut.Println("Usuario eliminado correctamente")
-	OutPut.Println("======End RMUSR======")
+	out.Println("Usuario eliminado correctamente")
+	out.Println("======End RMUSR======")
 	return nil
 }
 
-func Mkfs(id string, type_ string, fs string) error {
-	OutPut.Println("======Start MKFS======")
-	OutPut.Println("ID:", id, "Type:", type_, "FS:", fs)
+func Mkfs(out *OutPut.Output, id string, type_ string, fs string, confirm bool) error {
+	out.Println("======Start MKFS======")
+	out.Println("ID:", id, "Type:", type_, "FS:", fs)
 
 	if type_ != "FULL" {
-		return fmt.Errorf("type must be FULL")
+		return Results.Errorf(Results.InvalidParams, "type must be FULL")
 	}
 	if fs != "2FS" && fs != "3FS" {
 		fs = "2FS"
@@ -1055,19 +1038,29 @@ func Mkfs(id string, type_ string, fs string) error {
 
 	partition, diskPath, err := stores.GetMountedPartition(id)
 	if err != nil {
-		return fmt.Errorf("error retrieving mounted partition: %v", err)
+		return Results.Errorf(Results.NotFound, "error retrieving mounted partition: %v", err)
 	}
 	if partition == nil {
-		return fmt.Errorf("partition with ID %s not found", id)
+		return Results.Errorf(Results.NotFound, "partition with ID %s not found", id)
 	}
-	fmt.Printf("Partition found: Name=%s, Size=%d, Start=%d\n", strings.Trim(string(partition.Name[:]), "\x00"), partition.Size, partition.Start)
+	defer Locks.LockPartition(diskPath, id)()
+	out.Printf("Partition found: Name=%s, Size=%d, Start=%d\n", strings.Trim(string(partition.Name[:]), "\x00"), partition.Size, partition.Start)
 
 	file, err := Utilities.OpenFile(diskPath)
 	if err != nil {
-		return fmt.Errorf("error opening file %s: %v", diskPath, err)
+		return Results.Errorf(Results.IOError, "error opening file %s: %v", diskPath, err)
 	}
 	defer file.Close()
 
+	// Reformatear borra los datos, por eso se pide confirmación si ya hay un sistema de archivos
+	var current Structs.Superblock
+	if err := Utilities.ReadObject(file, &current, int64(partition.Start)); err == nil && current.S_magic == 0xEF53 && !confirm {
+		return Results.Errorf(Results.ConfirmationRequired, "La partición %s ya tiene un sistema de archivos. ¿Desea formatearla y perder todos sus datos?", id)
+	}
+
+	// El formateo reescribe la partición completa, la caché anterior ya no es válida
+	Cache.Invalidate(id)
+
 	// Create superblock
 	var superblock Structs.Superblock
 	minSize := int64(binary.Size(Structs.Superblock{})) + 4 + int64(binary.Size(Structs.Inode{})) + 3*int64(binary.Size(Structs.Fileblock{}))
@@ -1076,7 +1069,7 @@ func Mkfs(id string, type_ string, fs string) error {
 		minSize += int64(binary.Size(Structs.Journaling{}))
 	}
 	if int64(partition.Size) < minSize {
-		return fmt.Errorf("partition size too small to format: Size=%d bytes, Minimum required=%d bytes", partition.Size, minSize)
+		return Results.Errorf(Results.InvalidParams, "partition size too small to format: Size=%d bytes, Minimum required=%d bytes", partition.Size, minSize)
 	}
 
 	n := int32((int32(partition.Size) - int32(binary.Size(Structs.Superblock{}))) / (4 + int32(binary.Size(Structs.Inode{})) + 3*int32(binary.Size(Structs.Fileblock{}))))
@@ -1085,9 +1078,9 @@ func Mkfs(id string, type_ string, fs string) error {
 	}
 	//n = n / 4
 	if n <= 0 {
-		return fmt.Errorf("partition size too small to create inodes: Size=%d bytes, Minimum required=%d bytes", partition.Size, minSize*4)
+		return Results.Errorf(Results.InvalidParams, "partition size too small to create inodes: Size=%d bytes, Minimum required=%d bytes", partition.Size, minSize*4)
 	}
-	fmt.Printf("Calculated inodes: n=%d\n", n)
+	out.Printf("Calculated inodes: n=%d\n", n)
 
 	superblock.S_filesystem_type = 2
 	if fs == "3FS" {
@@ -1114,17 +1107,20 @@ func Mkfs(id string, type_ string, fs string) error {
 	superblock.S_first_blo = 0
 
 	if err := Utilities.WriteObject(file, superblock, int64(partition.Start)); err != nil {
-		return fmt.Errorf("error writing superblock: %v", err)
+		return Results.Errorf(Results.IOError, "error writing superblock: %v", err)
 	}
 
 	// Write bitmaps
 	bitmapInodes := make([]byte, n)
 	bitmapBlocks := make([]byte, 3*n)
+	// El inodo y el bloque 0 son de la raíz y los 1 de users.txt (CreateRootAndUsersFile)
+	bitmapInodes[0], bitmapInodes[1] = 1, 1
+	bitmapBlocks[0], bitmapBlocks[1] = 1, 1
 	if err := Utilities.WriteObject(file, bitmapInodes, int64(superblock.S_bm_inode_start)); err != nil {
-		return fmt.Errorf("error writing inode bitmap: %v", err)
+		return Results.Errorf(Results.IOError, "error writing inode bitmap: %v", err)
 	}
 	if err := Utilities.WriteObject(file, bitmapBlocks, int64(superblock.S_bm_block_start)); err != nil {
-		return fmt.Errorf("error writing block bitmap: %v", err)
+		return Results.Errorf(Results.IOError, "error writing block bitmap: %v", err)
 	}
 
 	// Initialize inodes and blocks
@@ -1132,172 +1128,172 @@ func Mkfs(id string, type_ string, fs string) error {
 		var inode Structs.Inode
 		inode.I_block = [15]int32{-1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1}
 		if err := Utilities.WriteObject(file, inode, int64(superblock.S_inode_start+i*superblock.S_inode_size)); err != nil {
-			return fmt.Errorf("error writing inode %d: %v", i, err)
+			return Results.Errorf(Results.IOError, "error writing inode %d: %v", i, err)
 		}
 	}
 	for i := int32(0); i < 3*n; i++ {
 		var block Structs.Fileblock
 		if err := Utilities.WriteObject(file, block, int64(superblock.S_block_start+i*superblock.S_block_size)); err != nil {
-			return fmt.Errorf("error writing block %d: %v", i, err)
+			return Results.Errorf(Results.IOError, "error writing block %d: %v", i, err)
 		}
 	}
 
 	// Crea root directory y users.txt
-	if err := CreateRootAndUsersFile(superblock, time.Now().Format("2006-01-02 15:04:05"), file); err != nil {
-		return fmt.Errorf("error creating root and users file: %v", err)
+	if err := CreateRootAndUsersFile(superblock, time.Now().Format("2006-01-02 15:04:05"), file, int64(partition.Start)); err != nil {
+		return Results.Errorf(Results.IOError, "error creating root and users file: %v", err)
 	}
 
-	OutPut.Println("Partition formatted successfully")
-	OutPut.Println("======End MKFS======")
+	out.Println("Partition formatted successfully")
+	out.Println("======End MKFS======")
 	return nil
 }
 
-func Mkfile(path string, createParents bool, size int, cont string) string {
+func Mkfile(out *OutPut.Output, path string, createParents bool, size int, cont string, confirm bool) error {
+	// Determinar el contenido a escribir.
+	var fileContent string
+	if strings.TrimSpace(cont) != "" {
+		bytes, err := os.ReadFile(cont)
+		if err != nil {
+			return Results.Errorf(Results.IOError, "No se pudo leer el archivo de contenido (%s): %v", cont, err)
+		}
+		fileContent = string(bytes)
+	} else if size > 0 {
+		var sbuilder strings.Builder
+		digits := "0123456789"
+		for sbuilder.Len() < size {
+			sbuilder.WriteString(digits)
+		}
+		fileContent = sbuilder.String()[:size]
+	} else {
+		fileContent = ""
+	}
+
+	_, err := writeFile(out, path, createParents, fileContent, confirm)
+	return err
+}
+
+// WriteFile crea el archivo path de la partición de la sesión con content, o
+// reemplaza su contenido si ya existe. Es lo que hace mkfile con el contenido en
+// memoria en lugar de -size o -cont. Retorna los datos del archivo y si se creó.
+func WriteFile(out *OutPut.Output, path string, content string, createParents bool) (PathInfo, bool, error) {
+	created, err := writeFile(out, path, createParents, content, true)
+	if err != nil {
+		return PathInfo{}, false, err
+	}
+	// Los datos se retornan aunque el usuario no tenga permiso de lectura sobre el archivo.
+	info, err := stat(normalizePath(path), false)
+	return info, created, err
+}
+
+// writeFile escribe fileContent en path; un archivo existente solo se sobrescribe
+// con confirm. Retorna si el archivo se creó.
+func writeFile(out *OutPut.Output, path string, createParents bool, fileContent string, confirm bool) (bool, error) {
 	// Verificar sesión.
+	current := session()
 	currentPartition := GetCurrentSessionPartition()
 	if currentPartition == nil {
-		return "Error: Necesita iniciar sesión"
+		return false, Results.Errorf(Results.NotLoggedIn, "Necesita iniciar sesión")
 	}
+	defer Locks.LockPartition(currentPartition.Path, currentPartition.ID)()
 
 	path = normalizePath(path)
 
 	lastSlash := strings.LastIndex(path, "/")
 	if lastSlash < 0 {
-		return "Error: Ruta inválida"
+		return false, Results.Errorf(Results.InvalidParams, "Ruta inválida")
 	}
 	parentPath := path[:lastSlash]
 	fileName := path[lastSlash+1:]
 	if fileName == "" {
-		return "Error: No se especificó el nombre del archivo"
+		return false, Results.Errorf(Results.InvalidParams, "No se especificó el nombre del archivo")
 	}
 
 	// Buscar o crear la carpeta padre.
 	parentIndex := SearchPath(parentPath, createParents, currentPartition)
 	if parentIndex < 0 {
-		return fmt.Sprintf("Error: La carpeta padre '%s' no existe", parentPath)
+		return false, Results.Errorf(Results.NotFound, "La carpeta padre '%s' no existe", parentPath)
 	}
 
-	// Abrir el disco.
-	diskFile, err := Utilities.OpenFile(currentPartition.Path)
+	// Obtener la caché de la partición.
+	part, err := partitionCache(currentPartition)
 	if err != nil {
-		return fmt.Sprintf("Error: No se pudo abrir el disco: %v", err)
-	}
-	defer diskFile.Close()
-
-	// Leer MBR y Superblock.
-	var TempMBR Structs.MRB
-	if err := Utilities.ReadObject(diskFile, &TempMBR, 0); err != nil {
-		return fmt.Sprintf("Error: No se pudo leer el MBR: %v", err)
-	}
-	var partIdx int = -1
-	for i := 0; i < 4; i++ {
-		if strings.Contains(string(TempMBR.Partitions[i].Id[:]), currentPartition.ID) {
-			partIdx = i
-			break
-		}
-	}
-	if partIdx == -1 {
-		return "Error: Partición no encontrada"
-	}
-	var sbSuper Structs.Superblock
-	if err := Utilities.ReadObject(diskFile, &sbSuper, int64(TempMBR.Partitions[partIdx].Start)); err != nil {
-		return fmt.Sprintf("Error al leer el Superblock: %v", err)
+		return false, Results.Errorf(Results.IOError, "No se pudo abrir la partición: %v", err)
 	}
 
 	// Verificar permiso de escritura en la carpeta padre.
-	parentInode, _ := GetInodeFromPath(parentPath, diskFile, sbSuper)
+	parentInode, _ := GetInodeFromPath(parentPath, part)
 	if parentInode == nil {
-		return fmt.Sprintf("Error: La carpeta padre '%s' no existe", parentPath)
+		return false, Results.Errorf(Results.NotFound, "La carpeta padre '%s' no existe", parentPath)
 	}
-	if !hasWritePermission(*parentInode, currentUser.user) {
-		return "Error: No tiene permiso de escritura en la carpeta padre"
+	if !hasWritePermission(*parentInode, current.user) {
+		return false, Results.Errorf(Results.PermissionDenied, "No tiene permiso de escritura en la carpeta padre")
 	}
 
-	// Verificar si el archivo ya existe en la carpeta padre.
-	if EntryExistsInFolder(*parentInode, diskFile, sbSuper, fileName) {
-		return "Error: El archivo ya existe. No se permite sobreescribir."
+	// Verificar si el archivo ya existe en la carpeta padre. Solo se sobrescribe con confirmación.
+	var existingInode *Structs.Inode
+	existingIndex := -1
+	if EntryExistsInFolder(*parentInode, part, fileName) {
+		if !confirm {
+			return false, Results.Errorf(Results.ConfirmationRequired, "El archivo %s ya existe. ¿Desea sobrescribirlo?", path)
+		}
+		existingInode, existingIndex = GetInodeFromPath(path, part)
+		if existingInode == nil || existingInode.I_type[0] != '1' {
+			return false, Results.Errorf(Results.AlreadyExists, "Ya existe una carpeta con el nombre %s", fileName)
+		}
+		if !hasWritePermission(*existingInode, current.user) {
+			return false, Results.Errorf(Results.PermissionDenied, "No tiene permiso de escritura sobre %s", path)
+		}
 	}
 
-	// Determinar el contenido a escribir.
-	var fileContent string
-	if strings.TrimSpace(cont) != "" {
-		bytes, err := os.ReadFile(cont)
-		if err != nil {
-			return fmt.Sprintf("Error: No se pudo leer el archivo de contenido (%s): %v", cont, err)
-		}
-		fileContent = string(bytes)
-	} else if size > 0 {
-		var sbuilder strings.Builder
-		digits := "0123456789"
-		for sbuilder.Len() < size {
-			sbuilder.WriteString(digits)
+	if existingInode != nil {
+		if err := MultiBlockUpdateFile(existingInode, fileContent, part, existingIndex); err != nil {
+			return false, Results.Errorf(Results.IOError, "Error al escribir el archivo: %v", err)
 		}
-		fileContent = sbuilder.String()[:size]
-	} else {
-		fileContent = ""
+		out.Println("Archivo sobrescrito con éxito")
+		return false, nil
 	}
 
-	perm := "664"             // permisos por defecto
-	owner := currentUser.user // Puedes buscar el propietario real si lo necesitas
-	group := "default"        // Puedes buscar el grupo real si lo necesitas
+	perm := "664"         // permisos por defecto
+	owner := current.user // Puedes buscar el propietario real si lo necesitas
+	group := "default"    // Puedes buscar el grupo real si lo necesitas
 
 	// Asignar un nuevo inodo para el archivo.
-	newFileInode, newInodeOffset, newFileIndex, err := allocateInode(diskFile, sbSuper, owner, group, perm, false)
+	newFileInode, newFileIndex, err := allocateInode(part, owner, group, perm, false)
 	if err != nil {
-		return fmt.Sprintf("Error al asignar un nuevo inodo: %v", err)
+		return false, Results.Errorf(Results.NoSpace, "Error al asignar un nuevo inodo: %v", err)
 	}
-	fmt.Printf("MKFILE: Nuevo inodo asignado: índice %d, offset %d\n", newFileIndex, newInodeOffset)
+	out.Printf("MKFILE: Nuevo inodo asignado: índice %d\n", newFileIndex)
 
 	// Escribir el contenido en múltiples bloques, usando apuntadores directos e indirectos.
-	if err := MultiBlockUpdateFile(newFileInode, fileContent, diskFile, sbSuper, newInodeOffset); err != nil {
-		return fmt.Sprintf("Error al escribir el archivo: %v", err)
+	if err := MultiBlockUpdateFile(newFileInode, fileContent, part, newFileIndex); err != nil {
+		return false, Results.Errorf(Results.IOError, "Error al escribir el archivo: %v", err)
 	}
 
 	// Agregar una entrada en la carpeta padre.
-	if err := AddEntryToFolderByIndex(parentIndex, diskFile, sbSuper, fileName, newFileIndex); err != nil {
-		return fmt.Sprintf("Error al agregar la entrada en la carpeta padre: %v", err)
+	if err := AddEntryToFolderByIndex(parentIndex, part, fileName, newFileIndex); err != nil {
+		return false, Results.Errorf(Results.Failed, "Error al agregar la entrada en la carpeta padre: %v", err)
 	}
 
-	return "Archivo creado con éxito"
+	out.Println("Archivo creado con éxito")
+	return true, nil
 }
 
-func Cat(params map[string]string) string {
+func Cat(params map[string]string) (string, error) {
 	// Verificar que exista una sesión activa.
+	current := session()
 	currentPartition := GetCurrentSessionPartition()
 	if currentPartition == nil {
-		return "Error: Necesita iniciar sesión"
+		return "", Results.Errorf(Results.NotLoggedIn, "Necesita iniciar sesión")
 	}
-	if currentUser.user == "" {
-		return "Error: No se encontró un usuario logueado"
+	defer Locks.RLockPartition(currentPartition.Path, currentPartition.ID)()
+	if current.user == "" {
+		return "", Results.Errorf(Results.NotLoggedIn, "No se encontró un usuario logueado")
 	}
 
-	// Abrir el disco de la partición activa.
-	file, err := Utilities.OpenFile(currentPartition.Path)
+	// Obtener la caché de la partición activa.
+	part, err := partitionCache(currentPartition)
 	if err != nil {
-		return fmt.Sprintf("Error: No se pudo abrir el archivo: %v", err)
-	}
-	defer file.Close()
-
-	// Leer el MBR y localizar la partición activa.
-	var TempMBR Structs.MRB
-	if err := Utilities.ReadObject(file, &TempMBR, 0); err != nil {
-		return fmt.Sprintf("Error: No se pudo leer el MBR: %v", err)
-	}
-	var partIndex int = -1
-	for i := 0; i < 4; i++ {
-		if strings.Contains(string(TempMBR.Partitions[i].Id[:]), currentPartition.ID) {
-			partIndex = i
-			break
-		}
-	}
-	if partIndex == -1 {
-		return "Error: Partición no encontrada"
-	}
-
-	// Leer el Superblock.
-	var sb Structs.Superblock
-	if err := Utilities.ReadObject(file, &sb, int64(TempMBR.Partitions[partIndex].Start)); err != nil {
-		return fmt.Sprintf("Error al leer el Superblock: %v", err)
+		return "", Results.Errorf(Results.IOError, "No se pudo abrir la partición: %v", err)
 	}
 
 	// Ordenar las claves de los parámetros (file1, file2, etc.) en orden ascendente.
@@ -1325,166 +1321,643 @@ func Cat(params map[string]string) string {
 		}
 
 		// Buscar el inodo del archivo usando InitSearch.
-		indexInode := InitSearch(path, file, sb)
+		indexInode := InitSearch(path, part)
 		if indexInode < 0 {
-			return fmt.Sprintf("Error: El archivo %s no existe", path)
+			return "", Results.Errorf(Results.NotFound, "El archivo %s no existe", path)
 		}
 
-		// Calcular el offset del inodo.
-		inodeOffset := int64(sb.S_inode_start) + int64(indexInode)*int64(binary.Size(Structs.Inode{}))
-		var fileInode Structs.Inode
-		if err := Utilities.ReadObject(file, &fileInode, inodeOffset); err != nil {
-			return fmt.Sprintf("Error al leer el inodo del archivo %s: %v", path, err)
+		// Leer el inodo del archivo.
+		fileInode, err := part.ReadInode(indexInode)
+		if err != nil {
+			return "", Results.Errorf(Results.IOError, "Error al leer el inodo del archivo %s: %v", path, err)
 		}
 
 		// Verificar permiso de lectura.
-		if !hasReadPermission(fileInode, currentUser.user) {
-			return fmt.Sprintf("Error: No tiene permiso de lectura para el archivo %s", path)
+		if !hasReadPermission(*fileInode, current.user) {
+			return "", Results.Errorf(Results.PermissionDenied, "No tiene permiso de lectura para el archivo %s", path)
 		}
 
 		// Obtener el contenido del archivo.
-		data := GetInodeFileData(fileInode, file, sb)
+		data := GetInodeFileData(*fileInode, part)
 		outputBuilder.WriteString(data)
 		outputBuilder.WriteString("\n")
 	}
 
-	return outputBuilder.String()
+	return outputBuilder.String(), nil
 }
 
-func Mkdir(path string, createParents bool) string {
+func Mkdir(out *OutPut.Output, path string, createParents bool) error {
+	current := session()
 	currentPartition := GetCurrentSessionPartition()
 	if currentPartition == nil {
-		return "Error: Necesita iniciar sesión"
+		return Results.Errorf(Results.NotLoggedIn, "Necesita iniciar sesión")
 	}
+	defer Locks.LockPartition(currentPartition.Path, currentPartition.ID)()
 	if !strings.HasPrefix(path, "/") {
-		return "Error: La ruta debe comenzar con '/'"
+		return Results.Errorf(Results.InvalidParams, "La ruta debe comenzar con '/'")
 	}
 	lastSlash := strings.LastIndex(path, "/")
 	if lastSlash < 0 {
-		return "Error: Ruta inválida"
+		return Results.Errorf(Results.InvalidParams, "Ruta inválida")
 	}
 	parentPath := path[:lastSlash]
 	folderName := path[lastSlash+1:]
 	if folderName == "" {
-		return "Error: No se especificó el nombre de la carpeta"
+		return Results.Errorf(Results.InvalidParams, "No se especificó el nombre de la carpeta")
 	}
 
 	// Buscar o crear la carpeta padre.
 	parentIndex := SearchPath(parentPath, createParents, currentPartition)
 	if parentIndex < 0 {
-		return fmt.Sprintf("Error: La carpeta padre '%s' no existe", parentPath)
+		return Results.Errorf(Results.NotFound, "La carpeta padre '%s' no existe", parentPath)
 	}
 
-	// Abrir el disco.
-	diskFile, err := Utilities.OpenFile(currentPartition.Path)
+	// Obtener la caché de la partición.
+	part, err := partitionCache(currentPartition)
 	if err != nil {
-		return fmt.Sprintf("Error: No se pudo abrir el disco: %v", err)
-	}
-	defer diskFile.Close()
-
-	// Leer MBR y Superblock.
-	var TempMBR Structs.MRB
-	if err := Utilities.ReadObject(diskFile, &TempMBR, 0); err != nil {
-		return fmt.Sprintf("Error: No se pudo leer el MBR: %v", err)
-	}
-	var partIdx int = -1
-	for i := 0; i < 4; i++ {
-		if strings.Contains(string(TempMBR.Partitions[i].Id[:]), currentPartition.ID) {
-			partIdx = i
-			break
-		}
-	}
-	if partIdx == -1 {
-		return "Error: Partición no encontrada"
-	}
-	var sbSuper Structs.Superblock
-	if err := Utilities.ReadObject(diskFile, &sbSuper, int64(TempMBR.Partitions[partIdx].Start)); err != nil {
-		return fmt.Sprintf("Error al leer el Superblock: %v", err)
+		return Results.Errorf(Results.IOError, "No se pudo abrir la partición: %v", err)
 	}
 
 	// Verificar que la carpeta padre exista y tenga permiso de escritura.
-	parentInode, _ := GetInodeFromPath(parentPath, diskFile, sbSuper)
+	parentInode, _ := GetInodeFromPath(parentPath, part)
 	if parentInode == nil {
-		return fmt.Sprintf("Error: La carpeta padre '%s' no existe", parentPath)
+		return Results.Errorf(Results.NotFound, "La carpeta padre '%s' no existe", parentPath)
 	}
-	if !hasWritePermission(*parentInode, currentUser.user) {
-		return "Error: No tiene permiso de escritura en la carpeta padre"
+	if !hasWritePermission(*parentInode, current.user) {
+		return Results.Errorf(Results.PermissionDenied, "No tiene permiso de escritura en la carpeta padre")
 	}
 
 	// Verificar si la carpeta ya existe en la carpeta padre.
-	if EntryExistsInFolder(*parentInode, diskFile, sbSuper, folderName) {
-		return "Error: La carpeta ya existe"
+	if EntryExistsInFolder(*parentInode, part, folderName) {
+		return Results.Errorf(Results.AlreadyExists, "La carpeta ya existe")
 	}
 
 	// Asignar un nuevo inodo para la carpeta (indicando que es directorio).
-	newFolderInode, newInodeOffset, newFolderIndex, err := allocateInode(diskFile, sbSuper, currentUser.user, "default", "664", true)
+	newFolderInode, newFolderIndex, err := allocateInode(part, current.user, "default", "664", true)
 	if err != nil {
-		return fmt.Sprintf("Error al asignar un nuevo inodo: %v", err)
+		return Results.Errorf(Results.NoSpace, "Error al asignar un nuevo inodo: %v", err)
 	}
-	fmt.Printf("MKDIR: Nuevo inodo asignado: índice %d, offset %d\n", newFolderIndex, newInodeOffset)
+	out.Printf("MKDIR: Nuevo inodo asignado: índice %d\n", newFolderIndex)
 
 	// Inicializar la carpeta con entradas "." y "..". Se usa el índice del padre (parentIndex).
 	if err := InitializeFolder(newFolderInode, parentInode, newFolderIndex, parentIndex); err != nil {
-		return fmt.Sprintf("Error al inicializar la carpeta: %v", err)
+		return Results.Errorf(Results.Failed, "Error al inicializar la carpeta: %v", err)
 	}
 
 	// Escribir el contenido inicial (vacío) en la carpeta.
-	if err := MultiBlockUpdateFile(newFolderInode, "", diskFile, sbSuper, newInodeOffset); err != nil {
-		return fmt.Sprintf("Error al escribir la carpeta: %v", err)
+	if err := MultiBlockUpdateFile(newFolderInode, "", part, newFolderIndex); err != nil {
+		return Results.Errorf(Results.IOError, "Error al escribir la carpeta: %v", err)
 	}
 
 	// Agregar una entrada en la carpeta padre.
-	if err := AddEntryToFolderByIndex(parentIndex, diskFile, sbSuper, folderName, newFolderIndex); err != nil {
-		return fmt.Sprintf("Error al agregar la entrada en la carpeta padre: %v", err)
+	if err := AddEntryToFolderByIndex(parentIndex, part, folderName, newFolderIndex); err != nil {
+		return Results.Errorf(Results.Failed, "Error al agregar la entrada en la carpeta padre: %v", err)
 	}
 
-	return "Carpeta creada con éxito"
+	out.Println("Carpeta creada con éxito")
+	return nil
 }
 
-func ReportFile(id string, outputPath string, filePath string) error {
-	// Obtener la ruta del disco usando el id de la partición
-	partitionPath := DiskManagement.GetPartitionPathByID(id)
-	if partitionPath == "" {
-		return fmt.Errorf("no se encontró la ruta para el id: %s", id)
+// PathInfo son los datos de un archivo o carpeta de la partición de la sesión. Las
+// carpetas incluyen sus entradas.
+type PathInfo struct {
+	Path string `json:"path"`
+	LsEntry
+	Perm    string    `json:"perm"` // formato numérico, por ejemplo 664
+	Entries []LsEntry `json:"entries,omitempty"`
+}
+
+// CurrentSession retorna el usuario y la partición de la sesión actual y si hay una
+// sesión iniciada
+func CurrentSession() (string, string, bool) {
+	current := session()
+	return current.user, current.partition, current.loggedIn
+}
+
+// sessionPartition retorna la partición de la sesión actual
+func sessionPartition() (*DiskManagement.MountedPartition, error) {
+	currentPartition := GetCurrentSessionPartition()
+	if currentPartition == nil || !session().loggedIn {
+		return nil, Results.Errorf(Results.NotLoggedIn, "Necesita iniciar sesión")
+	}
+	return currentPartition, nil
+}
+
+// cleanPath valida que path sea absoluta y le quita la barra final
+func cleanPath(path string) (string, error) {
+	if !strings.HasPrefix(path, "/") {
+		return "", Results.Errorf(Results.InvalidParams, "La ruta debe comenzar con '/'")
+	}
+	if path != "/" {
+		path = strings.TrimRight(path, "/")
+		if path == "" {
+			path = "/"
+		}
+	}
+	return path, nil
+}
+
+// splitPath separa path en la ruta de la carpeta padre y el nombre
+func splitPath(path string) (string, string) {
+	lastSlash := strings.LastIndex(path, "/")
+	parentPath := path[:lastSlash]
+	if parentPath == "" {
+		parentPath = "/"
 	}
+	return parentPath, path[lastSlash+1:]
+}
 
-	// Abrir el archivo del disco
-	file, err := Utilities.OpenFile(partitionPath)
+// validName valida un nombre de archivo o carpeta: cabe en un Content y no es una ruta
+func validName(name string) error {
+	switch {
+	case name == "" || name == "." || name == "..":
+		return Results.Errorf(Results.InvalidParams, "Nombre inválido: '%s'", name)
+	case strings.Contains(name, "/"):
+		return Results.Errorf(Results.InvalidParams, "El nombre no puede contener '/'")
+	case len(name) > len(Structs.Content{}.B_name):
+		return Results.Errorf(Results.InvalidParams, "El nombre excede los %d caracteres", len(Structs.Content{}.B_name))
+	}
+	return nil
+}
+
+// lookupUser busca en users.txt el UID del usuario name y el GID de su grupo
+func lookupUser(part *Cache.Partition, name string) (int32, int32, bool) {
+	usersInode, err := part.ReadInode(InitSearch("/users.txt", part))
 	if err != nil {
-		return fmt.Errorf("error abriendo el disco: %v", err)
+		return 0, 0, false
 	}
-	defer file.Close()
+	uid, group := int32(-1), ""
+	groups := map[string]int32{}
+	for _, line := range strings.Split(GetInodeFileData(*usersInode, part), "\n") {
+		tokens := strings.Split(strings.TrimSpace(line), ",")
+		for i := range tokens {
+			tokens[i] = strings.TrimSpace(tokens[i])
+		}
+		if len(tokens) < 3 || tokens[0] == "0" {
+			continue
+		}
+		id, err := strconv.Atoi(tokens[0])
+		if err != nil {
+			continue
+		}
+		switch {
+		case tokens[1] == "G":
+			groups[tokens[2]] = int32(id)
+		case tokens[1] == "U" && len(tokens) >= 5 && tokens[3] == name:
+			uid, group = int32(id), tokens[2]
+		}
+	}
+	gid, ok := groups[group]
+	if uid < 0 || !ok {
+		return 0, 0, false
+	}
+	return uid, gid, true
+}
+
+// isOwner indica si user es el propietario del inodo. root siempre lo es.
+func isOwner(part *Cache.Partition, inode Structs.Inode, user string) bool {
+	if user == "root" {
+		return true
+	}
+	uid, _, ok := lookupUser(part, user)
+	return ok && uid == inode.I_uid
+}
+
+// touch actualiza la fecha de modificación del inodo
+func touch(inode *Structs.Inode) {
+	copy(inode.I_mtime[:], time.Now().Format("02/01/2006 15:04"))
+}
+
+// Stat retorna los datos del archivo o carpeta path de la partición de la sesión
+func Stat(path string) (PathInfo, error) {
+	return stat(path, true)
+}
+
+// stat es Stat; sin checkRead no se verifica el permiso de lectura, para describir
+// lo que el usuario acaba de escribir aunque no pueda leerlo
+func stat(path string, checkRead bool) (PathInfo, error) {
+	current := session()
+	currentPartition, err := sessionPartition()
+	if err != nil {
+		return PathInfo{}, err
+	}
+	defer Locks.RLockPartition(currentPartition.Path, currentPartition.ID)()
+	if path, err = cleanPath(path); err != nil {
+		return PathInfo{}, err
+	}
+
+	part, err := partitionCache(currentPartition)
+	if err != nil {
+		return PathInfo{}, Results.Errorf(Results.IOError, "No se pudo abrir la partición: %v", err)
+	}
+	inode, _ := GetInodeFromPath(path, part)
+	if inode == nil {
+		return PathInfo{}, Results.Errorf(Results.NotFound, "La ruta %s no existe", path)
+	}
+	if checkRead && !hasReadPermission(*inode, current.user) {
+		return PathInfo{}, Results.Errorf(Results.PermissionDenied, "No tiene permiso de lectura sobre %s", path)
+	}
+	return pathInfo(part, path, *inode)
+}
+
+// pathInfo retorna los datos del inodo de path, con las entradas si es una carpeta
+func pathInfo(part *Cache.Partition, path string, inode Structs.Inode) (PathInfo, error) {
+	_, name := splitPath(path)
+	if path == "/" {
+		name = "/"
+	}
+	info := PathInfo{
+		Path:    path,
+		LsEntry: newLsEntry(name, inode),
+		Perm:    strings.Trim(string(inode.I_perm[:]), "\x00"),
+	}
+	if inode.I_type[0] != '0' {
+		return info, nil
+	}
+	info.Entries = []LsEntry{}
+	if inode.I_block[0] == -1 {
+		return info, nil
+	}
+
+	folder, err := ReadFolderBlock(part, inode.I_block[0])
+	if err != nil {
+		return PathInfo{}, Results.Errorf(Results.IOError, "Error al leer la carpeta %s: %v", path, err)
+	}
+	for _, entry := range folder.B_content {
+		entryName := strings.Trim(string(entry.B_name[:]), "\x00")
+		if entryName == "" || entryName == "." || entryName == ".." {
+			continue
+		}
+		if childInode := GetInodeFromPathByIndex(int(entry.B_inodo), part); childInode != nil {
+			info.Entries = append(info.Entries, newLsEntry(entryName, *childInode))
+		}
+	}
+	return info, nil
+}
+
+// ReadFile retorna el contenido del archivo path de la partición de la sesión
+func ReadFile(path string) (string, error) {
+	current := session()
+	currentPartition, err := sessionPartition()
+	if err != nil {
+		return "", err
+	}
+	defer Locks.RLockPartition(currentPartition.Path, currentPartition.ID)()
+	if path, err = cleanPath(path); err != nil {
+		return "", err
+	}
+
+	part, err := partitionCache(currentPartition)
+	if err != nil {
+		return "", Results.Errorf(Results.IOError, "No se pudo abrir la partición: %v", err)
+	}
+	inode, _ := GetInodeFromPath(path, part)
+	if inode == nil {
+		return "", Results.Errorf(Results.NotFound, "El archivo %s no existe", path)
+	}
+	if inode.I_type[0] != '1' {
+		return "", Results.Errorf(Results.InvalidParams, "%s es una carpeta", path)
+	}
+	if !hasReadPermission(*inode, current.user) {
+		return "", Results.Errorf(Results.PermissionDenied, "No tiene permiso de lectura para el archivo %s", path)
+	}
+	return GetInodeFileData(*inode, part), nil
+}
+
+// Changes son los cambios que aplica Update; los campos vacíos no se cambian
+type Changes struct {
+	Perm  string // tres dígitos de 0 a 7, como chmod -ugo
+	Owner string // usuario de users.txt, como chown -usuario
+	Name  string // nuevo nombre, como rename -name
+}
+
+// Chmod cambia los permisos (tres dígitos de 0 a 7, propietario, grupo y otros) del
+// archivo o carpeta path. Solo root o el propietario pueden cambiarlos.
+func Chmod(out *OutPut.Output, path string, perm string) error {
+	_, err := Update(out, path, Changes{Perm: perm})
+	return err
+}
+
+// Chown cambia el propietario del archivo o carpeta path al usuario user y el grupo
+// al grupo de ese usuario. Solo root o el propietario pueden cambiarlo.
+func Chown(out *OutPut.Output, path string, user string) error {
+	_, err := Update(out, path, Changes{Owner: user})
+	return err
+}
+
+// Rename cambia el nombre del archivo o carpeta path a name, dentro de la misma
+// carpeta padre
+func Rename(out *OutPut.Output, path string, name string) error {
+	_, err := Update(out, path, Changes{Name: name})
+	return err
+}
+
+// Update aplica los permisos, el propietario y el nombre de changes al archivo o
+// carpeta path, en ese orden y bajo un mismo candado. Todos los cambios se validan
+// antes de escribir: si alguno falla no se aplica ninguno. Retorna los datos del
+// archivo o carpeta con los cambios, sin verificar el permiso de lectura.
+func Update(out *OutPut.Output, path string, changes Changes) (PathInfo, error) {
+	current := session()
+	currentPartition, err := sessionPartition()
+	if err != nil {
+		return PathInfo{}, err
+	}
+	defer Locks.LockPartition(currentPartition.Path, currentPartition.ID)()
+	if path, err = cleanPath(path); err != nil {
+		return PathInfo{}, err
+	}
+	if changes.Perm != "" && (len(changes.Perm) != 3 || strings.Trim(changes.Perm, "01234567") != "") {
+		return PathInfo{}, Results.Errorf(Results.InvalidParams, "Permisos inválidos: '%s' (use tres dígitos de 0 a 7, por ejemplo 664)", changes.Perm)
+	}
+	if changes.Name != "" {
+		if path == "/" {
+			return PathInfo{}, Results.Errorf(Results.InvalidParams, "No se puede renombrar la carpeta raíz")
+		}
+		if err := validName(changes.Name); err != nil {
+			return PathInfo{}, err
+		}
+	}
+
+	part, err := partitionCache(currentPartition)
+	if err != nil {
+		return PathInfo{}, Results.Errorf(Results.IOError, "No se pudo abrir la partición: %v", err)
+	}
+	inode, index := GetInodeFromPath(path, part)
+	if inode == nil {
+		return PathInfo{}, Results.Errorf(Results.NotFound, "La ruta %s no existe", path)
+	}
+	if changes.Perm != "" && !isOwner(part, *inode, current.user) {
+		return PathInfo{}, Results.Errorf(Results.PermissionDenied, "Solo root o el propietario pueden cambiar los permisos de %s", path)
+	}
+	var uid, gid int32
+	if changes.Owner != "" {
+		if !isOwner(part, *inode, current.user) {
+			return PathInfo{}, Results.Errorf(Results.PermissionDenied, "Solo root o el propietario pueden cambiar el propietario de %s", path)
+		}
+		var ok bool
+		if uid, gid, ok = lookupUser(part, changes.Owner); !ok {
+			return PathInfo{}, Results.Errorf(Results.NotFound, "El usuario %s no existe", changes.Owner)
+		}
+	}
+
+	// Validar el nuevo nombre en la carpeta padre.
+	parentPath, oldName := splitPath(path)
+	newPath := path
+	var parentInode *Structs.Inode
+	if changes.Name != "" {
+		if parentInode, _ = GetInodeFromPath(parentPath, part); parentInode == nil {
+			return PathInfo{}, Results.Errorf(Results.NotFound, "La carpeta padre '%s' no existe", parentPath)
+		}
+		if !hasWritePermission(*parentInode, current.user) {
+			return PathInfo{}, Results.Errorf(Results.PermissionDenied, "No tiene permiso de escritura en la carpeta padre")
+		}
+		if changes.Name != oldName && EntryExistsInFolder(*parentInode, part, changes.Name) {
+			return PathInfo{}, Results.Errorf(Results.AlreadyExists, "Ya existe %s en %s", changes.Name, parentPath)
+		}
+		newPath = strings.TrimSuffix(parentPath, "/") + "/" + changes.Name
+	}
+
+	// Aplicar los cambios.
+	if changes.Perm != "" || changes.Owner != "" {
+		if changes.Perm != "" {
+			copy(inode.I_perm[:], changes.Perm)
+		}
+		if changes.Owner != "" {
+			inode.I_uid = uid
+			inode.I_gid = gid
+		}
+		touch(inode)
+		if err := part.WriteInode(int32(index), *inode); err != nil {
+			return PathInfo{}, Results.Errorf(Results.IOError, "Error al escribir el inodo: %v", err)
+		}
+		if changes.Perm != "" {
+			out.Printf("Permisos de %s cambiados a %s\n", path, changes.Perm)
+		}
+		if changes.Owner != "" {
+			out.Printf("Propietario de %s cambiado a %s\n", path, changes.Owner)
+		}
+	}
+	switch {
+	case changes.Name == "":
+	case changes.Name == oldName:
+		out.Printf("%s ya se llama %s\n", path, changes.Name)
+	default:
+		folder, err := ReadFolderBlock(part, parentInode.I_block[0])
+		if err != nil {
+			return PathInfo{}, Results.Errorf(Results.IOError, "Error al leer la carpeta padre: %v", err)
+		}
+		renamed := false
+		for i, entry := range folder.B_content {
+			if strings.Trim(string(entry.B_name[:]), "\x00") == oldName {
+				folder.B_content[i].B_name = [12]byte{}
+				copy(folder.B_content[i].B_name[:], changes.Name)
+				renamed = true
+				break
+			}
+		}
+		if !renamed {
+			return PathInfo{}, Results.Errorf(Results.NotFound, "La ruta %s no existe", path)
+		}
+		if err := part.WriteBlock(parentInode.I_block[0], folder); err != nil {
+			return PathInfo{}, Results.Errorf(Results.IOError, "Error al escribir la carpeta padre: %v", err)
+		}
+		out.Printf("%s renombrado a %s\n", path, changes.Name)
+	}
+
+	return pathInfo(part, newPath, *inode)
+}
+
+// Remove elimina el archivo o carpeta path. Una carpeta se elimina con todo su
+// contenido, siempre que el usuario tenga permiso de escritura sobre cada elemento.
+func Remove(out *OutPut.Output, path string) error {
+	current := session()
+	currentPartition, err := sessionPartition()
+	if err != nil {
+		return err
+	}
+	defer Locks.LockPartition(currentPartition.Path, currentPartition.ID)()
+	if path, err = cleanPath(path); err != nil {
+		return err
+	}
+	if path == "/" {
+		return Results.Errorf(Results.InvalidParams, "No se puede eliminar la carpeta raíz")
+	}
+
+	part, err := partitionCache(currentPartition)
+	if err != nil {
+		return Results.Errorf(Results.IOError, "No se pudo abrir la partición: %v", err)
+	}
+	parentPath, name := splitPath(path)
+	parentInode, _ := GetInodeFromPath(parentPath, part)
+	if parentInode == nil {
+		return Results.Errorf(Results.NotFound, "La carpeta padre '%s' no existe", parentPath)
+	}
+	if !hasWritePermission(*parentInode, current.user) {
+		return Results.Errorf(Results.PermissionDenied, "No tiene permiso de escritura en la carpeta padre")
+	}
+	_, index := GetInodeFromPath(path, part)
+	if index < 0 {
+		return Results.Errorf(Results.NotFound, "La ruta %s no existe", path)
+	}
+
+	// Los bloques de carpeta de los ancestros no se recorren ni se liberan: una
+	// carpeta puede compartir su bloque con la de un ancestro.
+	ancestors := []string{"/"}
+	if parentPath != "/" {
+		for i := 1; i < len(parentPath); i++ {
+			if parentPath[i] == '/' {
+				ancestors = append(ancestors, parentPath[:i])
+			}
+		}
+		ancestors = append(ancestors, parentPath)
+	}
+	protected := map[int32]bool{}
+	for _, ancestor := range ancestors {
+		if inode, _ := GetInodeFromPath(ancestor, part); inode != nil && inode.I_block[0] != -1 {
+			protected[inode.I_block[0]] = true
+		}
+	}
+
+	// Reunir los inodos a eliminar y verificar los permisos antes de modificar nada.
+	indexes := []int32{}
+	visited := map[int32]bool{}
+	var collect func(index int32, path string) error
+	collect = func(index int32, path string) error {
+		if visited[index] || index == 0 {
+			return nil
+		}
+		visited[index] = true
+		inode, err := part.ReadInode(index)
+		if err != nil {
+			return Results.Errorf(Results.IOError, "Error al leer el inodo de %s: %v", path, err)
+		}
+		if !hasWritePermission(*inode, current.user) {
+			return Results.Errorf(Results.PermissionDenied, "No tiene permiso de escritura sobre %s", path)
+		}
+		indexes = append(indexes, index)
+		if inode.I_type[0] != '0' || inode.I_block[0] == -1 || protected[inode.I_block[0]] {
+			return nil
+		}
+		folder, err := ReadFolderBlock(part, inode.I_block[0])
+		if err != nil {
+			return Results.Errorf(Results.IOError, "Error al leer la carpeta %s: %v", path, err)
+		}
+		for _, entry := range folder.B_content {
+			entryName := strings.Trim(string(entry.B_name[:]), "\x00")
+			if entryName == "" || entryName == "." || entryName == ".." {
+				continue
+			}
+			if err := collect(entry.B_inodo, path+"/"+entryName); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := collect(int32(index), path); err != nil {
+		return err
+	}
+
+	// Liberar los bloques y los inodos reunidos.
+	release := func(block int32) {
+		if block >= 0 && !protected[block] {
+			freeBlockInBitmap(part, block)
+		}
+	}
+	for _, i := range indexes {
+		inode, err := part.ReadInode(i)
+		if err != nil {
+			return Results.Errorf(Results.IOError, "Error al leer el inodo %d: %v", i, err)
+		}
+		for j := 0; j < 12; j++ {
+			release(inode.I_block[j])
+		}
+		if inode.I_type[0] == '1' && inode.I_block[12] != -1 && !protected[inode.I_block[12]] {
+			var pointers Structs.Pointerblock
+			if err := part.ReadBlock(inode.I_block[12], &pointers); err == nil {
+				for _, pointer := range pointers.B_pointers {
+					if pointer > 0 {
+						release(pointer)
+					}
+				}
+			}
+			release(inode.I_block[12])
+		}
+		if err := part.WriteInode(i, Structs.Inode{}); err != nil {
+			return Results.Errorf(Results.IOError, "Error al liberar el inodo %d: %v", i, err)
+		}
+		part.SetInodeUsed(i, false)
+	}
+
+	// Quitar la entrada de la carpeta padre.
+	folder, err := ReadFolderBlock(part, parentInode.I_block[0])
+	if err != nil {
+		return Results.Errorf(Results.IOError, "Error al leer la carpeta padre: %v", err)
+	}
+	for i, entry := range folder.B_content {
+		if strings.Trim(string(entry.B_name[:]), "\x00") == name {
+			folder.B_content[i] = Structs.Content{}
+		}
+	}
+	if err := part.WriteBlock(parentInode.I_block[0], folder); err != nil {
+		return Results.Errorf(Results.IOError, "Error al escribir la carpeta padre: %v", err)
+	}
+
+	out.Printf("%s eliminado (%d inodos liberados)\n", path, len(indexes))
+	return nil
+}
+
+// FileData son los datos del reporte file
+type FileData struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// FileReportData retorna el contenido del archivo filePath de la partición
+func FileReportData(id string, filePath string) (FileData, error) {
+	// Obtener la ruta del disco usando el id de la partición
+	partitionPath := DiskManagement.GetPartitionPathByID(id)
+	if partitionPath == "" {
+		return FileData{}, fmt.Errorf("no se encontró la ruta para el id: %s", id)
+	}
+	defer Locks.RLockPartition(partitionPath, id)()
 
 	// Obtener el inicio de la partición
 	partitionStart := DiskManagement.GetPartitionStartByID(id)
 	if partitionStart < 0 {
-		return fmt.Errorf("no se encontró la partición para el id: %s", id)
+		return FileData{}, fmt.Errorf("no se encontró la partición para el id: %s", id)
 	}
 
-	// Leer el Superblock desde el inicio de la partición
-	var sb Structs.Superblock
-	if err := Utilities.ReadObject(file, &sb, partitionStart); err != nil {
-		return fmt.Errorf("error leyendo el Superblock: %v", err)
+	// Obtener la caché de la partición (superbloque ya leído)
+	part, err := Cache.Open(id, partitionPath, partitionStart)
+	if err != nil {
+		return FileData{}, fmt.Errorf("error abriendo la partición: %v", err)
 	}
 
 	// Obtener el inodo del archivo usando su ruta en el sistema ext2
-	inode, _ := GetInodeFromPath(filePath, file, sb)
+	inode, _ := GetInodeFromPath(filePath, part)
 	if inode == nil {
-		return fmt.Errorf("no se encontró el archivo: %s", filePath)
+		return FileData{}, Results.Errorf(Results.NotFound, "no se encontró el archivo: %s", filePath)
 	}
 
 	// Obtener el contenido completo del archivo
-	content := GetInodeFileData(*inode, file, sb)
+	return FileData{Path: filePath, Content: GetInodeFileData(*inode, part)}, nil
+}
+
+func ReportFile(out *OutPut.Output, id string, outputPath string, filePath string) error {
+	data, err := FileReportData(id, filePath)
+	if err != nil {
+		return err
+	}
 
 	// Crear el reporte: se incluye el nombre del archivo y su contenido.
-	reportContent := fmt.Sprintf("Reporte de Archivo\nDirectorio: %s\n\nContenido:\n%s", filePath, content)
+	reportContent := fmt.Sprintf("Reporte de Archivo\nDirectorio: %s\n\nContenido:\n%s", data.Path, data.Content)
 
 	// Guardar el reporte en un archivo de texto en outputPath
 	if err := os.WriteFile(outputPath, []byte(reportContent), 0644); err != nil {
 		return fmt.Errorf("error escribiendo el reporte: %v", err)
 	}
 
-	fmt.Printf("Reporte generado exitosamente en: %s\n", outputPath)
+	out.Printf("Reporte generado exitosamente en: %s\n", outputPath)
 	return nil
 }
 
@@ -1536,69 +2009,68 @@ func parsePermissions(perm string, inodoType byte) string {
 	return prefixChar + ownerStr + groupStr + otherStr
 }
 
-// ReportLs genera un reporte Graphviz (DOT y PNG) que muestra la información (permisos, UID, GID, tamaño,
-// fechas de creación y modificación, tipo y nombre) de los archivos y carpetas en la ruta 'path_file_ls'
-// dentro del sistema ext2 de la partición identificada por 'id'. El reporte se guarda en 'outputPath'.
-func ReportLs(id string, outputPath string, path_file_ls string) error {
+// LsData son los datos del reporte ls: las entradas del directorio Path
+type LsData struct {
+	Path    string    `json:"path"`
+	Entries []LsEntry `json:"entries"`
+}
+
+// LsEntry es un archivo o carpeta del reporte ls
+type LsEntry struct {
+	Name         string `json:"name"`
+	Perms        string `json:"perms"` // formato simbólico, por ejemplo drwxr-xr-x
+	UID          int32  `json:"uid"`
+	GID          int32  `json:"gid"`
+	Size         int32  `json:"size"`
+	CreationDate string `json:"creationDate"`
+	ModDate      string `json:"modDate"`
+	Type         string `json:"type"` // Directorio o Archivo
+}
+
+// LsReportData retorna la información (permisos, UID, GID, tamaño, fechas de creación y
+// modificación, tipo y nombre) de los archivos y carpetas en la ruta 'path_file_ls' dentro
+// del sistema ext2 de la partición identificada por 'id'.
+func LsReportData(id string, path_file_ls string) (LsData, error) {
 	// 1. Obtener la ruta del disco a partir del id
 	partitionPath := DiskManagement.GetPartitionPathByID(id)
 	if partitionPath == "" {
-		OutPut.Println("no se encontró la ruta para el id: %s" + id)
-		return fmt.Errorf("no se encontró la ruta para el id: %s", id)
-	}
-	file, err := Utilities.OpenFile(partitionPath)
-	if err != nil {
-		OutPut.Println("error abriendo el disco: ")
-		return fmt.Errorf("error abriendo el disco: %v", err)
+		return LsData{}, fmt.Errorf("no se encontró la ruta para el id: %s", id)
 	}
-	defer file.Close()
-
+	defer Locks.RLockPartition(partitionPath, id)()
 	// 2. Obtener el inicio de la partición
 	partitionStart := DiskManagement.GetPartitionStartByID(id)
 	if partitionStart < 0 {
-		return fmt.Errorf("no se encontró la partición con el id: %s", id)
+		return LsData{}, fmt.Errorf("no se encontró la partición con el id: %s", id)
 	}
 
-	// 3. Leer el Superblock desde el inicio de la partición
-	var sb Structs.Superblock
-	if err := Utilities.ReadObject(file, &sb, partitionStart); err != nil {
-		return fmt.Errorf("error leyendo el Superblock: %v", err)
+	// 3. Obtener la caché de la partición (superbloque ya leído)
+	part, err := Cache.Open(id, partitionPath, partitionStart)
+	if err != nil {
+		return LsData{}, fmt.Errorf("error abriendo el disco: %v", err)
 	}
 
 	// 4. Obtener el inodo del directorio a listar (path_file_ls)
-	inode, _ := GetInodeFromPath(path_file_ls, file, sb)
+	inode, _ := GetInodeFromPath(path_file_ls, part)
 	if inode == nil {
-		OutPut.Println("no se encontró la ruta en el sistema ext2:" + path_file_ls)
-		return fmt.Errorf("no se encontró la ruta en el sistema ext2: %s", path_file_ls)
+		return LsData{}, Results.Errorf(Results.NotFound, "no se encontró la ruta en el sistema ext2: %s", path_file_ls)
 	}
 
 	// Verificar que el inodo corresponda a un directorio (I_type[0] == '0')
 	if inode.I_type[0] != '0' {
-		OutPut.Println("la ruta especificada no es un directorio: " + path_file_ls)
-		return fmt.Errorf("la ruta especificada no es un directorio: %s", path_file_ls)
+		return LsData{}, Results.Errorf(Results.InvalidParams, "la ruta especificada no es un directorio: %s", path_file_ls)
 	}
 
 	// 5. Leer el FolderBlock del directorio (se asume que está en I_block[0])
 	if inode.I_block[0] == -1 {
-		return fmt.Errorf("el directorio no tiene bloque asignado")
+		return LsData{}, fmt.Errorf("el directorio no tiene bloque asignado")
 	}
-	folder, err := ReadFolderBlock(file, sb, inode.I_block[0])
+	folder, err := ReadFolderBlock(part, inode.I_block[0])
 	if err != nil {
-		return fmt.Errorf("error leyendo el FolderBlock: %v", err)
+		return LsData{}, fmt.Errorf("error leyendo el FolderBlock: %v", err)
 	}
 
 	// 6. Para cada entrada (omitimos "." y ".."), obtener su información (inodo y datos relevantes)
-	type EntryInfo struct {
-		Name         string
-		Perms        string
-		UID          int32
-		GID          int32
-		Size         int32
-		CreationDate string
-		ModDate      string
-		Type         string
-	}
-	var entries []EntryInfo
+	entries := []LsEntry{}
 
 	for _, entry := range folder.B_content {
 		entryName := strings.Trim(string(entry.B_name[:]), "\x00")
@@ -1606,84 +2078,93 @@ func ReportLs(id string, outputPath string, path_file_ls string) error {
 			continue
 		}
 		childIndex := int(entry.B_inodo)
-		childInode, _ := GetInodeFromPathByIndex(childIndex, file, sb)
+		childInode := GetInodeFromPathByIndex(childIndex, part)
 		if childInode == nil {
 			continue
 		}
-		// Convertir permisos numéricos (ej. "664") a formato simbólico (ej. "rw-rw-r--")
-		perms := parsePermissions(strings.Trim(string(childInode.I_perm[:]), "\x00"), childInode.I_type[0])
-		// Usar I_ctime como fecha de creación y I_mtime como fecha de modificación.
-		creation := strings.Trim(string(childInode.I_ctime[:]), "\x00")
-		modification := strings.Trim(string(childInode.I_mtime[:]), "\x00")
-		// Para propósitos de este reporte, podríamos formatear las fechas si es necesario.
-		// Aquí las dejamos como strings.
-		var tipo string
-		if childInode.I_type[0] == '0' {
-			tipo = "Directorio"
-		} else {
-			tipo = "Archivo"
-		}
-		entries = append(entries, EntryInfo{
-			Name:         entryName,
-			Perms:        perms,
-			UID:          childInode.I_uid,
-			GID:          childInode.I_gid,
-			Size:         childInode.I_size,
-			CreationDate: creation,
-			ModDate:      modification,
-			Type:         tipo,
-		})
-	}
-
-	// 7. Generar el reporte DOT usando una tabla HTML (cada fila es un archivo o directorio)
-	var buffer strings.Builder
-	buffer.WriteString("digraph Reporte_Ls {\n")
-	buffer.WriteString("    rankdir=TB;\n")
-	buffer.WriteString("    node [fontname=\"Arial\", shape=plaintext, fontsize=10];\n")
-	buffer.WriteString("    graph [bgcolor=\"#ffffff\"];\n\n")
-	buffer.WriteString("    ls_report [\n")
-	buffer.WriteString("        label=<\n")
-	buffer.WriteString("            <TABLE BORDER=\"1\" CELLBORDER=\"1\" CELLSPACING=\"0\" CELLPADDING=\"4\">\n")
-	buffer.WriteString("                <TR bgcolor=\"#B3D9FF\">\n")
-	buffer.WriteString("                    <TD><B>PERMISOS</B></TD>\n")
-	buffer.WriteString("                    <TD><B>UID</B></TD>\n")
-	buffer.WriteString("                    <TD><B>GID</B></TD>\n")
-	buffer.WriteString("                    <TD><B>TAMAÑO</B></TD>\n")
-	buffer.WriteString("                    <TD><B>CREACIÓN</B></TD>\n")
-	buffer.WriteString("                    <TD><B>MODIFICACIÓN</B></TD>\n")
-	buffer.WriteString("                    <TD><B>TIPO</B></TD>\n")
-	buffer.WriteString("                    <TD><B>NOMBRE</B></TD>\n")
-	buffer.WriteString("                </TR>\n")
+		entries = append(entries, newLsEntry(entryName, *childInode))
+	}
+
+	return LsData{Path: path_file_ls, Entries: entries}, nil
+}
+
+// newLsEntry retorna la entrada del reporte ls para el inodo con nombre name
+func newLsEntry(name string, inode Structs.Inode) LsEntry {
+	// Convertir permisos numéricos (ej. "664") a formato simbólico (ej. "rw-rw-r--")
+	perms := parsePermissions(strings.Trim(string(inode.I_perm[:]), "\x00"), inode.I_type[0])
+	// Usar I_ctime como fecha de creación y I_mtime como fecha de modificación.
+	creation := strings.Trim(string(inode.I_ctime[:]), "\x00")
+	modification := strings.Trim(string(inode.I_mtime[:]), "\x00")
+	var tipo string
+	if inode.I_type[0] == '0' {
+		tipo = "Directorio"
+	} else {
+		tipo = "Archivo"
+	}
+	return LsEntry{
+		Name:         name,
+		Perms:        perms,
+		UID:          inode.I_uid,
+		GID:          inode.I_gid,
+		Size:         inode.I_size,
+		CreationDate: creation,
+		ModDate:      modification,
+		Type:         tipo,
+	}
+}
+
+// ReportLs genera un reporte con una tabla de las entradas de LsReportData. El
+// reporte se guarda en 'outputPath'.
+func ReportLs(out *OutPut.Output, id string, outputPath string, path_file_ls string) error {
+	data, err := LsReportData(id, path_file_ls)
+	if err != nil {
+		return err
+	}
+
+	// Las exportaciones .csv y .md usan los nombres de los campos JSON
+	if Export.Supported(outputPath) {
+		table := &Export.Table{
+			Title:   fmt.Sprintf("ls %s - partición %s", data.Path, id),
+			Columns: []string{"perms", "uid", "gid", "size", "creationDate", "modDate", "type", "name"},
+		}
+		for _, e := range data.Entries {
+			table.Row(e.Perms, e.UID, e.GID, e.Size, e.CreationDate, e.ModDate, e.Type, e.Name)
+		}
+		if outputPath, err = Export.Write(table, outputPath); err != nil {
+			return err
+		}
+		out.Printf("Reporte LS generado exitosamente en: %s\n", outputPath)
+		return nil
+	}
+
+	// Generar el reporte con una tabla (cada fila es un archivo o directorio)
+	table := &Render.Table{}
+	var header []Render.Cell
+	for _, title := range []string{"PERMISOS", "UID", "GID", "TAMAÑO", "CREACIÓN", "MODIFICACIÓN", "TIPO", "NOMBRE"} {
+		header = append(header, Render.Cell{Text: title, Bold: true, Color: "#B3D9FF"})
+	}
+	table.Row(header...)
 
 	// Agregar una fila por cada entrada
-	for _, e := range entries {
-		buffer.WriteString("                <TR>\n")
-		buffer.WriteString(fmt.Sprintf("                    <TD>%s</TD>\n", e.Perms))
-		buffer.WriteString(fmt.Sprintf("                    <TD>%d</TD>\n", e.UID))
-		buffer.WriteString(fmt.Sprintf("                    <TD>%d</TD>\n", e.GID))
-		buffer.WriteString(fmt.Sprintf("                    <TD>%d</TD>\n", e.Size))
-		buffer.WriteString(fmt.Sprintf("                    <TD>%s</TD>\n", e.CreationDate))
-		buffer.WriteString(fmt.Sprintf("                    <TD>%s</TD>\n", e.ModDate))
-		buffer.WriteString(fmt.Sprintf("                    <TD>%s</TD>\n", e.Type))
-		buffer.WriteString(fmt.Sprintf("                    <TD>%s</TD>\n", e.Name))
-		buffer.WriteString("                </TR>\n")
-	}
-
-	buffer.WriteString("            </TABLE>\n")
-	buffer.WriteString("        >\n")
-	buffer.WriteString("    ];\n")
-	buffer.WriteString("}\n")
-
-	// 8. Guardar el archivo DOT y generar la imagen
-	dotFile := outputPath + ".dot"
-	if err := os.WriteFile(dotFile, []byte(buffer.String()), 0644); err != nil {
-		return fmt.Errorf("error escribiendo el DOT: %v", err)
-	}
-	cmd := exec.Command("dot", "-Tpng", "-Gdpi=300", dotFile, "-o", outputPath+".png")
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("error al ejecutar Graphviz: %v", err)
-	}
-
-	fmt.Printf("Reporte LS generado exitosamente en: %s\n", outputPath+".png")
+	for _, e := range data.Entries {
+		table.Row(
+			Render.Cell{Text: e.Perms},
+			Render.Cell{Text: fmt.Sprint(e.UID)},
+			Render.Cell{Text: fmt.Sprint(e.GID)},
+			Render.Cell{Text: fmt.Sprint(e.Size)},
+			Render.Cell{Text: e.CreationDate},
+			Render.Cell{Text: e.ModDate},
+			Render.Cell{Text: e.Type},
+			Render.Cell{Text: e.Name},
+		)
+	}
+
+	// Generar el reporte en el formato de la ruta de salida
+	outputPath, err = Render.Write(Render.Single("", table), outputPath)
+	if err != nil {
+		return err
+	}
+
+	out.Printf("Reporte LS generado exitosamente en: %s\n", outputPath)
 	return nil
 }
@@ -1,19 +1,24 @@
 package Utilities
 
 import (
+	"MIA_P1/Overlay"
 	"encoding/binary"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 )
 
 // Funtion to create bin file
+// Durante un dry-run el archivo se crea en el overlay (ver paquete Overlay)
 func CreateFile(name string) error {
+	name, err := Overlay.Path(name)
+	if err != nil {
+		return err
+	}
+
 	//Ensure the directory exists
 	dir := filepath.Dir(name)
 	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
-		fmt.Println("Error creating directory:", err)
 		return err
 	}
 
@@ -21,7 +26,6 @@ func CreateFile(name string) error {
 	if _, err := os.Stat(name); os.IsNotExist(err) {
 		file, err := os.Create(name)
 		if err != nil {
-			fmt.Println("Error creating file:", err)
 			return err
 		}
 		defer file.Close()
@@ -30,10 +34,14 @@ func CreateFile(name string) error {
 }
 
 // Funtion to open bin file in read/write mode
+// Durante un dry-run se abre la copia del overlay en vez del disco real
 func OpenFile(name string) (*os.File, error) {
+	name, err := Overlay.Path(name)
+	if err != nil {
+		return nil, err
+	}
 	file, err := os.OpenFile(name, os.O_RDWR, 0644)
 	if err != nil {
-		fmt.Println("Errpr open file:", err)
 		return nil, err
 	}
 	return file, nil
@@ -44,7 +52,6 @@ func WriteObject(file *os.File, data interface{}, position int64) error {
 	file.Seek(position, 0)
 	err := binary.Write(file, binary.LittleEndian, data)
 	if err != nil {
-		fmt.Println("Error writing to file:", err)
 		return err
 	}
 	return nil
@@ -55,7 +62,6 @@ func ReadObject(file *os.File, data interface{}, position int64) error {
 	file.Seek(position, 0)
 	err := binary.Read(file, binary.LittleEndian, data)
 	if err != nil {
-		fmt.Println("Error reading the object", err)
 		return err
 	}
 	return nil
@@ -71,12 +77,3 @@ func CreateParentDirs(path string) error {
 	}
 	return nil
 }
-
-// getFileNames obtiene el nombre del archivo .dot y el nombre de la imagen de salida
-func GetFileNames(path string) (string, string) {
-	dir := filepath.Dir(path)
-	baseName := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
-	dotFileName := filepath.Join(dir, baseName+".dot")
-	outputImage := path
-	return dotFileName, outputImage
-}
\ No newline at end of file
@@ -2,14 +2,31 @@ package main
 
 import (
 	"MIA_P1/Analyzer"
+	"MIA_P1/Cache"
+	"MIA_P1/Commands"
+	"MIA_P1/Diff"
 	"MIA_P1/DiskManagement"
+	"MIA_P1/Jobs"
+	"MIA_P1/Locks"
 	"MIA_P1/OutPut"
+	"MIA_P1/Overlay"
+	"MIA_P1/Reports"
+	"MIA_P1/Results"
+	"MIA_P1/Scripts"
+	"MIA_P1/Tree"
 	"MIA_P1/UserManager"
+	"bufio"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/url"
 	"os"
+	"os/signal"
+	"path"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -26,15 +43,16 @@ type LoginRequest struct {
 }
 
 type ExecuteRequest struct {
-	Input string `json:"input"`
+	Input        string `json:"input"`
+	Confirm      bool   `json:"confirm"`      // el usuario ya confirmó el comando
+	DryRun       bool   `json:"dryRun"`       // simula el comando sin modificar los discos
+	InlineReport bool   `json:"inlineReport"` // incluye en la respuesta el reporte que genere rep
 }
 
 type ExecuteScriptRequest struct {
-	Script string `json:"script"`
-}
-
-type ContinueScriptRequest struct {
-	RemainingScript []string `json:"remaining"`
+	Script        string `json:"script"`
+	DryRun        bool   `json:"dryRun"`        // simula el script sin modificar los discos
+	Transactional bool   `json:"transactional"` // deshace todo el script si un comando falla
 }
 
 type LoginResponse struct {
@@ -58,18 +76,66 @@ type HealthResponse struct {
 }
 
 type ExecuteResponse struct {
-	Confirm bool   `json:"confirm,omitempty"`
-	Message string `json:"message"`
-	Console string `json:"console"`
+	Confirm bool             `json:"confirm,omitempty"`
+	Message string           `json:"message"`
+	Console string           `json:"console"`
+	Lines   []OutPut.Line    `json:"lines"`
+	Result  Results.Result   `json:"result"`
+	DryRun  bool             `json:"dryRun,omitempty"`
+	Changes []Overlay.Change `json:"changes,omitempty"`
+	Report  *ReportContent   `json:"report,omitempty"`
+}
+
+// ReportContent es un reporte con su contenido en base64
+type ReportContent struct {
+	Reports.Report
+	Content []byte `json:"content"`
+}
+
+// ReportDataResponse son los datos de un reporte sin dibujar
+type ReportDataResponse struct {
+	Name      string        `json:"name"`
+	Partition string        `json:"partition"`
+	Data      interface{}   `json:"data"`
+	Lines     []OutPut.Line `json:"lines,omitempty"`
+}
+
+// ReportJobRequest pide generar un reporte en segundo plano, con los mismos
+// parámetros que rep
+type ReportJobRequest struct {
+	Name       string `json:"name"`
+	ID         string `json:"id"`
+	Path       string `json:"path"`
+	PathFileLs string `json:"pathFileLs"`
+	Root       string `json:"root"`     // solo tree
+	Depth      int    `json:"depth"`    // solo tree
+	NoBlocks   bool   `json:"noBlocks"` // solo tree
+	Top        int    `json:"top"`      // solo usage
 }
 
 type ExecuteScriptResponse struct {
-	Confirm   bool     `json:"confirm,omitempty"`
-	Message   string   `json:"message,omitempty"`
-	Results   []string `json:"results"`
-	Console   string   `json:"console"`
-	Paused    bool     `json:"paused"`
-	Remaining []string `json:"remaining,omitempty"`
+	Confirm    bool             `json:"confirm,omitempty"`
+	Message    string           `json:"message,omitempty"`
+	Results    []string         `json:"results"`
+	Console    string           `json:"console"`
+	Lines      []OutPut.Line    `json:"lines"`
+	Commands   []Results.Result `json:"commands"`
+	Paused     bool             `json:"paused"`
+	Cancelled  bool             `json:"cancelled,omitempty"`
+	ScriptID   string           `json:"scriptId,omitempty"`
+	File       string           `json:"file,omitempty"`
+	Line       int              `json:"line,omitempty"`
+	Remaining  int              `json:"remaining,omitempty"`
+	ExpiresAt  string           `json:"expiresAt,omitempty"`
+	DryRun     bool             `json:"dryRun,omitempty"`
+	Changes    []Overlay.Change `json:"changes,omitempty"`
+	RolledBack bool             `json:"rolledBack,omitempty"`
+	Stopped    bool             `json:"stopped,omitempty"`
+}
+
+type ValidateScriptResponse struct {
+	Valid       bool                  `json:"valid"`
+	Diagnostics []Analyzer.Diagnostic `json:"diagnostics"`
 }
 
 type DisksResponse struct {
@@ -77,9 +143,9 @@ type DisksResponse struct {
 }
 
 type DiskInfo struct {
-	Name               string   `json:"name"`
-	Path               string   `json:"path"`
-	MountedPartitions  []string `json:"mounted_partitions"`
+	Name              string   `json:"name"`
+	Path              string   `json:"path"`
+	MountedPartitions []string `json:"mounted_partitions"`
 }
 
 type AllDisksResponse struct {
@@ -91,6 +157,10 @@ type ErrorResponse struct {
 }
 
 // ---------- CONFIGURACIÓN ----------
+
+// streamWriteTimeout es el plazo para escribir cada evento de un script en streaming
+const streamWriteTimeout = 30 * time.Second
+
 func getPort() string {
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -122,19 +192,19 @@ func main() {
 		StrictRouting: false,
 		ServerHeader:  "MIA-Backend",
 		AppName:       "MIA Project v1.0",
-		
+
 		// Timeouts apropiados para producción
 		ReadTimeout:  time.Second * 30,
 		WriteTimeout: time.Second * 30,
 		IdleTimeout:  time.Second * 60,
-		
+
 		// Manejo de errores
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			code := fiber.StatusInternalServerError
 			if e, ok := err.(*fiber.Error); ok {
 				code = e.Code
 			}
-			
+
 			log.Printf("Error: %v", err)
 			return c.Status(code).JSON(ErrorResponse{
 				Error: err.Error(),
@@ -152,10 +222,10 @@ func main() {
 
 	// Configuración del middleware CORS para producción
 	corsConfig := cors.Config{
-		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
+		AllowMethods: "GET,POST,PUT,PATCH,DELETE,OPTIONS",
 		AllowHeaders: "Origin,Content-Type,Accept,Authorization",
 	}
-	
+
 	// Configuración segura de CORS
 	allowedOrigins := getAllowedOrigins()
 	if allowedOrigins == "*" {
@@ -167,17 +237,17 @@ func main() {
 		corsConfig.AllowOrigins = allowedOrigins
 		corsConfig.AllowCredentials = true
 	}
-	
+
 	app.Use(cors.New(corsConfig))
 
 	// Endpoint para login
-	app.Post("/login", handleLogin)
+	app.Post("/login", shared, handleLogin)
 
 	// Endpoint para logout
-	app.Post("/logout", handleLogout)
+	app.Post("/logout", shared, handleLogout)
 
 	// Endpoint para verificar el estado de la sesión
-	app.Get("/session", handleSession)
+	app.Get("/session", shared, handleSession)
 
 	// Healthcheck endpoint mejorado para producción
 	app.Get("/health", handleHealth)
@@ -185,14 +255,39 @@ func main() {
 	// API endpoints
 	app.Post("/api/execute", handleExecute)
 	app.Post("/api/executeScript", handleExecuteScript)
-	app.Post("/api/continueScript", handleContinueScript)
-	app.Get("/api/disk-tree/:id", handleDiskTree)
-	app.Get("/api/partition-content/:id", handlePartitionContent)
-	app.Get("/api/partitions/:disk", handlePartitionsByDisk)
-	app.Get("/api/disks", handleDisks)
-	app.Get("/disks/:name/partitions", handleDiskPartitions)
-	app.Get("/api/test-partition/:id", handleTestPartition)
-	app.Get("/api/all-disks", handleAllDisks)
+	app.Post("/api/validateScript", shared, handleValidateScript)
+	app.Get("/api/commands", handleCommands)
+	app.Get("/api/commands/:name", handleCommand)
+	app.Get("/api/reports", handleReports)
+	app.Get("/api/reports/:id", handleReport)
+	app.Get("/api/reports/:name/:id", shared, handleReportData)
+	app.Post("/api/report-jobs", shared, handleStartReportJob)
+	app.Get("/api/report-jobs", handleReportJobs)
+	app.Get("/api/report-jobs/:id", handleReportJob)
+	app.Post("/api/report-jobs/:id/cancel", handleCancelReportJob)
+	app.Post("/api/diff", shared, handleDiff)
+	app.Get("/api/snapshots", handleSnapshots)
+	app.Post("/api/snapshots", shared, handleSaveSnapshot)
+	app.Post("/api/scripts/stream", handleStreamScript)
+	app.Post("/api/scripts/:id/continue", handleContinueScript)
+	app.Post("/api/scripts/:id/continue/stream", handleStreamContinue)
+	app.Post("/api/scripts/:id/confirm/stream", handleStreamConfirm)
+	app.Post("/api/scripts/:id/confirm", handleConfirmScript)
+	app.Post("/api/scripts/:id/cancel", handleCancelScript)
+	app.Get("/api/disk-tree/:id", shared, handleDiskTree)
+	app.Get("/api/partition-content/:id", shared, handlePartitionContent)
+	app.Get("/api/partitions/:disk", shared, handlePartitionsByDisk)
+	app.Get("/api/disks", shared, handleDisks)
+	app.Get("/disks/:name/partitions", shared, handleDiskPartitions)
+	app.Get("/api/test-partition/:id", shared, handleTestPartition)
+	app.Get("/api/all-disks", shared, handleAllDisks)
+
+	// Archivos y carpetas de la partición de la sesión
+	app.Get(fsRoute, shared, fsSession, handleFsGet)
+	app.Put(fsRoute, shared, fsSession, handleFsPut)
+	app.Post(fsRoute, shared, fsSession, handleFsMkdir)
+	app.Patch(fsRoute, shared, fsSession, handleFsPatch)
+	app.Delete(fsRoute, shared, fsSession, handleFsDelete)
 
 	// Ruta para servir archivos estáticos si es necesario
 	app.Static("/static", "./static")
@@ -207,8 +302,25 @@ func main() {
 	log.Printf("Servidor iniciado en http://0.0.0.0%s", port)
 	log.Printf("Host: %s", getHost())
 	log.Printf("Directorio de discos: %s", getDiskDirectory())
-	
-	log.Fatal(app.Listen("0.0.0.0" + port)) // Escuchar en todas las interfaces
+
+	// Al recibir SIGINT/SIGTERM se bajan las cachés de particiones antes de salir
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+		<-quit
+		log.Println("Apagando el servidor...")
+		if err := app.Shutdown(); err != nil {
+			log.Printf("Error al detener el servidor: %v", err)
+		}
+	}()
+
+	if err := app.Listen("0.0.0.0" + port); err != nil { // Escuchar en todas las interfaces
+		log.Printf("Error en el servidor: %v", err)
+	}
+
+	if err := Cache.FlushAll(); err != nil {
+		log.Printf("Error al guardar las particiones: %v", err)
+	}
 }
 
 // ---------- CONFIGURACIONES AUXILIARES ----------
@@ -224,6 +336,13 @@ func getAllowedOrigins() string {
 
 // ---------- HANDLERS ----------
 
+// shared toma el candado global en modo lectura mientras se atiende la petición,
+// para que las rutas que leen los discos directamente no vean un dry-run a medias
+func shared(c *fiber.Ctx) error {
+	defer Locks.RLockAll()()
+	return c.Next()
+}
+
 func handleLogin(c *fiber.Ctx) error {
 	var request LoginRequest
 	if err := c.BodyParser(&request); err != nil {
@@ -240,7 +359,7 @@ func handleLogin(c *fiber.Ctx) error {
 	}
 
 	// Intentar hacer login usando UserManager
-	err := UserManager.Login(request.Username, request.Password, request.PartitionID)
+	err := UserManager.Login(OutPut.New(), request.Username, request.Password, request.PartitionID)
 	if err != nil {
 		log.Printf("Login fallido para usuario %s: %v", request.Username, err)
 		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse{
@@ -255,7 +374,7 @@ func handleLogin(c *fiber.Ctx) error {
 }
 
 func handleLogout(c *fiber.Ctx) error {
-	err := UserManager.Logout()
+	err := UserManager.Logout(OutPut.New())
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
 			Error: err.Error(),
@@ -292,25 +411,329 @@ func handleExecute(c *fiber.Ctx) error {
 		})
 	}
 
-	OutPut.Clear()
+	// Cada petición usa su propia salida para no mezclarse con otras ejecuciones
+	out := OutPut.New()
 	command, params := Analyzer.GetCommandAndParams(request.Input)
-	result := Analyzer.AnalyzeCommand(command, params)
-	output := OutPut.GetOutput()
+	var (
+		result  Results.Result
+		changes []Overlay.Change
+	)
+	switch {
+	case request.DryRun:
+		result, changes = Analyzer.AnalyzeDryRun(out, command, params)
+	case request.Confirm:
+		result = Analyzer.AnalyzeConfirmed(out, command, params)
+	default:
+		result = Analyzer.AnalyzeCommand(out, command, params)
+	}
+	output := out.String()
 
 	log.Printf("Comando ejecutado: %s", request.Input)
 
-	if strings.HasPrefix(result, "CONFIRM_RMDISK:") {
-		return c.JSON(ExecuteResponse{
-			Confirm: true,
-			Message: result,
-			Console: output,
+	response := ExecuteResponse{
+		Confirm: result.Status == Results.StatusConfirm,
+		Message: result.Message,
+		Console: output,
+		Lines:   out.Lines(),
+		Result:  result,
+		DryRun:  request.DryRun,
+		Changes: changes,
+	}
+	if report, ok := result.Data.(Reports.Report); ok && request.InlineReport {
+		if report, content, err := Reports.Read(report.ID); err == nil {
+			response.Report = &ReportContent{Report: report, Content: content}
+		}
+	}
+	return c.JSON(response)
+}
+
+// handleReports lista los reportes generados, del más reciente al más antiguo
+func handleReports(c *fiber.Ctx) error {
+	return c.JSON(Reports.List())
+}
+
+// handleReport descarga un reporte con su tipo de contenido. Con ?download=1 el
+// navegador lo guarda en lugar de mostrarlo.
+func handleReport(c *fiber.Ctx) error {
+	report, content, err := Reports.Read(c.Params("id"))
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		if Results.CodeOf(err) == Results.NotFound {
+			status = fiber.StatusNotFound
+		}
+		return c.Status(status).JSON(ErrorResponse{Error: err.Error()})
+	}
+	disposition := "inline"
+	if c.Query("download") != "" {
+		disposition = "attachment"
+	}
+	c.Set(fiber.HeaderContentType, report.ContentType)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("%s; filename=%q", disposition, path.Base(report.Path)))
+	return c.Send(content)
+}
+
+// handleReportData retorna como JSON los datos del reporte :name de la partición
+// :id. Los reportes file y ls reciben la ruta con ?path=, tree acepta ?root=,
+// ?depth= y ?noblocks= como -root, -depth y -noblocks de rep, y usage acepta
+// ?top= como -top.
+func handleReportData(c *fiber.Ctx) error {
+	out := OutPut.New()
+	params := Analyzer.ReportParams{
+		Name:       c.Params("name"),
+		ID:         c.Params("id"),
+		PathFileLs: c.Query("path"),
+		Tree:       Tree.Options{Root: c.Query("root"), Depth: c.QueryInt("depth"), NoBlocks: c.QueryBool("noblocks")},
+		Top:        c.QueryInt("top"),
+	}
+	data, err := Analyzer.ReportData(context.Background(), out, params)
+	if err != nil {
+		return reportError(c, err)
+	}
+	return c.JSON(ReportDataResponse{Name: strings.ToLower(params.Name), Partition: params.ID, Data: data, Lines: out.Lines()})
+}
+
+// handleStartReportJob encola un reporte y responde con el trabajo sin esperar a
+// que termine
+func handleStartReportJob(c *fiber.Ctx) error {
+	var request ReportJobRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: "Datos inválidos",
 		})
 	}
+	job, err := Analyzer.StartReportJob(Analyzer.ReportParams{
+		Name:       request.Name,
+		ID:         request.ID,
+		PathFileLs: request.PathFileLs,
+		Tree:       Tree.Options{Root: request.Root, Depth: request.Depth, NoBlocks: request.NoBlocks},
+		Top:        request.Top,
+	}, request.Path)
+	if err != nil {
+		return reportError(c, err)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
 
-	return c.JSON(ExecuteResponse{
-		Message: result,
-		Console: output,
+// handleReportJobs lista los trabajos de reportes, del más reciente al más antiguo
+func handleReportJobs(c *fiber.Ctx) error {
+	return c.JSON(Jobs.List())
+}
+
+// handleReportJob retorna el estado y el avance de un trabajo
+func handleReportJob(c *fiber.Ctx) error {
+	job, err := Jobs.Get(c.Params("id"))
+	if err != nil {
+		return reportError(c, err)
+	}
+	return c.JSON(job)
+}
+
+// handleCancelReportJob cancela un trabajo pendiente o en ejecución
+func handleCancelReportJob(c *fiber.Ctx) error {
+	job, err := Jobs.Cancel(c.Params("id"))
+	if err != nil {
+		return reportError(c, err)
+	}
+	return c.JSON(job)
+}
+
+// handleDiff compara dos particiones, o una partición y una instantánea, con los
+// mismos parámetros que diff
+func handleDiff(c *fiber.Ctx) error {
+	var request Analyzer.DiffParams
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: "Datos inválidos",
+		})
+	}
+	result, err := Analyzer.CompareFiles(context.Background(), request)
+	if err != nil {
+		return reportError(c, err)
+	}
+	return c.JSON(result)
+}
+
+// SnapshotRequest pide guardar una instantánea, con los mismos parámetros que snapshot
+type SnapshotRequest struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// handleSnapshots lista las instantáneas guardadas, sin sus entradas
+func handleSnapshots(c *fiber.Ctx) error {
+	return c.JSON(Diff.List())
+}
+
+// handleSaveSnapshot guarda los archivos de una partición como instantánea
+func handleSaveSnapshot(c *fiber.Ctx) error {
+	var request SnapshotRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: "Datos inválidos",
+		})
+	}
+	snapshot, err := Analyzer.SaveSnapshot(context.Background(), request.ID, request.Name, request.Path)
+	if err != nil {
+		return reportError(c, err)
+	}
+	snapshot.Entries = nil
+	return c.Status(fiber.StatusCreated).JSON(snapshot)
+}
+
+// reportError responde con el estado HTTP que corresponde al código del error
+func reportError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	switch Results.CodeOf(err) {
+	case Results.NotFound:
+		status = fiber.StatusNotFound
+	case Results.InvalidParams:
+		status = fiber.StatusBadRequest
+	case Results.PermissionDenied:
+		status = fiber.StatusForbidden
+	case Results.NotLoggedIn:
+		status = fiber.StatusUnauthorized
+	case Results.AlreadyExists:
+		status = fiber.StatusConflict
+	}
+	return c.Status(status).JSON(ErrorResponse{Error: err.Error()})
+}
+
+// fsRoute es la ruta de un archivo o carpeta de una partición; lo que sigue a fs/
+// es la ruta dentro de la partición
+const fsRoute = "/api/v1/partitions/:id/fs/*"
+
+// FsPatchRequest cambia el nombre, los permisos o el propietario de un archivo o
+// carpeta; los campos vacíos no se cambian
+type FsPatchRequest struct {
+	Name  string `json:"name"`
+	Perm  string `json:"perm"`
+	Owner string `json:"owner"`
+}
+
+// fsSession exige una sesión iniciada en la partición :id. Las operaciones usan la
+// sesión igual que los comandos, así que no se puede trabajar en otra partición.
+func fsSession(c *fiber.Ctx) error {
+	_, partition, ok := UserManager.CurrentSession()
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse{
+			Error: "Necesita iniciar sesión",
+		})
+	}
+	if partition != c.Params("id") {
+		return c.Status(fiber.StatusForbidden).JSON(ErrorResponse{
+			Error: fmt.Sprintf("La sesión está iniciada en la partición %s, no en %s", partition, c.Params("id")),
+		})
+	}
+	return c.Next()
+}
+
+// fsPath retorna la ruta dentro de la partición de una petición a fsRoute
+func fsPath(c *fiber.Ctx) string {
+	filePath, err := url.PathUnescape(c.Params("*"))
+	if err != nil {
+		filePath = c.Params("*")
+	}
+	return "/" + strings.Trim(filePath, "/")
+}
+
+// handleFsGet retorna el contenido de un archivo como texto, o los datos de una
+// carpeta con sus entradas. Con ?meta=true retorna también los datos de un archivo.
+func handleFsGet(c *fiber.Ctx) error {
+	filePath := fsPath(c)
+	info, err := UserManager.Stat(filePath)
+	if err != nil {
+		return reportError(c, err)
+	}
+	if info.Entries != nil || c.QueryBool("meta") {
+		return c.JSON(info)
+	}
+	content, err := UserManager.ReadFile(filePath)
+	if err != nil {
+		return reportError(c, err)
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+	return c.SendString(content)
+}
+
+// handleFsPut crea un archivo con el cuerpo de la petición como contenido, o
+// reemplaza el contenido si ya existe. Con ?parents=true crea las carpetas padre.
+func handleFsPut(c *fiber.Ctx) error {
+	info, created, err := UserManager.WriteFile(OutPut.New(), fsPath(c), string(c.Body()), c.QueryBool("parents"))
+	if err != nil {
+		return reportError(c, err)
+	}
+	if created {
+		c.Status(fiber.StatusCreated)
+	}
+	return c.JSON(info)
+}
+
+// handleFsMkdir crea una carpeta, como mkdir. Con ?parents=true crea las carpetas padre.
+func handleFsMkdir(c *fiber.Ctx) error {
+	filePath := fsPath(c)
+	if err := UserManager.Mkdir(OutPut.New(), filePath, c.QueryBool("parents")); err != nil {
+		return reportError(c, err)
+	}
+	info, err := UserManager.Stat(filePath)
+	if err != nil {
+		return reportError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(info)
+}
+
+// handleFsPatch cambia los permisos, el propietario y el nombre de un archivo o
+// carpeta, como chmod, chown y rename. Si algún cambio no es válido no se aplica ninguno.
+func handleFsPatch(c *fiber.Ctx) error {
+	var request FsPatchRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: "Datos inválidos",
+		})
+	}
+	if request.Name == "" && request.Perm == "" && request.Owner == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: "Indique name, perm u owner",
+		})
+	}
+
+	info, err := UserManager.Update(OutPut.New(), fsPath(c), UserManager.Changes{
+		Perm:  request.Perm,
+		Owner: request.Owner,
+		Name:  request.Name,
 	})
+	if err != nil {
+		return reportError(c, err)
+	}
+	return c.JSON(info)
+}
+
+// handleFsDelete elimina un archivo o una carpeta con todo su contenido
+func handleFsDelete(c *fiber.Ctx) error {
+	if err := UserManager.Remove(OutPut.New(), fsPath(c)); err != nil {
+		return reportError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// handleCommands retorna la documentación de todos los comandos, la misma que muestra man
+func handleCommands(c *fiber.Ctx) error {
+	docs := []Commands.Doc{}
+	for _, cmd := range Commands.All() {
+		docs = append(docs, cmd.Doc())
+	}
+	return c.JSON(docs)
+}
+
+// handleCommand retorna la documentación de un comando por su nombre o alias
+func handleCommand(c *fiber.Ctx) error {
+	cmd, ok := Commands.Lookup(c.Params("name"))
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+			Error: "no existe el comando " + c.Params("name"),
+		})
+	}
+	return c.JSON(cmd.Doc())
 }
 
 func handleExecuteScript(c *fiber.Ctx) error {
@@ -321,78 +744,223 @@ func handleExecuteScript(c *fiber.Ctx) error {
 		})
 	}
 
-	OutPut.Clear()
-	results, paused, remainingScriptLines, confirm, confirmMsg := Analyzer.ExecuteScript(request.Script)
-	output := OutPut.GetOutput()
+	if request.DryRun && request.Transactional {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: "dryRun y transactional no se pueden usar juntos",
+		})
+	}
+
+	out := OutPut.New()
+	res, err := startScript(context.Background(), out, request, Scripts.Hooks{})
+	if err != nil {
+		return scriptError(c, err)
+	}
 
 	log.Printf("Script ejecutado con %d líneas", len(strings.Split(request.Script, "\n")))
 
-	if confirm {
-		log.Println("DEBUG: Enviando confirmación de rmdisk al frontend desde /api/executeScript")
-		return c.JSON(ExecuteScriptResponse{
-			Confirm:   true,
-			Message:   confirmMsg,
-			Results:   results,
-			Console:   output,
-			Remaining: remainingScriptLines,
+	return c.JSON(scriptResponse(out, res))
+}
+
+// handleValidateScript revisa un script sin ejecutarlo y retorna los problemas por línea
+func handleValidateScript(c *fiber.Ctx) error {
+	var request ExecuteScriptRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: "Datos inválidos",
 		})
 	}
 
-	if paused {
-		return c.JSON(ExecuteScriptResponse{
-			Results:   results,
-			Console:   output,
-			Paused:    true,
-			Remaining: remainingScriptLines,
-		})
+	diagnostics := Analyzer.ValidateScript(request.Script)
+	valid := true
+	for _, d := range diagnostics {
+		if d.Severity == OutPut.Error {
+			valid = false
+		}
+	}
+	if diagnostics == nil {
+		diagnostics = []Analyzer.Diagnostic{}
 	}
 
-	return c.JSON(ExecuteScriptResponse{
-		Results: results,
-		Console: output,
-		Paused:  false,
+	return c.JSON(ValidateScriptResponse{
+		Valid:       valid,
+		Diagnostics: diagnostics,
 	})
 }
 
+// handleContinueScript reanuda un script pausado. Si el script espera confirmación,
+// el comando pendiente se omite.
 func handleContinueScript(c *fiber.Ctx) error {
-	var request ContinueScriptRequest
+	out := OutPut.New()
+	res, err := Scripts.Continue(context.Background(), out, c.Params("id"), Scripts.Hooks{})
+	if err != nil {
+		return scriptError(c, err)
+	}
+
+	log.Printf("Continuando script %s", c.Params("id"))
+
+	return c.JSON(scriptResponse(out, res))
+}
+
+// handleConfirmScript ejecuta el comando que espera confirmación y continúa el script
+func handleConfirmScript(c *fiber.Ctx) error {
+	out := OutPut.New()
+	res, err := Scripts.Confirm(context.Background(), out, c.Params("id"), Scripts.Hooks{})
+	if err != nil {
+		return scriptError(c, err)
+	}
+
+	log.Printf("Comando confirmado en script %s", c.Params("id"))
+
+	return c.JSON(scriptResponse(out, res))
+}
+
+// handleStreamScript ejecuta un script nuevo y envía su avance como Server-Sent Events
+func handleStreamScript(c *fiber.Ctx) error {
+	var request ExecuteScriptRequest
 	if err := c.BodyParser(&request); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
 			Error: "Datos inválidos",
 		})
 	}
+	if request.DryRun && request.Transactional {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: "dryRun y transactional no se pueden usar juntos",
+		})
+	}
+	return streamScript(c, func(ctx context.Context, out *OutPut.Output, hooks Scripts.Hooks) (Scripts.Result, error) {
+		return startScript(ctx, out, request, hooks)
+	})
+}
 
-	remainingScript := strings.Join(request.RemainingScript, "\n")
-	OutPut.Clear()
-	results, paused, remainingScriptLines, confirm, confirmMsg := Analyzer.ExecuteScript(remainingScript)
-	output := OutPut.GetOutput()
+// startScript ejecuta un script nuevo en el modo pedido: normal, dry-run o transaccional
+func startScript(ctx context.Context, out *OutPut.Output, request ExecuteScriptRequest, hooks Scripts.Hooks) (Scripts.Result, error) {
+	switch {
+	case request.DryRun:
+		return Scripts.DryRun(ctx, out, request.Script, hooks)
+	case request.Transactional:
+		return Scripts.Transaction(ctx, out, request.Script, hooks)
+	default:
+		return Scripts.Start(ctx, out, request.Script, hooks)
+	}
+}
 
-	log.Println("Continuando script pausado")
+// handleStreamContinue reanuda un script pausado enviando su avance como eventos
+func handleStreamContinue(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if _, ok := Scripts.Get(id); !ok {
+		return scriptError(c, Results.Errorf(Results.NotFound, "la sesión de script %s no existe o expiró", id))
+	}
+	return streamScript(c, func(ctx context.Context, out *OutPut.Output, hooks Scripts.Hooks) (Scripts.Result, error) {
+		return Scripts.Continue(ctx, out, id, hooks)
+	})
+}
 
-	if confirm {
-		log.Println("DEBUG: Enviando confirmación de rmdisk al frontend desde /api/continueScript")
-		return c.JSON(ExecuteScriptResponse{
-			Confirm:   true,
-			Message:   confirmMsg,
-			Results:   results,
-			Console:   output,
-			Remaining: remainingScriptLines,
-		})
+// handleStreamConfirm confirma el comando pendiente y continúa enviando el avance como eventos
+func handleStreamConfirm(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if _, ok := Scripts.Get(id); !ok {
+		return scriptError(c, Results.Errorf(Results.NotFound, "la sesión de script %s no existe o expiró", id))
 	}
+	return streamScript(c, func(ctx context.Context, out *OutPut.Output, hooks Scripts.Hooks) (Scripts.Result, error) {
+		return Scripts.Confirm(ctx, out, id, hooks)
+	})
+}
+
+// streamScript responde con Server-Sent Events:
+//   - start: {"scriptId": ...} antes de la primera línea
+//   - line: resultado y salida de cada comando ejecutado
+//   - end: estado final igual al de /api/executeScript (pausa, confirmación o fin)
+//
+// Si el cliente se desconecta el script se cancela antes de su siguiente línea.
+func streamScript(c *fiber.Ctx, exec func(ctx context.Context, out *OutPut.Output, hooks Scripts.Hooks) (Scripts.Result, error)) error {
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	// El WriteTimeout del servidor aplica a toda la respuesta; cada evento extiende el plazo
+	conn := c.Context().Conn()
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		send := func(event string, data interface{}) {
+			payload, err := json.Marshal(data)
+			if err != nil {
+				log.Printf("Error serializando evento %s: %v", event, err)
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+			if err := w.Flush(); err != nil {
+				// El cliente cerró la conexión
+				cancel()
+			}
+		}
 
-	if paused {
-		return c.JSON(ExecuteScriptResponse{
-			Results:   results,
-			Console:   output,
-			Paused:    true,
-			Remaining: remainingScriptLines,
+		out := OutPut.New()
+		res, err := exec(ctx, out, Scripts.Hooks{
+			OnStart: func(id string) {
+				send("start", fiber.Map{"scriptId": id})
+			},
+			OnLine: func(line Analyzer.LineResult) {
+				send("line", line)
+			},
 		})
-	}
+		if err != nil {
+			send("error", ErrorResponse{Error: err.Error()})
+			return
+		}
+		send("end", scriptResponse(out, res))
+	})
+	return nil
+}
 
+// handleCancelScript detiene un script en ejecución o descarta uno pausado
+func handleCancelScript(c *fiber.Ctx) error {
+	if err := Scripts.Cancel(c.Params("id")); err != nil {
+		return scriptError(c, err)
+	}
 	return c.JSON(ExecuteScriptResponse{
-		Results: results,
-		Console: output,
-		Paused:  false,
+		Message: "Script cancelado",
+		Results: []string{},
+		Lines:   []OutPut.Line{},
+	})
+}
+
+func scriptResponse(out *OutPut.Output, res Scripts.Result) ExecuteScriptResponse {
+	response := ExecuteScriptResponse{
+		Confirm:    res.Confirm,
+		Message:    res.ConfirmMessage,
+		Results:    res.Results,
+		Commands:   res.Commands,
+		Console:    out.String(),
+		Lines:      out.Lines(),
+		Paused:     res.Paused,
+		Cancelled:  res.Cancelled,
+		ScriptID:   res.SessionID,
+		File:       res.File,
+		Line:       res.Line,
+		Remaining:  res.Remaining,
+		DryRun:     res.DryRun,
+		Changes:    res.Changes,
+		RolledBack: res.RolledBack,
+		Stopped:    res.Stopped,
+	}
+	if res.SessionID != "" {
+		response.ExpiresAt = res.Expires.Format(time.RFC3339)
+	}
+	return response
+}
+
+func scriptError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusBadRequest
+	if Results.CodeOf(err) == Results.NotFound {
+		status = fiber.StatusNotFound
+	}
+	return c.Status(status).JSON(ErrorResponse{
+		Error: err.Error(),
 	})
 }
 
@@ -512,13 +1080,13 @@ func handleAllDisks(c *fiber.Ctx) error {
 			Error: "No se pudo leer la carpeta de discos",
 		})
 	}
-	
+
 	var disks []string
 	for _, file := range files {
 		if !file.IsDir() && strings.HasSuffix(file.Name(), ".dsk") {
 			disks = append(disks, strings.TrimSuffix(file.Name(), ".dsk"))
 		}
 	}
-	
+
 	return c.JSON(AllDisksResponse{Disks: disks})
-}
\ No newline at end of file
+}
@@ -1,18 +1,20 @@
 package stores
 
 import (
+	"MIA_P1/Locks"
+	"MIA_P1/OutPut"
+	"MIA_P1/Overlay"
 	"MIA_P1/Structs"
 	"MIA_P1/Utilities"
 	"errors"
 	"fmt"
-	"os"
+	"log"
 	"path/filepath"
 	"strings"
-	"MIA_P1/OutPut"
 )
 
 // Carnet de estudiante (últimos dos dígitos)
-const Carnet string = "00" 
+const Carnet string = "00"
 
 // GetMountedPartition obtiene la partición montada con el id especificado
 func GetMountedPartition(id string) (*Structs.Partition, string, error) {
@@ -21,6 +23,7 @@ func GetMountedPartition(id string) (*Structs.Partition, string, error) {
 	if err != nil {
 		return nil, "", err
 	}
+	defer Locks.RLockDisk(diskPath)()
 
 	// Abrir el archivo del disco
 	file, err := Utilities.OpenFile(diskPath)
@@ -53,6 +56,7 @@ func GetMountedMBR(id string) (*Structs.MRB, string, error) {
 	if err != nil {
 		return nil, "", err
 	}
+	defer Locks.RLockDisk(diskPath)()
 
 	// Abrir el archivo del disco
 	file, err := Utilities.OpenFile(diskPath)
@@ -84,6 +88,7 @@ func GetMountedPartitionSuperblock(id string) (*Structs.Superblock, *Structs.Par
 	if err != nil {
 		return nil, nil, "", err
 	}
+	defer Locks.RLockPartition(diskPath, id)()
 
 	// Abrir el archivo del disco
 	file, err := Utilities.OpenFile(diskPath)
@@ -103,6 +108,8 @@ func GetMountedPartitionSuperblock(id string) (*Structs.Superblock, *Structs.Par
 
 // GetPartitions obtiene todas las particiones de un disco
 func GetPartitions(diskPath string) ([]Structs.Partition, error) {
+	defer Locks.RLockDisk(diskPath)()
+
 	// Abrir el archivo del disco
 	file, err := Utilities.OpenFile(diskPath)
 	if err != nil {
@@ -126,6 +133,8 @@ func GetPartitions(diskPath string) ([]Structs.Partition, error) {
 
 // LoadMBR carga el MBR desde un archivo binario
 func LoadMBR(diskPath string) (*Structs.MRB, error) {
+	defer Locks.RLockDisk(diskPath)()
+
 	// Abrir el archivo del disco
 	file, err := Utilities.OpenFile(diskPath)
 	if err != nil {
@@ -143,14 +152,14 @@ func LoadMBR(diskPath string) (*Structs.MRB, error) {
 }
 
 // ListMountedPartitions lista todas las particiones montadas
-func ListMountedPartitions() {
-	OutPut.Println("======Mounted Partitions======")
-	
+func ListMountedPartitions(out *OutPut.Output) {
+	out.Println("======Mounted Partitions======")
+
 	// Obtener todos los discos en la carpeta base
 	basePath := "./tets" // Changed from /home/user/MIA/P1
-	files, err := os.ReadDir(basePath)
+	files, err := Overlay.ReadDir(basePath)
 	if err != nil {
-		OutPut.Println("Error al leer la carpeta de discos:", err)
+		out.Error("Error al leer la carpeta de discos:", err)
 		return
 	}
 
@@ -161,14 +170,14 @@ func ListMountedPartitions() {
 			diskPath := filepath.Join(basePath, file.Name())
 			partitions, err := GetPartitions(diskPath)
 			if err != nil {
-				OutPut.Println("Error al leer particiones de", diskPath, ":", err)
+				out.Error("Error al leer particiones de", diskPath, ":", err)
 				continue
 			}
 			for _, partition := range partitions {
 				if partition.Size != 0 && string(partition.Status[:]) == "1" {
 					id := strings.Trim(string(partition.Id[:]), "\x00")
 					name := strings.Trim(string(partition.Name[:]), "\x00")
-					fmt.Printf("ID: %s, Name: %s, Disk: %s\n", id, name, file.Name())
+					out.Printf("ID: %s, Name: %s, Disk: %s\n", id, name, file.Name())
 					count++
 				}
 			}
@@ -176,15 +185,15 @@ func ListMountedPartitions() {
 	}
 
 	if count == 0 {
-		OutPut.Println("No hay particiones montadas")
+		out.Println("No hay particiones montadas")
 	}
-	OutPut.Println("======End Mounted Partitions======")
+	out.Println("======End Mounted Partitions======")
 }
 
 // findDiskPathByPartitionID busca el disco que contiene una partición con el id especificado
 func findDiskPathByPartitionID(id string) (string, error) {
 	basePath := "./tets"
-	files, err := os.ReadDir(basePath)
+	files, err := Overlay.ReadDir(basePath)
 	if err != nil {
 		return "", fmt.Errorf("error al leer la carpeta de discos: %v", err)
 	}
@@ -197,13 +206,13 @@ func findDiskPathByPartitionID(id string) (string, error) {
 			diskPath := filepath.Join(basePath, file.Name())
 			partitions, err := GetPartitions(diskPath)
 			if err != nil {
-				fmt.Printf("Error reading partitions from %s: %v\n", diskPath, err)
+				log.Printf("Error reading partitions from %s: %v", diskPath, err)
 				continue
 			}
 			for _, partition := range partitions {
 				partitionID := strings.Trim(string(partition.Id[:]), "\x00")
 				if partitionID == id && partition.Size != 0 {
-					fmt.Printf("Found partition ID %s in disk %s\n", id, diskPath)
+					log.Printf("Found partition ID %s in disk %s", id, diskPath)
 					return diskPath, nil
 				}
 			}
@@ -211,4 +220,4 @@ func findDiskPathByPartitionID(id string) (string, error) {
 	}
 
 	return "", fmt.Errorf("la partición con ID %s no está montada", id)
-}
\ No newline at end of file
+}
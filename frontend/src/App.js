@@ -3,6 +3,7 @@ import DiskSelector from "./DiskSelector";
 import PartitionSelector from "./PartitionSelector";
 import PartitionViewer from "./PartitionViewer";
 import LoginForm from "./LoginForm";
+import ReportViewer from "./ReportViewer";
 import "./App.css";
 
 function App() {
@@ -16,9 +17,18 @@ function App() {
     const [selectedPartition, setSelectedPartition] = useState(null);
     // Estados para pausa de scripts
     const [isPaused, setIsPaused] = useState(false);
-    const [remainingLines, setRemainingLines] = useState([]);
-    // Estado para confirmación de rmdisk
+    const [scriptId, setScriptId] = useState(null);
+    // Última línea ejecutada del script en curso
+    const [progress, setProgress] = useState(null);
+    // Ejecutar el script como transacción: si un comando falla se deshace todo
+    const [transactional, setTransactional] = useState(false);
+    // Estado para confirmación de comandos destructivos
     const [confirmData, setConfirmData] = useState(null);
+    // Documentación de los comandos (/api/commands) y comando de la línea donde está el cursor
+    const [commandDocs, setCommandDocs] = useState({});
+    const [currentCommand, setCurrentCommand] = useState("");
+    // Aumenta cuando termina un script para recargar la lista de reportes
+    const [reportsVersion, setReportsVersion] = useState(0);
     // Estado para healthcheck
     const [backendStatus, setBackendStatus] = useState("checking");
     // Nuevo estado para comando individual
@@ -46,6 +56,28 @@ function App() {
         return () => clearInterval(interval);
     }, []);
 
+    // Cargar la ayuda de los comandos para mostrarla mientras se escribe
+    useEffect(() => {
+        fetch("http://34.207.72.129:8080/api/commands")
+            .then((res) => res.json())
+            .then((docs) => {
+                const byName = {};
+                for (const doc of docs) {
+                    byName[doc.name] = doc;
+                    for (const alias of doc.aliases || []) byName[alias] = doc;
+                }
+                setCommandDocs(byName);
+            })
+            .catch(() => {});
+    }, []);
+
+    // Detecta el comando de la línea donde está el cursor
+    const updateCurrentCommand = (textarea) => {
+        const before = textarea.value.slice(0, textarea.selectionStart);
+        const line = before.slice(before.lastIndexOf("\n") + 1).trim();
+        setCurrentCommand(line.startsWith("#") ? "" : line.split(/\s+/)[0].toLowerCase());
+    };
+
     const handleLogin = (user) => {
         setUserData(user);
         setShowLoginForm(false);
@@ -80,79 +112,130 @@ function App() {
         }
     };
 
-    // Ejecutar comandos (primer envío)
-    const executeCommands = async (scriptOverride) => {
-        const scriptToRun = scriptOverride !== undefined ? scriptOverride : commands;
-        if (!scriptToRun.trim()) {
-            alert("Por favor, ingrese comandos para ejecutar.");
-            return;
+    // Actualiza el estado con la respuesta de un script (pausa, confirmación o fin).
+    // Con streaming la salida ya se mostró línea por línea.
+    const applyScriptResponse = (data, streamed) => {
+        const where = data.file ? `línea ${data.line} de ${data.file}` : `línea ${data.line}`;
+        if (!streamed || data.error) {
+            setOutput((prev) => (prev ? prev + "\n" : "") + (data.console || data.error || ""));
+        }
+        if (data.cancelled) {
+            setOutput((prev) => prev + `\nScript cancelado en la ${where}`);
+        }
+        if (streamed && data.rolledBack) {
+            setOutput((prev) => prev + `\nEl script se detuvo en la ${where}; se deshicieron todos sus cambios`);
+        }
+        if (streamed && data.stopped) {
+            setOutput((prev) => prev + `\nEl script se detuvo porque falló el comando de la ${where} (on-error stop)`);
         }
+        if (streamed && data.dryRun) {
+            // El resumen del dry-run no pertenece a ninguna línea del script
+            const changes = data.changes || [];
+            const summary = changes.length
+                ? [`Dry-run: se harían ${changes.length} cambios (ningún disco fue modificado)`].concat(changes.map((c) => "  - " + c.message))
+                : ["Dry-run: no se modificaría ningún disco"];
+            setOutput((prev) => (prev ? prev + "\n" : "") + summary.join("\n"));
+        }
+        if (data.confirm) {
+            setConfirmData({ message: data.message });
+            setIsPaused(false);
+            setScriptId(data.scriptId);
+        } else if (data.paused) {
+            setConfirmData(null);
+            setIsPaused(true);
+            setScriptId(data.scriptId);
+        } else {
+            setConfirmData(null);
+            setIsPaused(false);
+            setScriptId(null);
+            setReportsVersion((v) => v + 1);
+        }
+    };
+
+    // Ejecuta un script con Server-Sent Events: muestra la salida de cada línea
+    // a medida que el servidor la envía
+    const streamScript = async (url, body) => {
         setIsLoading(true);
-        setIsPaused(false);
-        setRemainingLines([]);
-        setConfirmData(null);
+        setProgress(null);
         try {
-            const response = await fetch("http://34.207.72.129:8080/api/executeScript", {
+            const response = await fetch(url, {
                 method: "POST",
-                headers: {
-                    "Content-Type": "application/json",
-                },
-                body: JSON.stringify({ script: scriptToRun }),
+                headers: { "Content-Type": "application/json" },
+                body: body ? JSON.stringify(body) : undefined,
             });
-            const data = await response.json();
-            setOutput((prev) => (prev ? prev + "\n" : "") + (data.console || ""));
-            if (data.confirm) {
-                setConfirmData({
-                    message: data.message,
-                    originalScript: scriptToRun,
-                    remaining: data.remaining || [],
-                });
-            } else if (data.paused) {
-                setIsPaused(true);
-                setRemainingLines(data.remaining || []);
-            } else {
-                setIsPaused(false);
-                setRemainingLines([]);
+            if (!response.ok || !response.body) {
+                applyScriptResponse(await response.json(), false);
+                return;
+            }
+            const reader = response.body.getReader();
+            const decoder = new TextDecoder();
+            let buffer = "";
+            for (;;) {
+                const { done, value } = await reader.read();
+                if (done) break;
+                buffer += decoder.decode(value, { stream: true });
+                let sep;
+                while ((sep = buffer.indexOf("\n\n")) >= 0) {
+                    const frame = buffer.slice(0, sep);
+                    buffer = buffer.slice(sep + 2);
+                    let event = "message";
+                    let data = "";
+                    for (const line of frame.split("\n")) {
+                        if (line.startsWith("event: ")) event = line.slice(7);
+                        else if (line.startsWith("data: ")) data += line.slice(6);
+                    }
+                    const payload = data ? JSON.parse(data) : {};
+                    if (event === "start") {
+                        setScriptId(payload.scriptId);
+                    } else if (event === "line") {
+                        const text = [">> " + payload.text].concat((payload.output || []).map((l) => l.text)).join("\n");
+                        setOutput((prev) => (prev ? prev + "\n" : "") + text);
+                        setProgress(payload.line);
+                    } else if (event === "end") {
+                        applyScriptResponse(payload, true);
+                    } else if (event === "error") {
+                        applyScriptResponse(payload, false);
+                    }
+                }
             }
         } catch (error) {
-            setOutput("Error al ejecutar comandos: " + error.message);
+            setOutput((prev) => (prev ? prev + "\n" : "") + "Error al ejecutar comandos: " + error.message);
         } finally {
             setIsLoading(false);
+            setProgress(null);
         }
     };
 
-    // Continuar después de pausa
-    const handleContinuePause = async () => {
-        setIsLoading(true);
+    // Ejecutar comandos (primer envío). Con dryRun el script se simula sin modificar los discos.
+    const executeCommands = async (dryRun = false) => {
+        if (!commands.trim()) {
+            alert("Por favor, ingrese comandos para ejecutar.");
+            return;
+        }
+        setIsPaused(false);
+        setScriptId(null);
+        setConfirmData(null);
+        await streamScript("http://34.207.72.129:8080/api/scripts/stream", { script: commands, dryRun, transactional: transactional && !dryRun });
+    };
+
+    // Envía una acción (continue o confirm) sobre el script pausado en el servidor
+    const scriptAction = async (action) => {
+        if (!scriptId) return;
+        await streamScript(`http://34.207.72.129:8080/api/scripts/${scriptId}/${action}/stream`);
+    };
+
+    // Detiene el script en ejecución antes de su siguiente línea
+    const handleStopScript = async () => {
+        if (!scriptId) return;
         try {
-            const response = await fetch("http://34.207.72.129:8080/api/continueScript", {
-                method: "POST",
-                headers: { "Content-Type": "application/json" },
-                body: JSON.stringify({ remaining: remainingLines }),
-            });
-            const data = await response.json();
-            setOutput((prev) => (prev ? prev + "\n" : "") + (data.console || ""));
-            if (data.confirm) {
-                setConfirmData({
-                    message: data.message,
-                    originalScript: remainingLines.join("\n"),
-                    remaining: data.remaining || [],
-                });
-                setIsPaused(false);
-                setRemainingLines([]);
-            } else if (data.paused) {
-                setIsPaused(true);
-                setRemainingLines(data.remaining || []);
-            } else {
-                setIsPaused(false);
-                setRemainingLines([]);
-            }
+            await fetch(`http://34.207.72.129:8080/api/scripts/${scriptId}/cancel`, { method: "POST" });
         } catch (error) {
-            setOutput("Error al continuar: " + error.message);
-        } finally {
-            setIsLoading(false);
+            setOutput((prev) => prev + "\nError al detener el script: " + error.message);
         }
     };
+
+    // Continuar después de pausa
+    const handleContinuePause = () => scriptAction("continue");
     /*
     // Ejecutar comando individual (por ejemplo, para rmdisk)
     const executeSingleCommand = async () => {
@@ -180,55 +263,20 @@ function App() {
     };
     */
 
-    // Confirmar eliminación de disco (afirmativo)
-    const handleConfirmRmdisk = async () => {
-        setIsLoading(true);
-        // Buscar la línea de rmdisk pendiente y agregar -confirm=true
-        const lines = confirmData.originalScript.split(/\r?\n/);
-        let found = false;
-        const newLines = lines.map(line => {
-            if (!found && line.trim().toLowerCase().startsWith("rmdisk") && !line.includes("-confirm=true")) {
-                found = true;
-                return line.trim() + " -confirm=true";
-            }
-            return line;
-        });
-        // Ejecutar el resto del script (incluyendo la línea modificada)
-        try {
-            await executeCommands(newLines.join("\n"));
-        } finally {
-            setConfirmData(null);
-            setIsLoading(false);
-        }
-    };
+    // Confirmar el comando pendiente (afirmativo)
+    const handleConfirmCommand = () => scriptAction("confirm");
 
-    // Cancelar eliminación de disco (negativo)
-    const handleCancelRmdisk = async () => {
-        setIsLoading(true);
-        // Omitir la línea de rmdisk pendiente y continuar con el resto del script
-        const lines = confirmData.originalScript.split(/\r?\n/);
-        let found = false;
-        const newLines = lines.filter(line => {
-            if (!found && line.trim().toLowerCase().startsWith("rmdisk") && !line.includes("-confirm=true")) {
-                found = true;
-                return false; // omitir esta línea
-            }
-            return true;
-        });
-        // Ejecutar el resto del script (sin la línea de rmdisk)
-        try {
-            await executeCommands(newLines.join("\n"));
-        } finally {
-            setConfirmData(null);
-            setIsLoading(false);
-        }
-    };
+    // Omitir el comando pendiente y continuar con el resto del script (negativo)
+    const handleSkipCommand = () => scriptAction("continue");
 
     const clearAll = () => {
+        if (scriptId) {
+            fetch(`http://34.207.72.129:8080/api/scripts/${scriptId}/cancel`, { method: "POST" }).catch(() => {});
+        }
         setCommands("");
         setOutput("");
         setIsPaused(false);
-        setRemainingLines([]);
+        setScriptId(null);
         setConfirmData(null);
         //setSingleCommand("");
     };
@@ -314,14 +362,14 @@ function App() {
                         )}
                     </div>
 
-                    {/* Confirmación de rmdisk */}
+                    {/* Confirmación de comandos destructivos */}
                     {confirmData && (
                         <div style={{margin: "1rem 0", background: "#ffe0e0", padding: "1rem", borderRadius: "8px"}}>
-                            <p>{confirmData.message.replace("CONFIRM_RMDISK:", "")}</p>
-                            <button onClick={handleConfirmRmdisk} disabled={isLoading} style={{background: "#c0392b", color: "white", fontWeight: "bold", border: "none", borderRadius: "6px", padding: "0.5rem 1rem"}}>
-                                {isLoading ? "Eliminando..." : "Eliminar"}
+                            <p>{confirmData.message}</p>
+                            <button onClick={handleConfirmCommand} disabled={isLoading} style={{background: "#c0392b", color: "white", fontWeight: "bold", border: "none", borderRadius: "6px", padding: "0.5rem 1rem"}}>
+                                {isLoading ? "Ejecutando..." : "Confirmar"}
                             </button>
-                            <button onClick={handleCancelRmdisk} disabled={isLoading} style={{marginLeft: "1rem"}}>Cancelar</button>
+                            <button onClick={handleSkipCommand} disabled={isLoading} style={{marginLeft: "1rem"}}>Omitir</button>
                         </div>
                     )}
 
@@ -333,9 +381,16 @@ function App() {
                             rows={6}
                             placeholder="Ingrese los comandos aquí..."
                             value={commands}
-                            onChange={(e) => setCommands(e.target.value)}
+                            onChange={(e) => { setCommands(e.target.value); updateCurrentCommand(e.target); }}
+                            onKeyUp={(e) => updateCurrentCommand(e.target)}
+                            onClick={(e) => updateCurrentCommand(e.target)}
                             disabled={isLoading || !!confirmData}
                         ></textarea>
+                        {commandDocs[currentCommand] && (
+                            <div title={commandDocs[currentCommand].flags.map((f) => `-${f.name}: ${f.help}`).join("\n")} style={{fontFamily: "monospace", fontSize: "0.85rem", color: "#555", marginTop: "0.25rem"}}>
+                                {commandDocs[currentCommand].usage} — {commandDocs[currentCommand].summary}
+                            </div>
+                        )}
                     </div>
 
                     <div className="actions-container">
@@ -350,8 +405,25 @@ function App() {
                             onClick={() => executeCommands()}
                             disabled={isLoading || isPaused || !!confirmData}
                         >
-                            {isLoading ? "Ejecutando..." : "Ejecutar"}
+                            {isLoading ? (progress ? `Ejecutando línea ${progress}...` : "Ejecutando...") : "Ejecutar"}
                         </button>
+                        <button
+                            onClick={() => executeCommands(true)}
+                            disabled={isLoading || isPaused || !!confirmData}
+                            title="Simula el script sin modificar los discos"
+                            style={{marginLeft: '1rem'}}
+                        >
+                            Simular
+                        </button>
+                        <label style={{marginLeft: '1rem'}} title="Si un comando falla se deshacen todos los cambios del script">
+                            <input type="checkbox" checked={transactional} onChange={(e) => setTransactional(e.target.checked)} disabled={isLoading} />
+                            {" "}Transaccional
+                        </label>
+                        {isLoading && scriptId && (
+                            <button onClick={handleStopScript} style={{marginLeft: '1rem', backgroundColor: '#c0392b', color: 'white', border: 'none', borderRadius: '6px', padding: '0.5rem 1rem', fontWeight: 'bold', cursor: 'pointer'}}>
+                                Detener
+                            </button>
+                        )}
                         <button className="clear-button" onClick={clearAll}>
                             Limpiar
                         </button>
@@ -366,6 +438,8 @@ function App() {
                         <h2>Salida:</h2>
                         <pre className="output">{output}</pre>
                     </div>
+
+                    <ReportViewer refresh={reportsVersion} />
                 </>
             )}
 
@@ -0,0 +1,97 @@
+import React, { useEffect, useState } from "react";
+
+const API = "http://34.207.72.129:8080/api/reports";
+
+// Lista los reportes generados con rep y muestra el seleccionado.
+// refresh cambia cada vez que termina un script para volver a cargar la lista.
+function ReportViewer({ refresh }) {
+  const [reports, setReports] = useState([]);
+  const [selected, setSelected] = useState(null);
+  const [text, setText] = useState("");
+
+  useEffect(() => {
+    fetch(API)
+      .then((res) => res.json())
+      .then((data) => {
+        const list = Array.isArray(data) ? data : [];
+        setReports(list);
+        // Un reporte regenerado conserva su ID pero cambia su contenido
+        setSelected((prev) => (prev && list.find((r) => r.id === prev.id)) || null);
+      })
+      .catch(() => setReports([]));
+  }, [refresh]);
+
+  // Los reportes de texto se muestran en un <pre>; las imágenes con <img>
+  useEffect(() => {
+    setText("");
+    if (selected && !selected.contentType.startsWith("image/") && selected.contentType !== "application/pdf") {
+      fetch(`${API}/${selected.id}`)
+        .then((res) => res.text())
+        .then(setText)
+        .catch(() => setText("No se pudo cargar el reporte"));
+    }
+  }, [selected]);
+
+  if (reports.length === 0) return null;
+
+  const url = selected ? `${API}/${selected.id}?v=${selected.createdAt}` : "";
+
+  return (
+    <div style={styles.container}>
+      <h2>Reportes:</h2>
+      <ul style={styles.list}>
+        {reports.map((report) => (
+          <li key={report.id}>
+            <button
+              onClick={() => setSelected(report)}
+              style={{ ...styles.item, fontWeight: selected && selected.id === report.id ? "bold" : "normal" }}
+            >
+              {report.path}
+            </button>
+            <span style={styles.meta}>
+              {report.name} · {report.partition} · {new Date(report.createdAt).toLocaleString()}
+            </span>
+            <a href={`${API}/${report.id}?download=1`} style={styles.meta}>Descargar</a>
+          </li>
+        ))}
+      </ul>
+      {selected && selected.contentType.startsWith("image/") && (
+        <img src={url} alt={selected.path} style={styles.preview} />
+      )}
+      {selected && selected.contentType === "application/pdf" && (
+        <iframe src={url} title={selected.path} style={{ ...styles.preview, width: "100%", height: "600px" }} />
+      )}
+      {selected && text && <pre className="output">{text}</pre>}
+    </div>
+  );
+}
+
+const styles = {
+  container: {
+    marginTop: "1rem",
+  },
+  list: {
+    listStyle: "none",
+    padding: 0,
+  },
+  item: {
+    background: "none",
+    border: "none",
+    color: "#2980b9",
+    cursor: "pointer",
+    padding: 0,
+  },
+  meta: {
+    marginLeft: "1rem",
+    color: "#777",
+    fontSize: "0.85rem",
+  },
+  preview: {
+    maxWidth: "100%",
+    border: "1px solid #ddd",
+    borderRadius: "6px",
+    background: "white",
+  },
+};
+
+export default ReportViewer;